@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"flag"
@@ -13,10 +14,12 @@ import (
 	"github.com/codecrafted007/service-catalog-api/internal/logger"
 	"github.com/codecrafted007/service-catalog-api/internal/middleware"
 	"github.com/codecrafted007/service-catalog-api/internal/storage"
-	"github.com/codecrafted007/service-catalog-api/internal/storage/sqlite"
+	_ "github.com/codecrafted007/service-catalog-api/internal/storage/mysql"
+	_ "github.com/codecrafted007/service-catalog-api/internal/storage/postgres"
+	_ "github.com/codecrafted007/service-catalog-api/internal/storage/sqlite"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
 	"github.com/gorilla/mux"
-	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
 	"go.uber.org/zap"
 )
 
@@ -33,35 +36,164 @@ func main() {
 	driver := flag.String("db-driver", "sqlite3", "Database driver: sqlite3|mysql|postgres")
 	dataSourceName := flag.String("db-dsn", "services.db", "Data source name or file path")
 	httpPort := flag.String("port", "8080", "HTTP server port")
+	skipMigrations := flag.Bool("skip-migrations", false, "Do not apply pending schema migrations on startup")
+	printSchemaOnly := flag.Bool("print-schema", false, "Print the schema DDL for --db-driver and exit")
+	validationConfig := flag.String("validation-config", "", "JSON file overriding the default request validation limits")
+	errorFormat := flag.String("error-format", string(utils.ErrorFormatEnvelope), "Default error body: envelope|problem (clients can always request application/problem+json)")
+
+	opts := storage.DefaultOptions()
+	flag.IntVar(&opts.MaxOpenConns, "db-max-open-conns", opts.MaxOpenConns, "Maximum open DB connections (0 = unlimited)")
+	flag.IntVar(&opts.MaxIdleConns, "db-max-idle-conns", opts.MaxIdleConns, "Maximum idle DB connections")
+	flag.DurationVar(&opts.ConnMaxLifetime, "db-conn-max-lifetime", opts.ConnMaxLifetime, "Maximum lifetime of a DB connection (0 = forever)")
+	flag.StringVar(&opts.SQLite.JournalMode, "sqlite-journal-mode", opts.SQLite.JournalMode, "SQLite journal_mode pragma (empty = driver default)")
+	flag.DurationVar(&opts.SQLite.BusyTimeout, "sqlite-busy-timeout", opts.SQLite.BusyTimeout, "SQLite busy_timeout pragma")
+	flag.BoolVar(&opts.SQLite.ForeignKeys, "sqlite-foreign-keys", opts.SQLite.ForeignKeys, "Enable SQLite foreign_keys pragma")
 	flag.Parse()
 
-	if err := ensureSchemaExists(*driver, *dataSourceName); err != nil {
-		log.Fatal("failed to initialize schema ", err)
+	format, err := utils.ParseErrorFormat(*errorFormat)
+	if err != nil {
+		log.Fatal(err)
 	}
+	utils.SetErrorFormat(format)
 
-	// Init sqlite store (for MySQL/Postgres we can add support later)
-	store, err := sqlite.New("services.db")
+	if *printSchemaOnly {
+		if err := printSchema(*driver); err != nil {
+			log.Fatal("failed to print schema ", err)
+		}
+		return
+	}
+
+	store, err := storage.Open(*driver, *dataSourceName, opts)
 	if err != nil {
 		log.Fatal("failed to connect to db", err)
 	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(store, *driver, flag.Args()[1:]); err != nil {
+			logger.L().Fatalw("migrate failed", "error", err)
+		}
+		return
+	}
+
+	if *skipMigrations {
+		logger.L().Warn("Skipping schema migrations")
+	} else if err := applyMigrations(store, *driver); err != nil {
+		log.Fatal("failed to migrate schema ", err)
+	}
+
+	if flag.Arg(0) == "keys" {
+		if err := runKeys(store, flag.Args()[1:]); err != nil {
+			logger.L().Fatalw("keys failed", "error", err)
+		}
+		return
+	}
+
 	initDatabase(store, logger.L())
 	r := mux.NewRouter()
-	r.Use(middleware.APIKeyAuth(store.IsValidAPIKey))
+	r.Use(middleware.RequestID)
+	r.Use(middleware.APIKeyAuth(store.GetAPIKey))
+
+	validator, err := newValidator(*validationConfig)
+	if err != nil {
+		log.Fatal("failed to load validation config ", err)
+	}
 
 	h := handler.NewServiceHandler(store, logger.L())
+	h.Validator = validator
 
 	r.HandleFunc("/services", h.ListServices).Methods("GET")
 	r.HandleFunc("/services/{id}", h.GetServiceByID).Methods("GET")
 	r.HandleFunc("/services", h.CreateService).Methods("POST")
 	r.HandleFunc("/services/{id}", h.UpdateService).Methods("PUT")
+	r.HandleFunc("/services/{id}", h.PatchService).Methods("PATCH")
 	r.HandleFunc("/services/{id}", h.DeleteService).Methods("DELETE")
 
 	vh := handler.NewVersionHandler(store, logger.L())
+	vh.Validator = validator
 
 	r.HandleFunc("/services/{id}/versions", vh.CreateVersion).Methods("POST")
 	r.HandleFunc("/services/{id}/versions", vh.ListVersions).Methods("GET")
+	r.HandleFunc("/services/{id}/versions/latest", vh.LatestVersion).Methods("GET")
+	r.HandleFunc("/services/{id}/versions/resolve", vh.ResolveVersion).Methods("GET")
 	r.HandleFunc("/versions/{id}", vh.GetVersion).Methods("GET")
+	r.HandleFunc("/versions/{id}", vh.UpdateVersion).Methods("PUT")
+	r.HandleFunc("/versions/{id}", vh.PatchVersion).Methods("PATCH")
 	r.HandleFunc("/versions/{id}", vh.DeleteVersion).Methods("DELETE")
+	r.HandleFunc("/versions/{id}/state", vh.TransitionVersion).Methods("POST")
+
+	th := handler.NewTeamHandler(store, logger.L())
+	th.Validator = validator
+
+	r.HandleFunc("/teams", th.ListTeams).Methods("GET")
+	r.HandleFunc("/teams", th.CreateTeam).Methods("POST")
+	r.HandleFunc("/teams/{id}", th.GetTeam).Methods("GET")
+	r.HandleFunc("/teams/{id}", th.UpdateTeam).Methods("PUT")
+	r.HandleFunc("/teams/{id}", th.DeleteTeam).Methods("DELETE")
+	r.HandleFunc("/services/{id}/owners", th.GetServiceOwners).Methods("GET")
+	r.HandleFunc("/services/{id}/owners", th.SetServiceOwners).Methods("PUT")
+
+	lh := handler.NewLabelHandler(store, logger.L())
+	lh.Validator = validator
+
+	// Label keys and tags may carry a "prefix/" part.
+	r.HandleFunc("/services/{id}/labels/{key:.+}", lh.SetLabel).Methods("PUT")
+	r.HandleFunc("/services/{id}/labels/{key:.+}", lh.RemoveLabel).Methods("DELETE")
+	r.HandleFunc("/services/{id}/tags/{tag:.+}", lh.AddTag).Methods("PUT")
+	r.HandleFunc("/services/{id}/tags/{tag:.+}", lh.RemoveTag).Methods("DELETE")
+
+	dh := handler.NewDependencyHandler(store, logger.L())
+	dh.Validator = validator
+
+	r.HandleFunc("/services/{id}/dependencies", dh.AddDependency).Methods("POST")
+	r.HandleFunc("/services/{id}/dependencies", dh.ListDependencies).Methods("GET")
+	r.HandleFunc("/services/{id}/dependencies/{dependsOnId}", dh.RemoveDependency).Methods("DELETE")
+	r.HandleFunc("/services/{id}/dependents", dh.ListDependents).Methods("GET")
+
+	gh := handler.NewGraphHandler(store, logger.L())
+	r.HandleFunc("/graph", gh.GetGraph).Methods("GET")
+
+	deph := handler.NewDeploymentHandler(store, logger.L())
+	deph.Validator = validator
+
+	r.HandleFunc("/environments", deph.ListEnvironments).Methods("GET")
+	r.HandleFunc("/environments", deph.CreateEnvironment).Methods("POST")
+	r.HandleFunc("/environments/{id}", deph.DeleteEnvironment).Methods("DELETE")
+	r.HandleFunc("/services/{id}/deployments", deph.CreateDeployment).Methods("POST")
+	r.HandleFunc("/services/{id}/deployments", deph.ListDeployments).Methods("GET")
+	r.HandleFunc("/services/{id}/environments", deph.ServiceEnvironments).Methods("GET")
+
+	ph := handler.NewPromotionHandler(store, logger.L())
+	ph.Validator = validator
+
+	r.HandleFunc("/promotion-rules", ph.ListRules).Methods("GET")
+	r.HandleFunc("/environments/{id}/promotion-rule", ph.SetRule).Methods("PUT")
+	r.HandleFunc("/environments/{id}/promotion-rule", ph.DeleteRule).Methods("DELETE")
+	r.HandleFunc("/versions/{id}/approvals", ph.ListApprovals).Methods("GET")
+	r.HandleFunc("/versions/{id}/approvals", ph.AddApproval).Methods("POST")
+	r.HandleFunc("/versions/{id}/promote", ph.Promote).Methods("POST")
+
+	ah := handler.NewApprovalHandler(store, logger.L())
+	ah.Validator = validator
+
+	r.HandleFunc("/approval-policies", ah.ListPolicies).Methods("GET")
+	r.HandleFunc("/approval-policies", ah.CreatePolicy).Methods("POST")
+	r.HandleFunc("/approval-policies/{id}", ah.DeletePolicy).Methods("DELETE")
+	r.HandleFunc("/versions/{id}/approval-requests", ah.RequestApproval).Methods("POST")
+	r.HandleFunc("/versions/{id}/approval-requests", ah.ListVersionRequests).Methods("GET")
+	r.HandleFunc("/approval-requests", ah.ListRequests).Methods("GET")
+	r.HandleFunc("/approval-requests/{id}", ah.GetRequest).Methods("GET")
+	r.HandleFunc("/approval-requests/{id}/approve", ah.Approve).Methods("POST")
+	r.HandleFunc("/approval-requests/{id}/reject", ah.Reject).Methods("POST")
+
+	fh := handler.NewFreezeHandler(store, logger.L())
+	fh.Validator = validator
+	r.HandleFunc("/freezes", fh.ListFreezes).Methods("GET")
+	r.HandleFunc("/freezes", fh.CreateFreeze).Methods("POST")
+	r.HandleFunc("/freezes/active", fh.ActiveFreezes).Methods("GET")
+	r.HandleFunc("/freezes/{id}", fh.DeleteFreeze).Methods("DELETE")
+
+	audh := handler.NewAuditHandler(store, logger.L())
+	r.HandleFunc("/audit", audh.ListEvents).Methods("GET")
 
 	addr := fmt.Sprintf(":%s", *httpPort)
 	logger.L().Infof("Listening on %s", addr)
@@ -77,54 +209,55 @@ func initDatabase(store storage.Storage, logger *zap.SugaredLogger) {
 
 	if count == 0 {
 		apiKey := generateAPIKey()
-		_, err := store.DB().Exec("INSERT INTO api_keys (key) VALUES (?)", apiKey)
-		if err != nil {
+		if err := store.CreateAPIKey(cliContext(), apiKey, "admin", true); err != nil {
 			logger.Fatal("failed to insert default API key:", err)
 		}
 		logger.Infof("Default API key generated: %s", apiKey)
 	}
 }
 
-func generateAPIKey() string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
-}
-
-func ensureSchemaExists(driver, dsn string) error {
-	schemaFile := map[string]string{
-		"sqlite3":  "db/schema.sqlite.sql",
-		"mysql":    "db/schema.mysql.sql",
-		"postgres": "db/schema.postgres.sql",
-	}[driver]
-
-	if schemaFile == "" {
-		return fmt.Errorf("unsupported DB driver: %s", driver)
-	}
-
-	// only needed for sqlite
-	if driver == "sqlite3" {
-		if _, err := os.Stat(dsn); err == nil {
-			logger.L().Infof("SQLite DB already exists: %s", dsn)
-			return nil
+// newValidator builds the request validator from the defaults, overlaid
+// with the optional JSON config file.
+func newValidator(path string) (*validation.Validator, error) {
+	cfg := validation.DefaultConfig()
+	if path != "" {
+		var err error
+		if cfg, err = validation.LoadConfig(path); err != nil {
+			return nil, err
 		}
 	}
+	return validation.New(cfg)
+}
 
-	content, err := os.ReadFile(schemaFile)
-	if err != nil {
-		return fmt.Errorf("failed to read schema file: %w", err)
+// runKeys implements the keys subcommand: "keys create [-elevated] NAME"
+// prints a new API key. Actions such as approvals are recorded under the
+// key's name, and only elevated keys may override a change freeze.
+func runKeys(store storage.Storage, args []string) error {
+	usage := fmt.Errorf("usage: keys create [-elevated] NAME")
+	if len(args) == 0 || args[0] != "create" {
+		return usage
 	}
-
-	db, err := sqlx.Open(driver, dsn)
-	if err != nil {
-		return fmt.Errorf("failed to open DB: %w", err)
+	fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	elevated := fs.Bool("elevated", false, "Allow the key to override change freezes")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+		return usage
 	}
-	defer db.Close()
-
-	if _, err := db.Exec(string(content)); err != nil {
-		return fmt.Errorf("failed to apply schema: %w", err)
+	apiKey := generateAPIKey()
+	if err := store.CreateAPIKey(cliContext(), apiKey, fs.Arg(0), *elevated); err != nil {
+		return err
 	}
-
-	logger.L().Info("Schema applied successfully")
+	fmt.Println(apiKey)
 	return nil
 }
+
+// cliContext is the context for writes made by the binary itself rather
+// than through the API, which are audited as made by "cli".
+func cliContext() context.Context {
+	return storage.WithActor(context.Background(), "cli")
+}
+
+func generateAPIKey() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/codecrafted007/service-catalog-api/db"
+	"github.com/codecrafted007/service-catalog-api/internal/logger"
+	"github.com/codecrafted007/service-catalog-api/internal/migrations"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+)
+
+func newMigrator(store storage.Storage, driver string) (*migrations.Migrator, error) {
+	return storage.NewMigrator(store.DB(), driver)
+}
+
+// printSchema writes the DDL produced by applying every migration for driver.
+func printSchema(driver string) error {
+	schema, err := migrations.Schema(db.Migrations(), driver)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Print(schema)
+	return err
+}
+
+// applyMigrations brings the schema up to date on startup.
+func applyMigrations(store storage.Storage, driver string) error {
+	m, err := newMigrator(store, driver)
+	if err != nil {
+		return err
+	}
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		return err
+	}
+	if applied > 0 {
+		logger.L().Infow("Schema migrations applied", "count", applied, "version", m.Latest())
+	} else {
+		logger.L().Infow("Schema is up to date", "version", m.Latest())
+	}
+	return nil
+}
+
+// runMigrate implements `migrate up|down|status|to N`.
+func runMigrate(store storage.Storage, driver string, args []string) error {
+	m, err := newMigrator(store, driver)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down|status|to N")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", applied)
+	case "down":
+		if err := m.Down(ctx); err != nil {
+			return err
+		}
+		current, err := m.Current(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back to version %d\n", current)
+	case "to":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: migrate to N")
+		}
+		target, err := strconv.Atoi(args[1])
+		if err != nil || target < 0 {
+			return fmt.Errorf("invalid migration version %q", args[1])
+		}
+		changed, err := m.To(ctx, target)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("ran %d migration(s), now at version %d\n", changed, target)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range statuses {
+			appliedAt := "pending"
+			if st.AppliedAt != nil {
+				appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up, down, status or to N)", args[0])
+	}
+	return nil
+}
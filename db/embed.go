@@ -0,0 +1,21 @@
+// Package db embeds the SQL migrations so the binary does not depend on the
+// directory it is started from.
+package db
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// Migrations returns the migrations tree rooted at one directory per driver.
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrationsFS, "migrations")
+	if err != nil {
+		// fs.Sub only fails on an invalid path, which is a compile-time constant here.
+		panic(err)
+	}
+	return sub
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/codecrafted007/service-catalog-api/internal/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Every driver must ship the same numbered migrations so a schema change is
+// never applied to one backend only.
+func TestMigrationsAreInSyncAcrossDrivers(t *testing.T) {
+	var reference []migrations.Migration
+	for _, driver := range []string{"sqlite3", "postgres", "mysql"} {
+		loaded, err := migrations.Load(Migrations(), driver)
+		require.NoError(t, err, driver)
+		require.NotEmpty(t, loaded, driver)
+
+		if reference == nil {
+			reference = loaded
+			continue
+		}
+		require.Len(t, loaded, len(reference), driver)
+		for i := range loaded {
+			assert.Equal(t, reference[i].Version, loaded[i].Version, driver)
+			assert.Equal(t, reference[i].Name, loaded[i].Name, driver)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS api_keys;
+
+DROP TABLE IF EXISTS versions;
+
+DROP TABLE IF EXISTS services;
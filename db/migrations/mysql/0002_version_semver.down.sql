@@ -0,0 +1,7 @@
+DROP INDEX idx_versions_semver ON versions;
+
+ALTER TABLE versions
+    DROP COLUMN prerelease,
+    DROP COLUMN patch,
+    DROP COLUMN minor,
+    DROP COLUMN major;
@@ -0,0 +1,9 @@
+-- Normalized semantic version parts. Rows whose version string is not a
+-- semantic version keep NULLs and rank below every valid version.
+ALTER TABLE versions
+    ADD COLUMN major BIGINT UNSIGNED NULL,
+    ADD COLUMN minor BIGINT UNSIGNED NULL,
+    ADD COLUMN patch BIGINT UNSIGNED NULL,
+    ADD COLUMN prerelease VARCHAR(255) NULL;
+
+CREATE INDEX idx_versions_semver ON versions(service_id, major, minor, patch);
@@ -0,0 +1,3 @@
+DROP INDEX idx_versions_service_version ON versions;
+
+DROP INDEX idx_services_name ON services;
@@ -0,0 +1,5 @@
+-- Existing duplicates must be resolved before this migration can apply.
+-- The table's default utf8mb4 collation already compares case-insensitively.
+CREATE UNIQUE INDEX idx_services_name ON services(name);
+
+CREATE UNIQUE INDEX idx_versions_service_version ON versions(service_id, version);
@@ -0,0 +1,3 @@
+ALTER TABLE versions DROP COLUMN revision;
+
+ALTER TABLE services DROP COLUMN revision;
@@ -0,0 +1,4 @@
+-- Incremented on every write; exposed as the ETag for optimistic locking.
+ALTER TABLE services ADD COLUMN revision BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE versions ADD COLUMN revision BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+-- Lifecycle: draft -> released -> deprecated, or yanked from any state.
+-- Existing versions were published as soon as they were created.
+ALTER TABLE versions ADD COLUMN state VARCHAR(16) NOT NULL DEFAULT 'released';
+
+ALTER TABLE versions ADD COLUMN state_reason VARCHAR(2000) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS service_owners;
+
+DROP TABLE IF EXISTS teams;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS teams (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description VARCHAR(2000) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    chat VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_teams_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- One row per owner of a service: the owning team (team_id set) or an
+-- individual contact (team_id NULL).
+CREATE TABLE IF NOT EXISTS service_owners (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    service_id INT NOT NULL,
+    team_id BIGINT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    chat VARCHAR(255) NOT NULL DEFAULT '',
+    INDEX idx_service_owners_service_id (service_id),
+    INDEX idx_service_owners_team_id (team_id),
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE,
+    FOREIGN KEY(team_id) REFERENCES teams(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
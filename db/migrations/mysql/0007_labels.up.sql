@@ -0,0 +1,18 @@
+-- Labels and tags compare case-sensitively, unlike names, so they use a
+-- binary collation.
+CREATE TABLE IF NOT EXISTS service_labels (
+    service_id INT NOT NULL,
+    name VARCHAR(317) COLLATE utf8mb4_bin NOT NULL,
+    value VARCHAR(63) COLLATE utf8mb4_bin NOT NULL DEFAULT '',
+    PRIMARY KEY (service_id, name),
+    INDEX idx_service_labels_name_value (name, value),
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS service_tags (
+    service_id INT NOT NULL,
+    tag VARCHAR(317) COLLATE utf8mb4_bin NOT NULL,
+    PRIMARY KEY (service_id, tag),
+    INDEX idx_service_tags_tag (tag),
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
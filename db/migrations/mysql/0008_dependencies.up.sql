@@ -0,0 +1,13 @@
+-- One row per edge: service_id calls depends_on_id, optionally pinned to a
+-- semver range of it.
+CREATE TABLE IF NOT EXISTS service_dependencies (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    service_id INT NOT NULL,
+    depends_on_id INT NOT NULL,
+    version_constraint VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_service_dependencies_edge (service_id, depends_on_id),
+    INDEX idx_service_dependencies_depends_on_id (depends_on_id),
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE,
+    FOREIGN KEY(depends_on_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS environments (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description VARCHAR(2000) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_environments_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT INTO environments (name) VALUES ('dev'), ('staging'), ('prod');
+
+CREATE TABLE IF NOT EXISTS deployments (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    service_id INT NOT NULL,
+    version_id BIGINT NOT NULL,
+    environment_id BIGINT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'succeeded',
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    deployed_at DATETIME(6) NOT NULL,
+    INDEX idx_deployments_service_id (service_id, deployed_at),
+    INDEX idx_deployments_version_id (version_id),
+    INDEX idx_deployments_environment_id (environment_id),
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE,
+    FOREIGN KEY(version_id) REFERENCES versions(id) ON DELETE CASCADE,
+    FOREIGN KEY(environment_id) REFERENCES environments(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,25 @@
+-- At most one rule per target environment: versions reach it only after a
+-- successful deployment to from_environment_id.
+CREATE TABLE IF NOT EXISTS promotion_rules (
+    environment_id BIGINT PRIMARY KEY,
+    from_environment_id BIGINT NOT NULL,
+    min_soak_seconds BIGINT NOT NULL DEFAULT 0,
+    required_approvals INT NOT NULL DEFAULT 0,
+    FOREIGN KEY(environment_id) REFERENCES environments(id) ON DELETE CASCADE,
+    FOREIGN KEY(from_environment_id) REFERENCES environments(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Decisions on promoting a version to an environment; each approver's
+-- latest decision replaces the earlier one.
+CREATE TABLE IF NOT EXISTS approvals (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    version_id BIGINT NOT NULL,
+    environment_id BIGINT NOT NULL,
+    approver VARCHAR(255) NOT NULL,
+    decision VARCHAR(16) NOT NULL,
+    comment VARCHAR(2000) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_approvals_version_id (version_id, environment_id),
+    FOREIGN KEY(version_id) REFERENCES versions(id) ON DELETE CASCADE,
+    FOREIGN KEY(environment_id) REFERENCES environments(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS approval_reviews;
+
+DROP TABLE IF EXISTS approval_requests;
+
+DROP TABLE IF EXISTS approval_policies;
+
+DROP INDEX idx_api_keys_name ON api_keys;
+
+ALTER TABLE api_keys DROP COLUMN name;
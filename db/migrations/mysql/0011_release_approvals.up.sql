@@ -0,0 +1,44 @@
+-- Every key names whoever uses it; approvals are recorded under that name.
+-- Existing keys are named after their ID.
+ALTER TABLE api_keys ADD COLUMN name VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE api_keys SET name = CONCAT('key-', id);
+
+CREATE UNIQUE INDEX idx_api_keys_name ON api_keys(name);
+
+-- How many approvals releasing a version takes, for one service or for every
+-- service carrying the label label_key=label_value.
+CREATE TABLE IF NOT EXISTS approval_policies (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    service_id INT,
+    label_key VARCHAR(317) COLLATE utf8mb4_bin NOT NULL DEFAULT '',
+    label_value VARCHAR(63) COLLATE utf8mb4_bin NOT NULL DEFAULT '',
+    required_approvals INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS approval_requests (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    version_id BIGINT NOT NULL,
+    requested_by VARCHAR(255) NOT NULL,
+    comment VARCHAR(2000) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    required_approvals INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    resolved_at DATETIME NULL,
+    INDEX idx_approval_requests_version_id (version_id),
+    INDEX idx_approval_requests_status (status),
+    FOREIGN KEY(version_id) REFERENCES versions(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS approval_reviews (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    request_id BIGINT NOT NULL,
+    reviewer VARCHAR(255) NOT NULL,
+    decision VARCHAR(16) NOT NULL,
+    comment VARCHAR(2000) NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_approval_reviews_request_id (request_id),
+    FOREIGN KEY(request_id) REFERENCES approval_requests(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS freezes;
+
+ALTER TABLE api_keys DROP COLUMN elevated;
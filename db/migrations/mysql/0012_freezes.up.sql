@@ -0,0 +1,19 @@
+-- Only an elevated key may override a change freeze.
+ALTER TABLE api_keys ADD COLUMN elevated BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- A change freeze: between starts_at and ends_at no version of a service it
+-- covers is released, promoted or deployed. It covers the services matching
+-- selector (a label selector) and owned by team_id, whichever are set, or
+-- every service when neither is.
+CREATE TABLE IF NOT EXISTS freezes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    starts_at DATETIME(6) NOT NULL,
+    ends_at DATETIME(6) NOT NULL,
+    selector VARCHAR(2000) COLLATE utf8mb4_bin NOT NULL DEFAULT '',
+    team_id BIGINT NULL,
+    reason VARCHAR(2000) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_freezes_ends_at (ends_at),
+    FOREIGN KEY(team_id) REFERENCES teams(id) ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,17 @@
+-- One row per write made through the storage layer: who made it, in which
+-- request, and the record as JSON before and after (NULL where it did not
+-- exist). Events outlive the records they describe, so there are no foreign
+-- keys.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    action VARCHAR(16) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    before_json JSON NULL,
+    after_json JSON NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    created_at DATETIME(6) NOT NULL,
+    INDEX idx_audit_events_entity (entity_type, entity_id, created_at),
+    INDEX idx_audit_events_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
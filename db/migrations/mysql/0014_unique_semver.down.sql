@@ -0,0 +1,6 @@
+DROP INDEX idx_versions_service_semver ON versions;
+
+-- Versions differing only in case must be resolved before this can apply.
+ALTER TABLE versions
+    MODIFY version VARCHAR(255) NOT NULL,
+    MODIFY prerelease VARCHAR(255) NULL;
@@ -0,0 +1,12 @@
+-- Versions compare case-sensitively, as on the other backends and in
+-- semver: 1.0.0-RC1 and 1.0.0-rc1 are different versions.
+ALTER TABLE versions
+    MODIFY version VARCHAR(255) COLLATE utf8mb4_bin NOT NULL,
+    MODIFY prerelease VARCHAR(255) COLLATE utf8mb4_bin NULL;
+
+-- A service has each semantic version once, however it is spelled: v1.0.0
+-- and 1.0.0 are the same version. Build metadata does not count, and rows
+-- that are not semantic versions keep NULLs, so only the unique index on
+-- the version string covers them. Existing duplicates must be resolved
+-- before this migration can apply.
+CREATE UNIQUE INDEX idx_versions_service_semver ON versions(service_id, major, minor, patch, prerelease);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_versions_semver;
+
+ALTER TABLE versions
+    DROP COLUMN prerelease,
+    DROP COLUMN patch,
+    DROP COLUMN minor,
+    DROP COLUMN major;
@@ -0,0 +1,9 @@
+-- Normalized semantic version parts. Rows whose version string is not a
+-- semantic version keep NULLs and rank below every valid version.
+ALTER TABLE versions
+    ADD COLUMN major BIGINT,
+    ADD COLUMN minor BIGINT,
+    ADD COLUMN patch BIGINT,
+    ADD COLUMN prerelease TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_versions_semver ON versions(service_id, major, minor, patch);
@@ -0,0 +1,4 @@
+-- Existing duplicates must be resolved before this migration can apply.
+CREATE UNIQUE INDEX idx_services_name ON services(LOWER(name));
+
+CREATE UNIQUE INDEX idx_versions_service_version ON versions(service_id, version);
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS teams (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    chat TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_teams_name ON teams(LOWER(name));
+
+-- One row per owner of a service: the owning team (team_id set) or an
+-- individual contact (team_id NULL).
+CREATE TABLE IF NOT EXISTS service_owners (
+    id BIGSERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    team_id BIGINT REFERENCES teams(id),
+    name TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    chat TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_service_owners_service_id ON service_owners(service_id);
+
+CREATE INDEX idx_service_owners_team_id ON service_owners(team_id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS service_labels (
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (service_id, name)
+);
+
+CREATE INDEX idx_service_labels_name_value ON service_labels(name, value);
+
+CREATE TABLE IF NOT EXISTS service_tags (
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (service_id, tag)
+);
+
+CREATE INDEX idx_service_tags_tag ON service_tags(tag);
@@ -0,0 +1,13 @@
+-- One row per edge: service_id calls depends_on_id, optionally pinned to a
+-- semver range of it.
+CREATE TABLE IF NOT EXISTS service_dependencies (
+    id BIGSERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    depends_on_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    version_constraint TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_service_dependencies_edge ON service_dependencies(service_id, depends_on_id);
+
+CREATE INDEX idx_service_dependencies_depends_on_id ON service_dependencies(depends_on_id);
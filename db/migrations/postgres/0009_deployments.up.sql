@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS environments (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_environments_name ON environments(LOWER(name));
+
+INSERT INTO environments (name) VALUES ('dev'), ('staging'), ('prod');
+
+CREATE TABLE IF NOT EXISTS deployments (
+    id BIGSERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    version_id BIGINT NOT NULL REFERENCES versions(id) ON DELETE CASCADE,
+    environment_id BIGINT NOT NULL REFERENCES environments(id),
+    status TEXT NOT NULL DEFAULT 'succeeded',
+    actor TEXT NOT NULL DEFAULT '',
+    deployed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_deployments_service_id ON deployments(service_id, deployed_at);
+
+CREATE INDEX idx_deployments_version_id ON deployments(version_id);
+
+CREATE INDEX idx_deployments_environment_id ON deployments(environment_id);
@@ -0,0 +1,22 @@
+-- At most one rule per target environment: versions reach it only after a
+-- successful deployment to from_environment_id.
+CREATE TABLE IF NOT EXISTS promotion_rules (
+    environment_id BIGINT PRIMARY KEY REFERENCES environments(id) ON DELETE CASCADE,
+    from_environment_id BIGINT NOT NULL REFERENCES environments(id),
+    min_soak_seconds BIGINT NOT NULL DEFAULT 0,
+    required_approvals INTEGER NOT NULL DEFAULT 0
+);
+
+-- Decisions on promoting a version to an environment; each approver's
+-- latest decision replaces the earlier one.
+CREATE TABLE IF NOT EXISTS approvals (
+    id BIGSERIAL PRIMARY KEY,
+    version_id BIGINT NOT NULL REFERENCES versions(id) ON DELETE CASCADE,
+    environment_id BIGINT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    approver TEXT NOT NULL,
+    decision TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_approvals_version_id ON approvals(version_id, environment_id);
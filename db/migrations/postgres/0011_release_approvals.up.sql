@@ -0,0 +1,43 @@
+-- Every key names whoever uses it; approvals are recorded under that name.
+-- Existing keys are named after their ID.
+ALTER TABLE api_keys ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+UPDATE api_keys SET name = 'key-' || id;
+
+CREATE UNIQUE INDEX idx_api_keys_name ON api_keys(name);
+
+-- How many approvals releasing a version takes, for one service or for every
+-- service carrying the label label_key=label_value.
+CREATE TABLE IF NOT EXISTS approval_policies (
+    id BIGSERIAL PRIMARY KEY,
+    service_id INTEGER REFERENCES services(id) ON DELETE CASCADE,
+    label_key TEXT NOT NULL DEFAULT '',
+    label_value TEXT NOT NULL DEFAULT '',
+    required_approvals INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS approval_requests (
+    id BIGSERIAL PRIMARY KEY,
+    version_id BIGINT NOT NULL REFERENCES versions(id) ON DELETE CASCADE,
+    requested_by TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    required_approvals INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_approval_requests_version_id ON approval_requests(version_id);
+CREATE INDEX idx_approval_requests_status ON approval_requests(status);
+
+CREATE TABLE IF NOT EXISTS approval_reviews (
+    id BIGSERIAL PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES approval_requests(id) ON DELETE CASCADE,
+    reviewer TEXT NOT NULL,
+    decision TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_approval_reviews_request_id ON approval_reviews(request_id);
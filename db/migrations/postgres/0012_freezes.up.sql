@@ -0,0 +1,19 @@
+-- Only an elevated key may override a change freeze.
+ALTER TABLE api_keys ADD COLUMN elevated BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- A change freeze: between starts_at and ends_at no version of a service it
+-- covers is released, promoted or deployed. It covers the services matching
+-- selector (a label selector) and owned by team_id, whichever are set, or
+-- every service when neither is.
+CREATE TABLE IF NOT EXISTS freezes (
+    id BIGSERIAL PRIMARY KEY,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    selector TEXT NOT NULL DEFAULT '',
+    team_id BIGINT REFERENCES teams(id) ON DELETE RESTRICT,
+    reason TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_freezes_ends_at ON freezes(ends_at);
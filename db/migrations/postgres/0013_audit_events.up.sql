@@ -0,0 +1,18 @@
+-- One row per write made through the storage layer: who made it, in which
+-- request, and the record as JSON before and after (NULL where it did not
+-- exist). Events outlive the records they describe, so there are no foreign
+-- keys.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id BIGINT NOT NULL,
+    before_json JSONB,
+    after_json JSONB,
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_audit_events_entity ON audit_events(entity_type, entity_id, created_at);
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
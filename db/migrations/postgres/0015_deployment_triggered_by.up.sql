@@ -0,0 +1,3 @@
+-- Who a caller such as CI says started a deployment; actor is the API key
+-- that recorded it.
+ALTER TABLE deployments ADD COLUMN triggered_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_versions_semver;
+
+ALTER TABLE versions DROP COLUMN prerelease;
+ALTER TABLE versions DROP COLUMN patch;
+ALTER TABLE versions DROP COLUMN minor;
+ALTER TABLE versions DROP COLUMN major;
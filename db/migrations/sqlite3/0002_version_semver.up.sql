@@ -0,0 +1,8 @@
+-- Normalized semantic version parts. Rows whose version string is not a
+-- semantic version keep NULLs and rank below every valid version.
+ALTER TABLE versions ADD COLUMN major INTEGER;
+ALTER TABLE versions ADD COLUMN minor INTEGER;
+ALTER TABLE versions ADD COLUMN patch INTEGER;
+ALTER TABLE versions ADD COLUMN prerelease TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_versions_semver ON versions(service_id, major, minor, patch);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_versions_service_version;
+
+DROP INDEX IF EXISTS idx_services_name;
@@ -0,0 +1,3 @@
+ALTER TABLE versions DROP COLUMN state_reason;
+
+ALTER TABLE versions DROP COLUMN state;
@@ -0,0 +1,5 @@
+-- Lifecycle: draft -> released -> deprecated, or yanked from any state.
+-- Existing versions were published as soon as they were created.
+ALTER TABLE versions ADD COLUMN state TEXT NOT NULL DEFAULT 'released';
+
+ALTER TABLE versions ADD COLUMN state_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS teams (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    chat TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_teams_name ON teams(lower(name));
+
+-- One row per owner of a service: the owning team (team_id set) or an
+-- individual contact (team_id NULL).
+CREATE TABLE IF NOT EXISTS service_owners (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    team_id INTEGER,
+    name TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    chat TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE,
+    FOREIGN KEY(team_id) REFERENCES teams(id)
+);
+
+CREATE INDEX idx_service_owners_service_id ON service_owners(service_id);
+
+CREATE INDEX idx_service_owners_team_id ON service_owners(team_id);
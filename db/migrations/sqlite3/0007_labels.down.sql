@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS service_tags;
+
+DROP TABLE IF EXISTS service_labels;
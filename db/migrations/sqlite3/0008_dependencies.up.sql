@@ -0,0 +1,15 @@
+-- One row per edge: service_id calls depends_on_id, optionally pinned to a
+-- semver range of it.
+CREATE TABLE IF NOT EXISTS service_dependencies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    depends_on_id INTEGER NOT NULL,
+    version_constraint TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE,
+    FOREIGN KEY(depends_on_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_service_dependencies_edge ON service_dependencies(service_id, depends_on_id);
+
+CREATE INDEX idx_service_dependencies_depends_on_id ON service_dependencies(depends_on_id);
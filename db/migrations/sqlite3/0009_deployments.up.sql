@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS environments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_environments_name ON environments(lower(name));
+
+INSERT INTO environments (name) VALUES ('dev'), ('staging'), ('prod');
+
+CREATE TABLE IF NOT EXISTS deployments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    version_id INTEGER NOT NULL,
+    environment_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'succeeded',
+    actor TEXT NOT NULL DEFAULT '',
+    deployed_at DATETIME NOT NULL,
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE,
+    FOREIGN KEY(version_id) REFERENCES versions(id) ON DELETE CASCADE,
+    FOREIGN KEY(environment_id) REFERENCES environments(id)
+);
+
+CREATE INDEX idx_deployments_service_id ON deployments(service_id, deployed_at);
+
+CREATE INDEX idx_deployments_version_id ON deployments(version_id);
+
+CREATE INDEX idx_deployments_environment_id ON deployments(environment_id);
@@ -0,0 +1,26 @@
+-- At most one rule per target environment: versions reach it only after a
+-- successful deployment to from_environment_id.
+CREATE TABLE IF NOT EXISTS promotion_rules (
+    environment_id INTEGER PRIMARY KEY,
+    from_environment_id INTEGER NOT NULL,
+    min_soak_seconds INTEGER NOT NULL DEFAULT 0,
+    required_approvals INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY(environment_id) REFERENCES environments(id) ON DELETE CASCADE,
+    FOREIGN KEY(from_environment_id) REFERENCES environments(id)
+);
+
+-- Decisions on promoting a version to an environment; each approver's
+-- latest decision replaces the earlier one.
+CREATE TABLE IF NOT EXISTS approvals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    version_id INTEGER NOT NULL,
+    environment_id INTEGER NOT NULL,
+    approver TEXT NOT NULL,
+    decision TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(version_id) REFERENCES versions(id) ON DELETE CASCADE,
+    FOREIGN KEY(environment_id) REFERENCES environments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_approvals_version_id ON approvals(version_id, environment_id);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS approval_reviews;
+
+DROP TABLE IF EXISTS approval_requests;
+
+DROP TABLE IF EXISTS approval_policies;
+
+DROP INDEX IF EXISTS idx_api_keys_name;
+
+ALTER TABLE api_keys DROP COLUMN name;
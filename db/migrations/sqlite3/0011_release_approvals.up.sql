@@ -0,0 +1,46 @@
+-- Every key names whoever uses it; approvals are recorded under that name.
+-- Existing keys are named after their ID.
+ALTER TABLE api_keys ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+UPDATE api_keys SET name = 'key-' || id;
+
+CREATE UNIQUE INDEX idx_api_keys_name ON api_keys(name);
+
+-- How many approvals releasing a version takes, for one service or for every
+-- service carrying the label label_key=label_value.
+CREATE TABLE IF NOT EXISTS approval_policies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER,
+    label_key TEXT NOT NULL DEFAULT '',
+    label_value TEXT NOT NULL DEFAULT '',
+    required_approvals INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS approval_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    version_id INTEGER NOT NULL,
+    requested_by TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    required_approvals INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    resolved_at DATETIME,
+    FOREIGN KEY(version_id) REFERENCES versions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_approval_requests_version_id ON approval_requests(version_id);
+CREATE INDEX idx_approval_requests_status ON approval_requests(status);
+
+CREATE TABLE IF NOT EXISTS approval_reviews (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id INTEGER NOT NULL,
+    reviewer TEXT NOT NULL,
+    decision TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(request_id) REFERENCES approval_requests(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_approval_reviews_request_id ON approval_reviews(request_id);
@@ -0,0 +1,6 @@
+-- A service has each semantic version once, however it is spelled: v1.0.0
+-- and 1.0.0 are the same version. Build metadata does not count, and rows
+-- that are not semantic versions keep NULLs, so only the unique index on
+-- the version string covers them. Existing duplicates must be resolved
+-- before this migration can apply.
+CREATE UNIQUE INDEX idx_versions_service_semver ON versions(service_id, major, minor, patch, prerelease);
@@ -0,0 +1,119 @@
+// Package graph renders the service dependency graph as Graphviz DOT,
+// Mermaid or a JSON node/edge document.
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Node is a service, annotated with its latest version and labels.
+type Node struct {
+	ID            int64             `json:"id"`
+	Name          string            `json:"name"`
+	LatestVersion string            `json:"latestVersion,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// Edge points from a service to one it depends on.
+type Edge struct {
+	From       int64  `json:"from"`
+	To         int64  `json:"to"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// Graph is also the JSON format.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Keep drops the nodes not in ids and every edge that touches one.
+func (g *Graph) Keep(ids map[int64]bool) {
+	nodes := g.Nodes[:0]
+	for _, n := range g.Nodes {
+		if ids[n.ID] {
+			nodes = append(nodes, n)
+		}
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if ids[e.From] && ids[e.To] {
+			edges = append(edges, e)
+		}
+	}
+	g.Nodes, g.Edges = nodes, edges
+}
+
+// DOT renders the graph for Graphviz, e.g. `dot -Tsvg`.
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph catalog {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "  s%d [label=\"%s\"];\n", n.ID, dotEscape(strings.Join(n.lines(), "\n")))
+	}
+	for _, e := range g.Edges {
+		if e.Constraint != "" {
+			fmt.Fprintf(&b, "  s%d -> s%d [label=\"%s\"];\n", e.From, e.To, dotEscape(e.Constraint))
+		} else {
+			fmt.Fprintf(&b, "  s%d -> s%d;\n", e.From, e.To)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart, which Markdown
+// renderers such as GitHub's display in a ```mermaid block.
+func (g *Graph) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for _, n := range g.Nodes {
+		lines := n.lines()
+		for i, l := range lines {
+			lines[i] = mermaidEscape(l)
+		}
+		fmt.Fprintf(&b, "  s%d[\"%s\"]\n", n.ID, strings.Join(lines, "<br/>"))
+	}
+	for _, e := range g.Edges {
+		if e.Constraint != "" {
+			fmt.Fprintf(&b, "  s%d -->|\"%s\"| s%d\n", e.From, mermaidEscape(e.Constraint), e.To)
+		} else {
+			fmt.Fprintf(&b, "  s%d --> s%d\n", e.From, e.To)
+		}
+	}
+	return b.String()
+}
+
+// lines is the text of a node: name, latest version, then labels sorted by
+// key.
+func (n Node) lines() []string {
+	lines := []string{n.Name}
+	if n.LatestVersion != "" {
+		lines = append(lines, n.LatestVersion)
+	}
+	keys := make([]string, 0, len(n.Labels))
+	for k := range n.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		lines = append(lines, k+"="+n.Labels[k])
+	}
+	return lines
+}
+
+var dotReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotEscape(s string) string {
+	return dotReplacer.Replace(s)
+}
+
+var mermaidReplacer = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+func mermaidEscape(s string) string {
+	return mermaidReplacer.Replace(s)
+}
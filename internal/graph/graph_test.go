@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sample() *Graph {
+	return &Graph{
+		Nodes: []Node{
+			{ID: 1, Name: "web", LatestVersion: "v2.0.0", Labels: map[string]string{"tier": "1", "lang": "go"}},
+			{ID: 2, Name: `say "hi"`},
+			{ID: 3, Name: "ledger"},
+		},
+		Edges: []Edge{
+			{From: 1, To: 2, Constraint: ">=1.0.0 <2.0.0"},
+			{From: 2, To: 3},
+		},
+	}
+}
+
+func TestDOT(t *testing.T) {
+	assert.Equal(t, `digraph catalog {
+  rankdir=LR;
+  node [shape=box];
+  s1 [label="web\nv2.0.0\nlang=go\ntier=1"];
+  s2 [label="say \"hi\""];
+  s3 [label="ledger"];
+  s1 -> s2 [label=">=1.0.0 <2.0.0"];
+  s2 -> s3;
+}
+`, sample().DOT())
+}
+
+func TestMermaid(t *testing.T) {
+	assert.Equal(t, `graph LR
+  s1["web<br/>v2.0.0<br/>lang=go<br/>tier=1"]
+  s2["say #quot;hi#quot;"]
+  s3["ledger"]
+  s1 -->|"#gt;=1.0.0 #lt;2.0.0"| s2
+  s2 --> s3
+`, sample().Mermaid())
+}
+
+func TestKeep(t *testing.T) {
+	g := sample()
+	g.Keep(map[int64]bool{1: true, 2: true})
+	assert.Len(t, g.Nodes, 2)
+	assert.Equal(t, []Edge{{From: 1, To: 2, Constraint: ">=1.0.0 <2.0.0"}}, g.Edges)
+}
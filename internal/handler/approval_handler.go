@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type ApprovalHandler struct {
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
+}
+
+func NewApprovalHandler(store storage.Storage, logger *zap.SugaredLogger) *ApprovalHandler {
+	return &ApprovalHandler{
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
+	}
+}
+
+// GET /approval-policies
+func (h *ApprovalHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.Store.ListApprovalPolicies(r.Context())
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Approval policy not found", "Failed to fetch approval policies")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, policies, "")
+}
+
+// POST /approval-policies
+func (h *ApprovalHandler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var input approvalPolicyInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	p := model.ApprovalPolicy{
+		LabelKey:          input.LabelKey,
+		LabelValue:        input.LabelValue,
+		RequiredApprovals: input.RequiredApprovals,
+	}
+	if input.ServiceID != 0 {
+		p.ServiceID = &input.ServiceID
+	}
+	var created *model.ApprovalPolicy
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		id, err := tx.CreateApprovalPolicy(ctx, &p)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetApprovalPolicy(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Approval policy not found", "Failed to create approval policy")
+		return
+	}
+	h.Logger.Infow("Approval policy created", "policy_id", created.ID, "required_approvals", created.RequiredApprovals)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// DELETE /approval-policies/{id}
+func (h *ApprovalHandler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "approval policy")
+	if !ok {
+		return
+	}
+	if err := h.Store.DeleteApprovalPolicy(r.Context(), id); err != nil {
+		writeStoreError(w, r, h.Logger, err, "Approval policy not found", "Failed to delete approval policy")
+		return
+	}
+	h.Logger.Infow("Approval policy deleted", "policy_id", id)
+	utils.WriteJSON(w, http.StatusOK, "approval policy deleted successfully", "")
+}
+
+// POST /versions/{id}/approval-requests
+func (h *ApprovalHandler) RequestApproval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	versionID, ok := h.pathID(w, r, "version")
+	if !ok {
+		return
+	}
+	requester, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	var input reviewInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	req := model.ApprovalRequest{VersionID: versionID, RequestedBy: requester, Comment: input.Comment}
+	var created *model.ApprovalRequest
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		id, err := tx.CreateApprovalRequest(ctx, &req)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetApprovalRequest(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to request approval")
+		return
+	}
+	h.Logger.Infow("Approval requested", "request_id", created.ID, "version_id", versionID, "requested_by", requester)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// GET /versions/{id}/approval-requests
+func (h *ApprovalHandler) ListVersionRequests(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	versionID, ok := h.pathID(w, r, "version")
+	if !ok {
+		return
+	}
+
+	var requests []model.ApprovalRequest
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.GetVersionByID(ctx, versionID); err != nil {
+			return err
+		}
+		var err error
+		requests, err = tx.ListApprovalRequests(ctx, storage.ApprovalRequestFilter{VersionID: versionID}, 1, maxApprovalRequests)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to fetch approval requests")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, requests, "")
+}
+
+// maxApprovalRequests bounds the history listed for a single version.
+const maxApprovalRequests = 100
+
+// GET /approval-requests?status=pending&service=3&page=1&limit=20
+//
+// status defaults to pending, so the default listing is the review queue.
+func (h *ApprovalHandler) ListRequests(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := storage.ApprovalRequestFilter{Status: model.ApprovalRequestPending}
+	if raw := query.Get("status"); raw != "" {
+		filter.Status = model.ApprovalRequestStatus(raw)
+		if !validRequestStatus(filter.Status) {
+			utils.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid status %q", raw))
+			return
+		}
+	}
+	if raw := query.Get("service"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+			return
+		}
+		filter.ServiceID = id
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	requests, err := h.Store.ListApprovalRequests(r.Context(), filter, page, limit)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Approval request not found", "Failed to fetch approval requests")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, requests, "")
+}
+
+// GET /approval-requests/{id}
+func (h *ApprovalHandler) GetRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "approval request")
+	if !ok {
+		return
+	}
+	req, err := h.Store.GetApprovalRequest(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Approval request not found", "Failed to fetch approval request")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, req, "")
+}
+
+// POST /approval-requests/{id}/approve
+func (h *ApprovalHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, model.ApprovalApproved)
+}
+
+// POST /approval-requests/{id}/reject
+func (h *ApprovalHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, model.ApprovalRejected)
+}
+
+// review records the caller's decision and returns the request as it now
+// stands.
+func (h *ApprovalHandler) review(w http.ResponseWriter, r *http.Request, decision model.ApprovalDecision) {
+	ctx := r.Context()
+	id, ok := h.pathID(w, r, "approval request")
+	if !ok {
+		return
+	}
+	reviewer, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	override, ok := freezeOverride(w, r)
+	if !ok {
+		return
+	}
+	var input reviewInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	review := model.ApprovalReview{Reviewer: reviewer, Decision: decision, Comment: input.Comment}
+	var updated *model.ApprovalRequest
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.ReviewApprovalRequest(ctx, id, &review); err != nil {
+			return err
+		}
+		var err error
+		if updated, err = tx.GetApprovalRequest(ctx, id); err != nil {
+			return err
+		}
+		// The approval that completes the request releases the version,
+		// which a freeze forbids.
+		if updated.Status == model.ApprovalRequestApproved {
+			return checkFreeze(ctx, tx, h.Logger, updated.ServiceID, override)
+		}
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Approval request not found", "Failed to review approval request")
+		return
+	}
+	h.Logger.Infow("Approval request reviewed", "request_id", id, "reviewer", reviewer, "decision", decision, "status", updated.Status)
+	utils.WriteJSON(w, http.StatusOK, updated, "")
+}
+
+func validRequestStatus(s model.ApprovalRequestStatus) bool {
+	for _, known := range model.ApprovalRequestStatuses {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
+// pathID parses the {id} route variable; entity names it in errors.
+func (h *ApprovalHandler) pathID(w http.ResponseWriter, r *http.Request, entity string) (int64, bool) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid "+entity+" ID", "id", idStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid "+entity+" ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"go.uber.org/zap"
+)
+
+type AuditHandler struct {
+	Store  storage.Storage
+	Logger *zap.SugaredLogger
+}
+
+func NewAuditHandler(store storage.Storage, logger *zap.SugaredLogger) *AuditHandler {
+	return &AuditHandler{
+		Store:  store,
+		Logger: logger,
+	}
+}
+
+// GET /audit?entity=service:42&actor=ana&since=2026-01-02T15:04:05Z&page=1&limit=20
+//
+// entity is a type such as service, optionally with the record's ID after
+// a colon. Events are listed oldest first.
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter storage.AuditFilter
+	if raw := query.Get("entity"); raw != "" {
+		entity, idStr, hasID := strings.Cut(raw, ":")
+		if entity == "" {
+			utils.WriteError(w, r, http.StatusBadRequest, "Invalid entity: expected a type such as service, or service:42")
+			return
+		}
+		filter.EntityType = entity
+		if hasID {
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil || id < 1 {
+				utils.WriteError(w, r, http.StatusBadRequest, "Invalid entity: expected a type such as service, or service:42")
+				return
+			}
+			filter.EntityID = id
+		}
+	}
+	filter.Actor = query.Get("actor")
+	if raw := query.Get("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, "Invalid since: expected an RFC 3339 time such as 2026-01-02T15:04:05Z")
+			return
+		}
+		filter.Since = since
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	events, err := h.Store.ListAuditEvents(r.Context(), filter, page, limit)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Audit event not found", "Failed to fetch audit events")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, events, "")
+}
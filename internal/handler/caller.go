@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/codecrafted007/service-catalog-api/internal/middleware"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+)
+
+// caller names whoever made the request: the name of its API key. It
+// writes a 401 and returns false when the request was not authenticated.
+func caller(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := middleware.APIKeyFromContext(r.Context())
+	if key == nil {
+		utils.WriteError(w, r, http.StatusUnauthorized, "API key is missing")
+		return "", false
+	}
+	return key.Name, true
+}
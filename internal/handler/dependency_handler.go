@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type DependencyHandler struct {
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
+}
+
+func NewDependencyHandler(store storage.Storage, logger *zap.SugaredLogger) *DependencyHandler {
+	return &DependencyHandler{
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
+	}
+}
+
+// POST /services/{id}/dependencies
+func (h *DependencyHandler) AddDependency(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceID, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var input dependencyInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	dep := model.Dependency{ServiceID: serviceID, DependsOnID: input.DependsOnID, Constraint: input.Constraint}
+	var created *model.Dependency
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		id, err := tx.AddDependency(ctx, &dep)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetDependency(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to add dependency")
+		return
+	}
+	h.Logger.Infow("Dependency added", "service_id", serviceID, "depends_on_id", created.DependsOnID)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// DELETE /services/{id}/dependencies/{dependsOnId}
+func (h *DependencyHandler) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceID, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	dependsOnID, ok := h.pathID(w, r, "dependsOnId")
+	if !ok {
+		return
+	}
+	if err := h.Store.RemoveDependency(ctx, serviceID, dependsOnID); err != nil {
+		writeStoreError(w, r, h.Logger, err, "Dependency not found", "Failed to remove dependency")
+		return
+	}
+	h.Logger.Infow("Dependency removed", "service_id", serviceID, "depends_on_id", dependsOnID)
+	utils.WriteJSON(w, http.StatusOK, "dependency removed successfully", "")
+}
+
+// GET /services/{id}/dependencies?depth=1
+//
+// Lists the services this one calls, directly (depth 1, the default) or
+// transitively up to depth levels; depth=0 walks the whole graph.
+func (h *DependencyHandler) ListDependencies(w http.ResponseWriter, r *http.Request) {
+	h.walk(w, r, h.Store.ListDependencies, "Failed to fetch dependencies")
+}
+
+// GET /services/{id}/dependents?depth=1
+//
+// Lists the services that call this one, with the same depth rules as
+// ListDependencies.
+func (h *DependencyHandler) ListDependents(w http.ResponseWriter, r *http.Request) {
+	h.walk(w, r, h.Store.ListDependents, "Failed to fetch dependents")
+}
+
+func (h *DependencyHandler) walk(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, serviceID int64, depth int) ([]model.DependencyNode, error), failureMsg string) {
+	serviceID, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	depth, ok := queryDepth(w, r, 1)
+	if !ok {
+		return
+	}
+	nodes, err := list(r.Context(), serviceID, depth)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", failureMsg)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, nodes, "")
+}
+
+// queryDepth reads ?depth=, or returns def when it is absent; 0 means no
+// limit.
+func queryDepth(w http.ResponseWriter, r *http.Request, def int) (int, bool) {
+	raw := r.URL.Query().Get("depth")
+	if raw == "" {
+		return def, true
+	}
+	depth, err := strconv.Atoi(raw)
+	if err != nil || depth < 0 {
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid depth")
+		return 0, false
+	}
+	return depth, true
+}
+
+func (h *DependencyHandler) pathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	idStr := mux.Vars(r)[name]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid service ID", name, idStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type DeploymentHandler struct {
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
+}
+
+func NewDeploymentHandler(store storage.Storage, logger *zap.SugaredLogger) *DeploymentHandler {
+	return &DeploymentHandler{
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
+	}
+}
+
+// GET /environments
+func (h *DeploymentHandler) ListEnvironments(w http.ResponseWriter, r *http.Request) {
+	envs, err := h.Store.ListEnvironments(r.Context())
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Environment not found", "Failed to fetch environments")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, envs, "")
+}
+
+// POST /environments
+func (h *DeploymentHandler) CreateEnvironment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var input environmentInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	env := model.Environment{Name: input.Name, Description: input.Description}
+	var created *model.Environment
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		id, err := tx.CreateEnvironment(ctx, &env)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetEnvironment(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Environment not found", "Failed to create environment")
+		return
+	}
+	h.Logger.Infow("Environment created successfully", "environment_id", created.ID)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// DELETE /environments/{id}
+func (h *DeploymentHandler) DeleteEnvironment(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid environment ID", "environment_id", idStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid environment ID")
+		return
+	}
+	if err := h.Store.DeleteEnvironment(r.Context(), id); err != nil {
+		writeStoreError(w, r, h.Logger, err, "Environment not found", "Failed to delete environment")
+		return
+	}
+	h.Logger.Infow("Environment deleted successfully", "environment_id", id)
+	utils.WriteJSON(w, http.StatusOK, "environment deleted successfully", "")
+}
+
+// POST /services/{id}/deployments
+//
+// A deployment to an environment with a promotion rule must pass its gates,
+// as a promotion does, or gets a 409 naming the gate that failed.
+func (h *DeploymentHandler) CreateDeployment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceID, ok := h.serviceID(w, r)
+	if !ok {
+		return
+	}
+	actor, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	override, ok := freezeOverride(w, r)
+	if !ok {
+		return
+	}
+	var input deploymentInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	d := model.Deployment{
+		ServiceID:   serviceID,
+		Version:     input.Version,
+		Environment: input.Environment,
+		Status:      model.DeploymentStatus(input.Status),
+		Actor:       actor,
+		TriggeredBy: input.TriggeredBy,
+		DeployedAt:  time.Now().UTC(),
+	}
+	if d.Status == "" {
+		d.Status = model.DeploymentSucceeded
+	}
+
+	var created *model.Deployment
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if err := checkFreeze(ctx, tx, h.Logger, serviceID, override); err != nil {
+			return err
+		}
+		id, err := tx.CreateDeployment(ctx, &d)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetDeployment(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to record deployment")
+		return
+	}
+	h.Logger.Infow("Deployment recorded", "service_id", serviceID, "version", created.Version, "environment", created.Environment, "status", created.Status)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// GET /services/{id}/deployments?environment=prod&page=1&limit=20
+func (h *DeploymentHandler) ListDeployments(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := h.serviceID(w, r)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	deployments, err := h.Store.ListDeployments(r.Context(), serviceID, r.URL.Query().Get("environment"), page, limit)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to fetch deployments")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, deployments, "")
+}
+
+// GET /services/{id}/environments
+func (h *DeploymentHandler) ServiceEnvironments(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := h.serviceID(w, r)
+	if !ok {
+		return
+	}
+	statuses, err := h.Store.ServiceEnvironments(r.Context(), serviceID)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to fetch environments")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, statuses, "")
+}
+
+func (h *DeploymentHandler) serviceID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid service ID", "service_id", idStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"go.uber.org/zap"
+)
+
+// writeStoreError is the single place storage errors become HTTP responses.
+// notFoundMsg is sent for storage.ErrNotFound; errors without a known kind
+// are logged and reported as failureMsg with a 500.
+func writeStoreError(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, err error, notFoundMsg, failureMsg string) {
+	status, msg := storeErrorStatus(err)
+	switch status {
+	case http.StatusInternalServerError:
+		logger.Errorw(failureMsg, "error", err)
+		msg = failureMsg
+	case http.StatusNotFound:
+		logger.Warnw(notFoundMsg, "error", err)
+		msg = notFoundMsg
+	default:
+		logger.Warnw(msg, "error", err)
+	}
+
+	// Conflicts point at the record that already exists so clients can
+	// treat a re-run as success.
+	var ce *storage.ConflictError
+	if errors.As(err, &ce) && ce.ExistingID != 0 {
+		utils.WriteErrorDetails(w, r, status, msg, map[string]interface{}{"existingId": ce.ExistingID})
+		return
+	}
+	// Refused promotions name the gate so clients can tell a missing
+	// approval from a deployment that has not soaked yet.
+	var ge *storage.GateError
+	if errors.As(err, &ge) {
+		utils.WriteErrorDetails(w, r, status, msg, map[string]interface{}{"gate": ge.Gate})
+		return
+	}
+	utils.WriteError(w, r, status, msg)
+}
+
+// storeErrorStatus maps an error to its HTTP status and client-safe message.
+func storeErrorStatus(err error) (int, string) {
+	var status int
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrInvalid):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, storage.ErrPreconditionFailed):
+		status = http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError, ""
+	}
+
+	var se *storage.Error
+	if errors.As(err, &se) {
+		return status, se.Msg
+	}
+	var ce *storage.ConflictError
+	if errors.As(err, &ce) {
+		return status, ce.Entity + " already exists"
+	}
+	return status, err.Error()
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+)
+
+// etag renders a stored revision as a strong entity tag.
+func etag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// parseETag returns the revision in a strong entity tag, or -1 for tags we
+// never issue (including weak ones), which therefore cannot match.
+func parseETag(tag string) int64 {
+	tag = strings.TrimSpace(tag)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return -1
+	}
+	revision, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || revision <= 0 {
+		return -1
+	}
+	return revision
+}
+
+// requiredRevision reads If-Match for a storage write: 0 when the header is
+// absent or "*", otherwise the revision the stored record must have. It
+// writes a 400 itself and returns false for a list of tags, which the
+// storage layer cannot check atomically.
+func requiredRevision(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	if strings.Contains(header, ",") {
+		utils.WriteError(w, r, http.StatusBadRequest, "If-Match must be a single entity tag")
+		return 0, false
+	}
+	return parseETag(header), true
+}
+
+// notModified sets the ETag for revision and, when If-None-Match already
+// names it, answers 304 and returns true.
+func notModified(w http.ResponseWriter, r *http.Request, revision int64) bool {
+	w.Header().Set("ETag", etag(revision))
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		// If-None-Match uses the weak comparison.
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || parseETag(tag) == revision {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
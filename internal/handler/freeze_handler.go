@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/internal/labels"
+	"github.com/codecrafted007/service-catalog-api/internal/middleware"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// freezeOverrideHeader carries the reason for releasing, promoting or
+// deploying during a freeze. Only elevated API keys may send it.
+const freezeOverrideHeader = "X-Freeze-Override"
+
+// errLiftFreeze aborts deleting a freeze the caller's key may not lift.
+var errLiftFreeze = errors.New("lifting a freeze requires an elevated key")
+
+type FreezeHandler struct {
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
+}
+
+func NewFreezeHandler(store storage.Storage, logger *zap.SugaredLogger) *FreezeHandler {
+	return &FreezeHandler{
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
+	}
+}
+
+// GET /freezes
+func (h *FreezeHandler) ListFreezes(w http.ResponseWriter, r *http.Request) {
+	freezes, err := h.Store.ListFreezes(r.Context())
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Freeze not found", "Failed to fetch freezes")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, freezes, "")
+}
+
+// GET /freezes/active?service=
+//
+// Lists the freezes in force now, only those covering the service when one
+// is given, so CI can check before it builds.
+func (h *FreezeHandler) ActiveFreezes(w http.ResponseWriter, r *http.Request) {
+	var serviceID int64
+	if s := r.URL.Query().Get("service"); s != "" {
+		var err error
+		if serviceID, err = strconv.ParseInt(s, 10, 64); err != nil || serviceID < 1 {
+			utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+			return
+		}
+	}
+	freezes, err := h.Store.ActiveFreezes(r.Context(), serviceID, time.Now())
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to fetch active freezes")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, freezes, "")
+}
+
+// POST /freezes
+func (h *FreezeHandler) CreateFreeze(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	creator, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	var input freezeInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	f := model.Freeze{
+		StartsAt:  *input.StartsAt,
+		EndsAt:    *input.EndsAt,
+		Reason:    input.Reason,
+		CreatedBy: creator,
+	}
+	if input.Selector != "" {
+		// Parsed by the input rules already; store it in canonical form.
+		sel, _ := labels.Parse(input.Selector)
+		f.Selector = sel.String()
+	}
+	if input.TeamID != 0 {
+		f.TeamID = &input.TeamID
+	}
+	var created *model.Freeze
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		id, err := tx.CreateFreeze(ctx, &f)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetFreeze(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Freeze not found", "Failed to create freeze")
+		return
+	}
+	h.Logger.Infow("Freeze created", "freeze_id", created.ID, "starts_at", created.StartsAt, "ends_at", created.EndsAt, "created_by", creator)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// DELETE /freezes/{id}
+//
+// Lifting a freeze that is in force or still to come takes an elevated key,
+// like overriding it; any key may clear away one that has ended.
+func (h *FreezeHandler) DeleteFreeze(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid freeze ID", "freeze_id", idStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid freeze ID")
+		return
+	}
+	err = h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		f, err := tx.GetFreeze(ctx, id)
+		if err != nil {
+			return err
+		}
+		if f.EndsAt.After(time.Now()) && !elevated(r) {
+			return errLiftFreeze
+		}
+		return tx.DeleteFreeze(ctx, id)
+	})
+	if errors.Is(err, errLiftFreeze) {
+		utils.WriteError(w, r, http.StatusForbidden, "Deleting a freeze that has not ended requires an elevated API key")
+		return
+	} else if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Freeze not found", "Failed to delete freeze")
+		return
+	}
+	h.Logger.Infow("Freeze deleted", "freeze_id", id)
+	utils.WriteJSON(w, http.StatusOK, "freeze deleted successfully", "")
+}
+
+// freezeOverride reads the reason a caller gives for changing a frozen
+// service, or "" when it gives none. Only elevated keys may give one; it
+// writes a 403 and returns false for any other.
+func freezeOverride(w http.ResponseWriter, r *http.Request) (string, bool) {
+	reason := strings.TrimSpace(r.Header.Get(freezeOverrideHeader))
+	if reason == "" {
+		return "", true
+	}
+	if !elevated(r) {
+		utils.WriteError(w, r, http.StatusForbidden, "Overriding a freeze requires an elevated API key")
+		return "", false
+	}
+	return reason, true
+}
+
+// elevated reports whether the request was made with an elevated API key.
+func elevated(r *http.Request) bool {
+	key := middleware.APIKeyFromContext(r.Context())
+	return key != nil && key.Elevated
+}
+
+// checkFreeze refuses a release, promotion or deployment of a service
+// while a freeze covers it, with a *storage.GateError naming the freeze.
+// An override reason from freezeOverride lets the change through, and is
+// logged and audited.
+func checkFreeze(ctx context.Context, tx storage.Storage, logger *zap.SugaredLogger, serviceID int64, override string) error {
+	freezes, err := tx.ActiveFreezes(ctx, serviceID, time.Now())
+	if err != nil || len(freezes) == 0 {
+		return err
+	}
+	return overrideFreeze(ctx, tx, logger, freezes[0], serviceID, override, "changes are frozen")
+}
+
+// keepFrozen runs change, a write to a service's labels, tags or owners,
+// and refuses it with a *storage.GateError if it takes the service out of a
+// freeze that covered it, so a freeze cannot be escaped by relabelling. An
+// override reason lets it through like checkFreeze.
+func keepFrozen(ctx context.Context, tx storage.Storage, logger *zap.SugaredLogger, serviceID int64, override string, change func() error) error {
+	now := time.Now()
+	before, err := tx.ActiveFreezes(ctx, serviceID, now)
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	if len(before) == 0 {
+		return nil
+	}
+	after, err := tx.ActiveFreezes(ctx, serviceID, now)
+	if err != nil {
+		return err
+	}
+	covered := make(map[int64]bool, len(after))
+	for _, f := range after {
+		covered[f.ID] = true
+	}
+	for _, f := range before {
+		if !covered[f.ID] {
+			return overrideFreeze(ctx, tx, logger, f, serviceID, override, "the service cannot leave a freeze")
+		}
+	}
+	return nil
+}
+
+// overrideFreeze lets a change to a service through freeze f if the caller
+// gave an override reason, logging it and recording it in the audit log, and
+// otherwise refuses it with a freeze *storage.GateError starting with msg.
+func overrideFreeze(ctx context.Context, tx storage.Storage, logger *zap.SugaredLogger, f model.Freeze, serviceID int64, override, msg string) error {
+	if override == "" {
+		return storage.NewGateError("freeze", fmt.Sprintf("%s until %s: %s", msg, f.EndsAt.Format(time.RFC3339), f.Reason))
+	}
+	logger.Warnw("Freeze overridden", "freeze_id", f.ID, "service_id", serviceID,
+		"key", middleware.APIKeyFromContext(ctx).Name, "reason", override)
+	return storage.RecordFreezeOverride(ctx, tx, f, serviceID, override)
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/codecrafted007/service-catalog-api/internal/graph"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"go.uber.org/zap"
+)
+
+type GraphHandler struct {
+	Store  storage.Storage
+	Logger *zap.SugaredLogger
+}
+
+func NewGraphHandler(store storage.Storage, logger *zap.SugaredLogger) *GraphHandler {
+	return &GraphHandler{
+		Store:  store,
+		Logger: logger,
+	}
+}
+
+// graphPageSize is how many services GetGraph loads per ListServices call.
+const graphPageSize = 100
+
+// GET /graph?format=dot&root=1&depth=2&direction=both
+//
+// Renders the dependency graph of the whole catalog, or of the services
+// reachable from root within depth levels (0, the default, for all of them)
+// following its dependencies, its dependents or both.
+func (h *GraphHandler) GetGraph(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	switch format {
+	case "":
+		format = "json"
+	case "json", "dot", "mermaid":
+	default:
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid format; use json, dot or mermaid")
+		return
+	}
+	direction := query.Get("direction")
+	switch direction {
+	case "":
+		direction = "dependencies"
+	case "dependencies", "dependents", "both":
+	default:
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid direction; use dependencies, dependents or both")
+		return
+	}
+	depth, ok := queryDepth(w, r, 0)
+	if !ok {
+		return
+	}
+
+	// Pick the services first, so a missing root is a 404 before anything
+	// else is loaded.
+	var keep map[int64]bool
+	if raw := query.Get("root"); raw != "" {
+		root, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, "Invalid root service ID")
+			return
+		}
+		keep = map[int64]bool{root: true}
+		var walks []func(ctx context.Context, serviceID int64, depth int) ([]model.DependencyNode, error)
+		if direction != "dependents" {
+			walks = append(walks, h.Store.ListDependencies)
+		}
+		if direction != "dependencies" {
+			walks = append(walks, h.Store.ListDependents)
+		}
+		for _, walk := range walks {
+			nodes, err := walk(ctx, root, depth)
+			if err != nil {
+				writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to build graph")
+				return
+			}
+			for _, n := range nodes {
+				keep[n.ServiceID] = true
+			}
+		}
+	}
+
+	g, err := h.load(r, keep)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to build graph")
+		return
+	}
+
+	switch format {
+	case "dot":
+		writeText(w, "text/vnd.graphviz; charset=utf-8", g.DOT())
+	case "mermaid":
+		writeText(w, "text/plain; charset=utf-8", g.Mermaid())
+	default:
+		utils.WriteJSON(w, http.StatusOK, g, "")
+	}
+}
+
+// load builds the graph of the services in keep, or of every service when
+// keep is nil.
+func (h *GraphHandler) load(r *http.Request, keep map[int64]bool) (*graph.Graph, error) {
+	ctx := r.Context()
+	g := &graph.Graph{Nodes: []graph.Node{}, Edges: []graph.Edge{}}
+	for page := 1; ; page++ {
+		services, err := h.Store.ListServices(ctx, storage.ServiceFilter{}, "name", page, graphPageSize)
+		if err != nil {
+			return nil, err
+		}
+		var nodes []graph.Node
+		var ids []int64
+		for _, svc := range services {
+			id := int64(svc.ID)
+			if keep != nil && !keep[id] {
+				continue
+			}
+			nodes = append(nodes, graph.Node{ID: id, Name: svc.Name, Labels: svc.Labels})
+			ids = append(ids, id)
+		}
+		// The node shows the latest release, as GET
+		// /services/{id}/versions/latest does by default.
+		latest, err := h.Store.LatestVersions(ctx, ids, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			if v := latest[node.ID]; v != nil {
+				node.LatestVersion = v.Version
+			}
+			g.Nodes = append(g.Nodes, node)
+		}
+		if len(services) < graphPageSize {
+			break
+		}
+	}
+
+	deps, err := h.Store.ListAllDependencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int64]bool, len(g.Nodes))
+	for _, n := range g.Nodes {
+		ids[n.ID] = true
+	}
+	for _, d := range deps {
+		g.Edges = append(g.Edges, graph.Edge{From: d.ServiceID, To: d.DependsOnID, Constraint: d.Constraint})
+	}
+	g.Keep(ids)
+	return g, nil
+}
+
+func writeText(w http.ResponseWriter, contentType, body string) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, body)
+}
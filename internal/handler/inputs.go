@@ -0,0 +1,553 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/internal/labels"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"go.uber.org/zap"
+)
+
+// validatable is implemented by request payloads that declare their rules.
+type validatable interface {
+	rules(v *validation.Validator) []validation.Field
+}
+
+type createServiceInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	Changelog   string `json:"changelog,omitempty"`
+}
+
+func (in *createServiceInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("name", in.Name, validation.Required(), validation.MaxLength(v.NameMaxLength), v.NamePattern()),
+		validation.F("description", in.Description, validation.MaxLength(v.DescriptionMaxLength)),
+		validation.F("version", in.Version, validation.Required(), validation.MaxLength(v.VersionMaxLength), v.VersionFormat()),
+		validation.F("changelog", in.Changelog, validation.MaxLength(v.ChangelogMaxLength)),
+	}
+}
+
+type updateServiceInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (in *updateServiceInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("name", in.Name, validation.Required(), validation.MaxLength(v.NameMaxLength), v.NamePattern()),
+		validation.F("description", in.Description, validation.MaxLength(v.DescriptionMaxLength)),
+	}
+}
+
+type versionInput struct {
+	Version   string `json:"version"`
+	Changelog string `json:"changelog,omitempty"`
+}
+
+func (in *versionInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("version", in.Version, validation.Required(), validation.MaxLength(v.VersionMaxLength), v.VersionFormat()),
+		validation.F("changelog", in.Changelog, validation.MaxLength(v.ChangelogMaxLength)),
+	}
+}
+
+// createVersionInput adds the initial lifecycle state, which defaults to
+// released.
+type createVersionInput struct {
+	versionInput
+	State string `json:"state,omitempty"`
+}
+
+func (in *createVersionInput) rules(v *validation.Validator) []validation.Field {
+	return append(in.versionInput.rules(v),
+		validation.F("state", in.State, validation.OneOf(string(model.VersionDraft), string(model.VersionReleased))))
+}
+
+// transitionInput moves a version to another lifecycle state. Yanking must
+// say why.
+type transitionInput struct {
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (in *transitionInput) rules(v *validation.Validator) []validation.Field {
+	reason := []validation.Rule{validation.MaxLength(v.DescriptionMaxLength)}
+	if in.State == string(model.VersionYanked) {
+		reason = append([]validation.Rule{validation.Required()}, reason...)
+	}
+	return []validation.Field{
+		validation.F("state", in.State, validation.Required(),
+			validation.OneOf(string(model.VersionReleased), string(model.VersionDeprecated), string(model.VersionYanked))),
+		validation.F("reason", in.Reason, reason...),
+	}
+}
+
+// versionPatchInput is a partial update; omitted fields keep their value.
+type versionPatchInput struct {
+	Version   *string `json:"version"`
+	Changelog *string `json:"changelog"`
+}
+
+func (in *versionPatchInput) rules(v *validation.Validator) []validation.Field {
+	var fields []validation.Field
+	if in.Version != nil {
+		fields = append(fields, validation.F("version", *in.Version, validation.Required(), validation.MaxLength(v.VersionMaxLength), v.VersionFormat()))
+	}
+	if in.Changelog != nil {
+		fields = append(fields, validation.F("changelog", *in.Changelog, validation.MaxLength(v.ChangelogMaxLength)))
+	}
+	return fields
+}
+
+// apply copies the fields that were sent onto v.
+func (in *versionPatchInput) apply(v *model.Version) {
+	if in.Version != nil {
+		v.Version = *in.Version
+	}
+	if in.Changelog != nil {
+		v.Changelog = *in.Changelog
+	}
+}
+
+type teamInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Email       string `json:"email,omitempty"`
+	Chat        string `json:"chat,omitempty"`
+}
+
+func (in *teamInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("name", in.Name, validation.Required(), validation.MaxLength(v.NameMaxLength), v.NamePattern()),
+		validation.F("description", in.Description, validation.MaxLength(v.DescriptionMaxLength)),
+		validation.F("email", in.Email, validation.MaxLength(contactMaxLength), validation.Email()),
+		validation.F("chat", in.Chat, validation.MaxLength(contactMaxLength)),
+	}
+}
+
+// contactMaxLength bounds email addresses and chat handles.
+const contactMaxLength = 255
+
+// ownershipInput replaces the owners of a service.
+type ownershipInput struct {
+	TeamID   int64           `json:"teamId,omitempty"`
+	Contacts []model.Contact `json:"contacts"`
+}
+
+func (in *ownershipInput) rules(v *validation.Validator) []validation.Field {
+	var fields []validation.Field
+	for i, c := range in.Contacts {
+		prefix := fmt.Sprintf("contacts[%d]", i)
+		fields = append(fields,
+			validation.F(prefix+".name", c.Name, validation.Required(), validation.MaxLength(v.NameMaxLength)),
+			validation.F(prefix+".email", c.Email, validation.MaxLength(contactMaxLength), validation.Email()),
+			validation.F(prefix+".chat", c.Chat, validation.MaxLength(contactMaxLength)),
+			validation.F(prefix, c.Email+c.Chat, func(value string) string {
+				if value == "" {
+					return "needs an email or a chat handle"
+				}
+				return ""
+			}),
+		)
+	}
+	return fields
+}
+
+// labelInput sets one label; the key comes from the URL.
+type labelInput struct {
+	Key   string `json:"-"`
+	Value string `json:"value"`
+}
+
+func (in *labelInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("key", in.Key, labelRule(labels.ValidateKey, "must be a label key such as tier or example.com/tier")),
+		validation.F("value", in.Value, labelRule(labels.ValidateValue, "must be at most 63 letters, digits, '-', '_' or '.'")),
+	}
+}
+
+// labelRule turns one of the labels validators into a validation.Rule.
+func labelRule(check func(string) error, msg string) validation.Rule {
+	return func(value string) string {
+		if check(value) != nil {
+			return msg
+		}
+		return ""
+	}
+}
+
+// dependencyInput records that a service calls another.
+type dependencyInput struct {
+	DependsOnID int64  `json:"dependsOnId"`
+	Constraint  string `json:"constraint"`
+}
+
+func (in *dependencyInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("dependsOnId", strconv.FormatInt(in.DependsOnID, 10), func(value string) string {
+			if in.DependsOnID <= 0 {
+				return "is required"
+			}
+			return ""
+		}),
+		validation.F("constraint", in.Constraint, validation.MaxLength(constraintMaxLength), validation.SemVerConstraint()),
+	}
+}
+
+// constraintMaxLength bounds the version range pinned on a dependency.
+const constraintMaxLength = 255
+
+// environmentInput creates an environment.
+type environmentInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (in *environmentInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("name", in.Name, validation.Required(), validation.MaxLength(v.NameMaxLength), v.NamePattern()),
+		validation.F("description", in.Description, validation.MaxLength(v.DescriptionMaxLength)),
+	}
+}
+
+// deploymentInput records a deployment of one of the service's versions.
+// Status defaults to succeeded. The actor is whoever the API key belongs
+// to; TriggeredBy optionally names who started it. The deployment is dated
+// when it is recorded, never by the caller, since promotion soak times are
+// measured from it.
+type deploymentInput struct {
+	Version     string `json:"version"`
+	Environment string `json:"environment"`
+	Status      string `json:"status"`
+	TriggeredBy string `json:"triggeredBy"`
+}
+
+func (in *deploymentInput) rules(v *validation.Validator) []validation.Field {
+	statuses := make([]string, len(model.DeploymentStatuses))
+	for i, s := range model.DeploymentStatuses {
+		statuses[i] = string(s)
+	}
+	return []validation.Field{
+		validation.F("version", in.Version, validation.Required(), validation.MaxLength(v.VersionMaxLength)),
+		validation.F("environment", in.Environment, validation.Required(), validation.MaxLength(v.NameMaxLength)),
+		validation.F("status", in.Status, validation.OneOf(statuses...)),
+		validation.F("triggeredBy", in.TriggeredBy, validation.MaxLength(contactMaxLength)),
+	}
+}
+
+// promotionRuleInput sets the path into an environment.
+type promotionRuleInput struct {
+	From              string `json:"from"`
+	MinSoakSeconds    int64  `json:"minSoakSeconds"`
+	RequiredApprovals int    `json:"requiredApprovals"`
+}
+
+func (in *promotionRuleInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("from", in.From, validation.Required(), validation.MaxLength(v.NameMaxLength)),
+		validation.F("minSoakSeconds", strconv.FormatInt(in.MinSoakSeconds, 10), nonNegative(in.MinSoakSeconds)),
+		validation.F("requiredApprovals", strconv.Itoa(in.RequiredApprovals), nonNegative(int64(in.RequiredApprovals))),
+	}
+}
+
+// nonNegative rejects a negative number; rules only see its text.
+func nonNegative(n int64) validation.Rule {
+	return func(string) string {
+		if n < 0 {
+			return "must not be negative"
+		}
+		return ""
+	}
+}
+
+// approvalInput records a decision on promoting a version. The approver is
+// whoever the API key belongs to.
+type approvalInput struct {
+	Environment string `json:"environment"`
+	Decision    string `json:"decision"`
+	Comment     string `json:"comment"`
+}
+
+func (in *approvalInput) rules(v *validation.Validator) []validation.Field {
+	decisions := make([]string, len(model.ApprovalDecisions))
+	for i, d := range model.ApprovalDecisions {
+		decisions[i] = string(d)
+	}
+	return []validation.Field{
+		validation.F("environment", in.Environment, validation.Required(), validation.MaxLength(v.NameMaxLength)),
+		validation.F("decision", in.Decision, validation.Required(), validation.OneOf(decisions...)),
+		validation.F("comment", in.Comment, validation.MaxLength(v.DescriptionMaxLength)),
+	}
+}
+
+// promoteInput promotes a version to an environment. The actor is whoever
+// the API key belongs to.
+type promoteInput struct {
+	Environment string `json:"environment"`
+}
+
+func (in *promoteInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("environment", in.Environment, validation.Required(), validation.MaxLength(v.NameMaxLength)),
+	}
+}
+
+// approvalPolicyInput requires approvals for one service, or for every
+// service with a label.
+type approvalPolicyInput struct {
+	ServiceID         int64  `json:"serviceId"`
+	LabelKey          string `json:"labelKey"`
+	LabelValue        string `json:"labelValue"`
+	RequiredApprovals int    `json:"requiredApprovals"`
+}
+
+func (in *approvalPolicyInput) rules(v *validation.Validator) []validation.Field {
+	fields := []validation.Field{
+		validation.F("serviceId", strconv.FormatInt(in.ServiceID, 10), func(string) string {
+			switch {
+			case in.ServiceID == 0 && in.LabelKey == "":
+				return "is required unless labelKey is set"
+			case in.ServiceID != 0 && (in.LabelKey != "" || in.LabelValue != ""):
+				return "cannot be combined with a label"
+			}
+			return ""
+		}),
+		validation.F("requiredApprovals", strconv.Itoa(in.RequiredApprovals), func(string) string {
+			if in.RequiredApprovals < 1 {
+				return "must be at least 1"
+			}
+			return ""
+		}),
+	}
+	if in.LabelKey != "" {
+		fields = append(fields,
+			validation.F("labelKey", in.LabelKey, labelRule(labels.ValidateKey, "must be a label key such as tier or example.com/tier")),
+			validation.F("labelValue", in.LabelValue, labelRule(labels.ValidateValue, "must be at most 63 letters, digits, '-', '_' or '.'")))
+	}
+	return fields
+}
+
+// reviewInput carries the optional comment on an approval request or on a
+// decision about one.
+type reviewInput struct {
+	Comment string `json:"comment"`
+}
+
+func (in *reviewInput) rules(v *validation.Validator) []validation.Field {
+	return []validation.Field{
+		validation.F("comment", in.Comment, validation.MaxLength(v.DescriptionMaxLength)),
+	}
+}
+
+// freezeInput defines a change freeze. Selector and TeamID narrow it to
+// some services; without either it covers them all.
+type freezeInput struct {
+	StartsAt *time.Time `json:"startsAt"`
+	EndsAt   *time.Time `json:"endsAt"`
+	Selector string     `json:"selector"`
+	TeamID   int64      `json:"teamId"`
+	Reason   string     `json:"reason"`
+}
+
+func (in *freezeInput) rules(v *validation.Validator) []validation.Field {
+	given := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return []validation.Field{
+		validation.F("startsAt", given(in.StartsAt), validation.Required()),
+		validation.F("endsAt", given(in.EndsAt), validation.Required(), func(string) string {
+			if in.StartsAt != nil && in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+				return "must be after startsAt"
+			}
+			return ""
+		}),
+		validation.F("selector", in.Selector, func(s string) string {
+			if _, err := labels.Parse(s); err != nil {
+				return "must be a label selector such as tier=1,!experimental"
+			}
+			return ""
+		}),
+		validation.F("teamId", strconv.FormatInt(in.TeamID, 10), nonNegative(in.TeamID)),
+		validation.F("reason", in.Reason, validation.Required(), validation.MaxLength(v.DescriptionMaxLength)),
+	}
+}
+
+// patchedService is model.Service as a client may send it back after
+// applying a patch; only name and description are writable.
+type patchedService struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	Versions    []string          `json:"versions"`
+	Revision    int64             `json:"revision"`
+	Ownership   json.RawMessage   `json:"ownership"`
+	Labels      map[string]string `json:"labels"`
+	Tags        []string          `json:"tags"`
+}
+
+// servicePatch validates a patched service document against the stored one
+// and returns the writable fields that changed. Failures are reported as
+// validation.Errors.
+func servicePatch(current *model.Service, patched []byte, v *validation.Validator) (storage.ServicePatch, error) {
+	var p storage.ServicePatch
+	var doc patchedService
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		if strings.HasPrefix(err.Error(), "json: unknown field ") {
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			return p, validation.Errors{{Field: field, Message: "is not a known field"}}
+		}
+		return p, errNotAService
+	}
+
+	var errs validation.Errors
+	if doc.ID != current.ID {
+		errs = append(errs, validation.FieldError{Field: "id", Message: "is read-only"})
+	}
+	if !doc.CreatedAt.Equal(current.CreatedAt) {
+		errs = append(errs, validation.FieldError{Field: "createdAt", Message: "is read-only"})
+	}
+	if doc.Revision != current.Revision {
+		errs = append(errs, validation.FieldError{Field: "revision", Message: "is read-only; send If-Match instead"})
+	}
+	if owners, _ := json.Marshal(current.Ownership); !jsonEqual(doc.Ownership, owners) {
+		errs = append(errs, validation.FieldError{Field: "ownership", Message: "is read-only; use PUT /services/{id}/owners"})
+	}
+	if len(doc.Labels) != len(current.Labels) || (len(doc.Labels) > 0 && !reflect.DeepEqual(doc.Labels, current.Labels)) {
+		errs = append(errs, validation.FieldError{Field: "labels", Message: "is read-only; use the labels endpoints"})
+	}
+	if !equalStrings(doc.Tags, current.Tags) {
+		errs = append(errs, validation.FieldError{Field: "tags", Message: "is read-only; use the tags endpoints"})
+	}
+	if !equalStrings(doc.Versions, current.Versions) {
+		errs = append(errs, validation.FieldError{Field: "versions", Message: "is read-only; use the versions endpoints"})
+	}
+	in := updateServiceInput{Name: doc.Name, Description: doc.Description}
+	errs = append(errs, validation.Check(in.rules(v)...)...)
+	if len(errs) > 0 {
+		return p, errs
+	}
+
+	if doc.Name != current.Name {
+		p.Name = &doc.Name
+	}
+	if doc.Description != current.Description {
+		p.Description = &doc.Description
+	}
+	return p, nil
+}
+
+// errNotAService means a patch turned the service into something that is not
+// a JSON object with the service's fields.
+var errNotAService = errors.New("patched document is not a service object")
+
+// jsonEqual compares two JSON documents by value; a missing document equals
+// null.
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if len(a) > 0 && json.Unmarshal(a, &va) != nil {
+		return false
+	}
+	if len(b) > 0 && json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// equalStrings treats nil and empty slices as equal.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readBody reads a size-limited raw body, writing the error response itself
+// and returning false on failure.
+func readBody(w http.ResponseWriter, r *http.Request, v *validation.Validator, logger *zap.SugaredLogger) ([]byte, bool) {
+	body := r.Body
+	if v.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, v.MaxBodyBytes)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		logger.Warnw("failed to read body", "error", err)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			utils.WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", v.MaxBodyBytes))
+		} else {
+			utils.WriteError(w, r, http.StatusBadRequest, "Invalid input")
+		}
+		return nil, false
+	}
+	return content, true
+}
+
+// writeValidationErrors reports field errors as a 422.
+func writeValidationErrors(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, errs validation.Errors) {
+	logger.Warnw("input failed validation", "errors", errs.Error())
+	fields := make([]utils.FieldError, len(errs))
+	for i, fe := range errs {
+		fields[i] = utils.FieldError{Field: fe.Field, Message: fe.Message}
+	}
+	utils.WriteFieldErrors(w, r, http.StatusUnprocessableEntity, "Validation failed", fields)
+}
+
+// decodeInput reads a size-limited JSON body into dst, rejecting unknown
+// fields, and validates it. On failure it writes the response itself and
+// returns false.
+func decodeInput(w http.ResponseWriter, r *http.Request, v *validation.Validator, logger *zap.SugaredLogger, dst validatable) bool {
+	body := r.Body
+	if v.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, v.MaxBodyBytes)
+	}
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		logger.Warnw("invalid input", "error", err)
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			utils.WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", v.MaxBodyBytes))
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			// encoding/json has no typed error for unknown fields.
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			utils.WriteFieldErrors(w, r, http.StatusUnprocessableEntity, "Validation failed",
+				[]utils.FieldError{{Field: field, Message: "is not a known field"}})
+		default:
+			utils.WriteError(w, r, http.StatusBadRequest, "Invalid input")
+		}
+		return false
+	}
+
+	if errs := validation.Check(dst.rules(v)...); len(errs) > 0 {
+		writeValidationErrors(w, r, logger, errs)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/codecrafted007/service-catalog-api/internal/labels"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// LabelHandler serves the labels and tags of a service. Each write changes
+// the service's revision, so If-Match takes the service ETag, and each
+// responds with the updated service. A write that would take the service out
+// of a freeze needs a freeze override.
+type LabelHandler struct {
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
+}
+
+func NewLabelHandler(store storage.Storage, logger *zap.SugaredLogger) *LabelHandler {
+	return &LabelHandler{
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
+	}
+}
+
+// PUT /services/{id}/labels/{key}
+func (h *LabelHandler) SetLabel(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := h.serviceID(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	input := labelInput{Key: mux.Vars(r)["key"]}
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	h.write(w, r, serviceID, "Service not found", "Failed to set label", func(tx storage.Storage) error {
+		return tx.SetServiceLabel(r.Context(), serviceID, input.Key, input.Value, revision)
+	})
+}
+
+// DELETE /services/{id}/labels/{key}
+func (h *LabelHandler) RemoveLabel(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := h.serviceID(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	key := mux.Vars(r)["key"]
+
+	h.write(w, r, serviceID, "Service or label not found", "Failed to remove label", func(tx storage.Storage) error {
+		return tx.RemoveServiceLabel(r.Context(), serviceID, key, revision)
+	})
+}
+
+// PUT /services/{id}/tags/{tag}
+func (h *LabelHandler) AddTag(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := h.serviceID(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	tag := mux.Vars(r)["tag"]
+	// Tags follow the label key syntax so selectors can name them.
+	if errs := validation.Check(validation.F("tag", tag, labelRule(labels.ValidateKey, "must be a tag such as pci or example.com/pci"))); len(errs) > 0 {
+		writeValidationErrors(w, r, h.Logger, errs)
+		return
+	}
+
+	h.write(w, r, serviceID, "Service not found", "Failed to add tag", func(tx storage.Storage) error {
+		return tx.AddServiceTag(r.Context(), serviceID, tag, revision)
+	})
+}
+
+// DELETE /services/{id}/tags/{tag}
+func (h *LabelHandler) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := h.serviceID(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	tag := mux.Vars(r)["tag"]
+
+	h.write(w, r, serviceID, "Service or tag not found", "Failed to remove tag", func(tx storage.Storage) error {
+		return tx.RemoveServiceTag(r.Context(), serviceID, tag, revision)
+	})
+}
+
+// write runs change and reloads the service in one transaction, then
+// responds with the service and its new ETag. The change is refused if it
+// takes the service out of a freeze, unless the caller overrides it.
+func (h *LabelHandler) write(w http.ResponseWriter, r *http.Request, serviceID int64, notFoundMsg, failureMsg string, change func(tx storage.Storage) error) {
+	ctx := r.Context()
+	override, ok := freezeOverride(w, r)
+	if !ok {
+		return
+	}
+	var svc *model.Service
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		err := keepFrozen(ctx, tx, h.Logger, serviceID, override, func() error {
+			return change(tx)
+		})
+		if err != nil {
+			return err
+		}
+		svc, err = tx.GetServiceById(ctx, int(serviceID))
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, notFoundMsg, failureMsg)
+		return
+	}
+	h.Logger.Infow("Service labels updated", "service_id", serviceID)
+	w.Header().Set("ETag", etag(svc.Revision))
+	utils.WriteJSON(w, http.StatusOK, svc, "")
+}
+
+func (h *LabelHandler) serviceID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid service ID", "service_id", idStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type PromotionHandler struct {
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
+}
+
+func NewPromotionHandler(store storage.Storage, logger *zap.SugaredLogger) *PromotionHandler {
+	return &PromotionHandler{
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
+	}
+}
+
+// GET /promotion-rules
+func (h *PromotionHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.Store.ListPromotionRules(r.Context())
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Promotion rule not found", "Failed to fetch promotion rules")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, rules, "")
+}
+
+// PUT /environments/{id}/promotion-rule
+func (h *PromotionHandler) SetRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	envID, ok := h.pathID(w, r, "environment")
+	if !ok {
+		return
+	}
+	var input promotionRuleInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	rule := model.PromotionRule{
+		EnvironmentID:     envID,
+		From:              input.From,
+		MinSoakSeconds:    input.MinSoakSeconds,
+		RequiredApprovals: input.RequiredApprovals,
+	}
+	var saved *model.PromotionRule
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.SetPromotionRule(ctx, &rule); err != nil {
+			return err
+		}
+		var err error
+		saved, err = tx.GetPromotionRule(ctx, envID)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Environment not found", "Failed to set promotion rule")
+		return
+	}
+	h.Logger.Infow("Promotion rule set", "environment", saved.Environment, "from", saved.From)
+	utils.WriteJSON(w, http.StatusOK, saved, "")
+}
+
+// DELETE /environments/{id}/promotion-rule
+func (h *PromotionHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	envID, ok := h.pathID(w, r, "environment")
+	if !ok {
+		return
+	}
+	if err := h.Store.DeletePromotionRule(r.Context(), envID); err != nil {
+		writeStoreError(w, r, h.Logger, err, "Promotion rule not found", "Failed to delete promotion rule")
+		return
+	}
+	h.Logger.Infow("Promotion rule deleted", "environment_id", envID)
+	utils.WriteJSON(w, http.StatusOK, "promotion rule deleted successfully", "")
+}
+
+// GET /versions/{id}/approvals
+func (h *PromotionHandler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	versionID, ok := h.pathID(w, r, "version")
+	if !ok {
+		return
+	}
+	approvals, err := h.Store.ListApprovals(r.Context(), versionID)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to fetch approvals")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, approvals, "")
+}
+
+// POST /versions/{id}/approvals
+func (h *PromotionHandler) AddApproval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	versionID, ok := h.pathID(w, r, "version")
+	if !ok {
+		return
+	}
+	approver, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	var input approvalInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	a := model.Approval{
+		VersionID:   versionID,
+		Environment: input.Environment,
+		Approver:    approver,
+		Decision:    model.ApprovalDecision(input.Decision),
+		Comment:     input.Comment,
+	}
+	var created *model.Approval
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		id, err := tx.AddApproval(ctx, &a)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetApproval(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to record approval")
+		return
+	}
+	h.Logger.Infow("Approval recorded", "version_id", versionID, "environment", created.Environment, "approver", created.Approver, "decision", created.Decision)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// POST /versions/{id}/promote
+//
+// A version that fails a gate of the target environment's promotion rule
+// gets a 409 naming the gate in the error details.
+func (h *PromotionHandler) Promote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	versionID, ok := h.pathID(w, r, "version")
+	if !ok {
+		return
+	}
+	actor, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	override, ok := freezeOverride(w, r)
+	if !ok {
+		return
+	}
+	var input promoteInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	d := model.Deployment{Environment: input.Environment, Actor: actor}
+	var created *model.Deployment
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		v, err := tx.GetVersionByID(ctx, versionID)
+		if err != nil {
+			return err
+		}
+		if err := checkFreeze(ctx, tx, h.Logger, v.ServiceID, override); err != nil {
+			return err
+		}
+		id, err := tx.PromoteVersion(ctx, versionID, &d)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetDeployment(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to promote version")
+		return
+	}
+	h.Logger.Infow("Version promoted", "version_id", versionID, "version", created.Version, "environment", created.Environment, "actor", created.Actor)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// pathID parses the {id} route variable; entity names it in errors.
+func (h *PromotionHandler) pathID(w http.ResponseWriter, r *http.Request, entity string) (int64, bool) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid "+entity+" ID", entity+"_id", idStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid "+entity+" ID")
+		return 0, false
+	}
+	return id, true
+}
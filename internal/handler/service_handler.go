@@ -1,34 +1,50 @@
 package handler
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/codecrafted007/service-catalog-api/internal/labels"
+	"github.com/codecrafted007/service-catalog-api/internal/patch"
 	"github.com/codecrafted007/service-catalog-api/internal/storage"
 	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
 	"github.com/codecrafted007/service-catalog-api/model"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
 type ServiceHandler struct {
-	Store  storage.Storage
-	Logger *zap.SugaredLogger
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
 }
 
 func NewServiceHandler(store storage.Storage, logger *zap.SugaredLogger) *ServiceHandler {
 	return &ServiceHandler{
-		Store:  store,
-		Logger: logger,
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
 	}
 }
 
 func (h *ServiceHandler) ListServices(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	filter := r.URL.Query().Get("filter")
+	filter := storage.ServiceFilter{
+		Text:  r.URL.Query().Get("filter"),
+		Owner: r.URL.Query().Get("owner"),
+	}
+	selector, err := labels.Parse(r.URL.Query().Get("selector"))
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Selector = selector
 	sort := r.URL.Query().Get("sort")
 
 	pageStr := r.URL.Query().Get("page")
@@ -46,7 +62,7 @@ func (h *ServiceHandler) ListServices(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		h.Logger.Error("error listing services: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "Internal Server Error")
+		utils.WriteError(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -60,20 +76,16 @@ func (h *ServiceHandler) GetServiceByID(w http.ResponseWriter, r *http.Request)
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		h.Logger.Error("invalid service id: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid service ID")
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
 		return
 	}
 
 	svc, err := h.Store.GetServiceById(ctx, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			h.Logger.Warnw("Service not found", "id", id)
-			utils.WriteJSON(w, http.StatusNotFound, nil, "Service not found")
-			return
-		}
-
-		h.Logger.Errorw("Failed to get service", "error", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "Internal server error")
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Internal server error")
+		return
+	}
+	if notModified(w, r, svc.Revision) {
 		return
 	}
 
@@ -82,15 +94,12 @@ func (h *ServiceHandler) GetServiceByID(w http.ResponseWriter, r *http.Request)
 
 func (h *ServiceHandler) CreateService(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	var input struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Version     string `json:"version"`
-		Changelog   string `json:"changelog,omitempty"`
+	override, ok := freezeOverride(w, r)
+	if !ok {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.Logger.Errorw("invalid input", "error", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid input")
+	var input createServiceInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
 		return
 	}
 
@@ -100,25 +109,34 @@ func (h *ServiceHandler) CreateService(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:   time.Now(),
 	}
 
-	id, err := h.Store.CreateService(ctx, &service)
-	if err != nil {
-		h.Logger.Errorw("failed to create service", "error", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "Failed to create service")
-		return
-	}
-	service.ID = int(id)
-
 	version := model.Version{
-		ServiceID: id,
 		Version:   input.Version,
 		Changelog: input.Changelog,
 		CreatedAt: time.Now(),
 	}
 
-	_, err = h.Store.CreateVersion(ctx, &version)
+	// The service and its initial version are created atomically so a failed
+	// version insert never leaves a versionless service behind.
+	var id int64
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		var err error
+		id, err = tx.CreateService(ctx, &service)
+		if err != nil {
+			return fmt.Errorf("create service: %w", err)
+		}
+		version.ServiceID = id
+		// The initial version is released, so a freeze covering every
+		// service holds it back like any other release.
+		if err := checkFreeze(ctx, tx, h.Logger, id, override); err != nil {
+			return err
+		}
+		if _, err := tx.CreateVersion(ctx, &version); err != nil {
+			return fmt.Errorf("create initial version: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		h.Logger.Errorw("failed to create initial version", "error", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "Service created but failed to add version")
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to create service")
 		return
 	}
 
@@ -131,33 +149,40 @@ func (h *ServiceHandler) UpdateService(w http.ResponseWriter, r *http.Request) {
 	serviceID, err := strconv.Atoi(serviceIDStr)
 	if err != nil {
 		h.Logger.Errorw("invalid service ID", "error", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid service ID")
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
 		return
 	}
 
-	var input struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.Logger.Errorw("invalid input", "error", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid input")
+	var input updateServiceInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
 		return
 	}
 
 	updatedService := model.Service{
 		Name:        input.Name,
 		Description: input.Description,
+		Revision:    revision,
 	}
 
-	err = h.Store.UpdateService(ctx, serviceID, &updatedService)
+	var updated *model.Service
+	err = h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.UpdateService(ctx, serviceID, &updatedService); err != nil {
+			return err
+		}
+		updated, err = tx.GetServiceById(ctx, serviceID)
+		return err
+	})
 	if err != nil {
-		h.Logger.Errorw("failed to update service", "error", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "Failed to update service")
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to update service")
 		return
 	}
 
+	w.Header().Set("ETag", etag(updated.Revision))
 	utils.WriteJSON(w, http.StatusOK, nil, "")
 }
 
@@ -167,16 +192,111 @@ func (h *ServiceHandler) DeleteService(w http.ResponseWriter, r *http.Request) {
 	idStr := vars["id"]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "invalid service id")
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid service id")
 		return
 	}
 
-	err = h.Store.DeleteService(ctx, id)
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+
+	err = h.Store.DeleteService(ctx, id, revision)
 	if err != nil {
-		h.Logger.Errorf("failed to delete service %d: %v", id, err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "could not delete service")
+		writeStoreError(w, r, h.Logger, err, "Service not found", "could not delete service")
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, "service deleted successfully", "")
 }
+
+// PATCH /services/{id}
+//
+// Accepts a JSON Merge Patch (RFC 7386) or a JSON Patch (RFC 6902) applied
+// to the service as GET /services/{id} returns it. Only name and
+// description can change.
+func (h *ServiceHandler) PatchService(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceIDStr := mux.Vars(r)["id"]
+	serviceID, err := strconv.Atoi(serviceIDStr)
+	if err != nil {
+		h.Logger.Errorw("invalid service ID", "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+		return
+	}
+
+	var applyPatch func(doc, p []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchContentType:
+		applyPatch = patch.MergePatch
+	case patch.JSONPatchContentType:
+		applyPatch = patch.JSONPatch
+	default:
+		w.Header().Set("Accept-Patch", patch.MergePatchContentType+", "+patch.JSONPatchContentType)
+		utils.WriteError(w, r, http.StatusUnsupportedMediaType,
+			"PATCH requires Content-Type "+patch.MergePatchContentType+" or "+patch.JSONPatchContentType)
+		return
+	}
+
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	body, ok := readBody(w, r, h.Validator, h.Logger)
+	if !ok {
+		return
+	}
+
+	var updated *model.Service
+	err = h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		current, err := tx.GetServiceById(ctx, serviceID)
+		if err != nil {
+			return err
+		}
+		if revision != 0 && current.Revision != revision {
+			// Checked up front so a stale client gets 412 rather than a
+			// confusing test failure from a patch built on old data.
+			return storage.NewError(storage.ErrPreconditionFailed, "service has been modified", nil)
+		}
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		patched, err := applyPatch(doc, body)
+		if err != nil {
+			return err
+		}
+		changes, err := servicePatch(current, patched, h.Validator)
+		if err != nil {
+			return err
+		}
+		changes.Revision = revision
+		if err := tx.PatchService(ctx, serviceID, changes); err != nil {
+			return err
+		}
+		updated, err = tx.GetServiceById(ctx, serviceID)
+		return err
+	})
+
+	var fieldErrs validation.Errors
+	switch {
+	case err == nil:
+		h.Logger.Infow("Service patched successfully", "service_id", serviceID)
+		w.Header().Set("ETag", etag(updated.Revision))
+		utils.WriteJSON(w, http.StatusOK, updated, "")
+	case errors.As(err, &fieldErrs):
+		writeValidationErrors(w, r, h.Logger, fieldErrs)
+	case errors.Is(err, patch.ErrInvalidPatch):
+		h.Logger.Warnw("invalid patch", "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, patch.ErrTestFailed):
+		h.Logger.Warnw("patch test failed", "error", err)
+		utils.WriteError(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, patch.ErrPathNotFound), errors.Is(err, errNotAService):
+		h.Logger.Warnw("patch cannot be applied", "error", err)
+		utils.WriteError(w, r, http.StatusUnprocessableEntity, err.Error())
+	default:
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to patch service")
+	}
+}
@@ -2,15 +2,28 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/codecrafted007/service-catalog-api/internal/logger"
+	"github.com/codecrafted007/service-catalog-api/internal/middleware"
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	_ "github.com/codecrafted007/service-catalog-api/internal/storage/sqlite"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
 	"github.com/codecrafted007/service-catalog-api/model"
 	"github.com/gorilla/mux"
 	"github.com/jmoiron/sqlx"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -20,7 +33,7 @@ type mockStorage struct {
 	service  *model.Service
 }
 
-func (m *mockStorage) ListServices(context.Context, string, string, int, int) ([]model.Service, error) {
+func (m *mockStorage) ListServices(context.Context, storage.ServiceFilter, string, int, int) ([]model.Service, error) {
 	return m.services, nil
 }
 
@@ -31,8 +44,14 @@ func (m *mockStorage) CreateService(context.Context, *model.Service) (int64, err
 func (m *mockStorage) DB() *sqlx.DB {
 	return nil
 }
-func (m *mockStorage) IsValidAPIKey(key string) bool {
-	return true
+func (m *mockStorage) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	return fn(m)
+}
+func (m *mockStorage) GetAPIKey(key string) (*model.APIKey, error) {
+	return &model.APIKey{Name: key}, nil
+}
+func (m *mockStorage) CreateAPIKey(ctx context.Context, key, name string, elevated bool) error {
+	return nil
 }
 
 func (m *mockStorage) GetServiceById(xtx context.Context, id int) (*model.Service, error) {
@@ -41,21 +60,186 @@ func (m *mockStorage) GetServiceById(xtx context.Context, id int) (*model.Servic
 func (m *mockStorage) UpdateService(xtx context.Context, id int, s *model.Service) error {
 	return nil
 }
-func (m *mockStorage) DeleteService(xtx context.Context, id int) error {
+func (m *mockStorage) PatchService(ctx context.Context, id int, p storage.ServicePatch) error {
+	return nil
+}
+func (m *mockStorage) DeleteService(xtx context.Context, id int, revision int64) error {
 	return nil
 }
 
 func (m *mockStorage) CreateVersion(ctx context.Context, v *model.Version) (int64, error) {
 	return 0, nil
 }
-func (m *mockStorage) GetVersionsByServiceID(ctx context.Context, serviceID int64) ([]*model.Version, error) {
+func (m *mockStorage) GetVersionsByServiceID(ctx context.Context, serviceID int64, states []model.VersionState) ([]*model.Version, error) {
 	return nil, nil
 }
 func (m *mockStorage) GetVersionByID(ctx context.Context, versionID int64) (*model.Version, error) {
 	return nil, nil
 }
-func (m *mockStorage) DeleteVersionByID(ctx context.Context, versionID int64) (bool, error) {
-	return false, nil
+func (m *mockStorage) UpdateVersion(ctx context.Context, versionID int64, v *model.Version) error {
+	return nil
+}
+func (m *mockStorage) DeleteVersionByID(ctx context.Context, versionID int64, revision int64) error {
+	return nil
+}
+func (m *mockStorage) TransitionVersion(ctx context.Context, versionID int64, to model.VersionState, reason string, revision int64) error {
+	return nil
+}
+func (m *mockStorage) GetLatestVersion(ctx context.Context, serviceID int64, includePrerelease bool) (*model.Version, error) {
+	return nil, nil
+}
+func (m *mockStorage) LatestVersions(ctx context.Context, serviceIDs []int64, includePrerelease bool) (map[int64]*model.Version, error) {
+	return map[int64]*model.Version{}, nil
+}
+func (m *mockStorage) ResolveVersion(ctx context.Context, serviceID int64, c semver.Constraint) (*model.Version, error) {
+	return nil, nil
+}
+func (m *mockStorage) ListTeams(ctx context.Context) ([]model.Team, error) {
+	return nil, nil
+}
+func (m *mockStorage) GetTeam(ctx context.Context, id int64) (*model.Team, error) {
+	return nil, nil
+}
+func (m *mockStorage) CreateTeam(ctx context.Context, t *model.Team) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) UpdateTeam(ctx context.Context, id int64, t *model.Team) error {
+	return nil
+}
+func (m *mockStorage) DeleteTeam(ctx context.Context, id int64) error {
+	return nil
+}
+func (m *mockStorage) GetServiceOwnership(ctx context.Context, serviceID int64) (*model.Ownership, error) {
+	return nil, nil
+}
+func (m *mockStorage) SetServiceOwnership(ctx context.Context, serviceID int64, o storage.OwnershipUpdate) error {
+	return nil
+}
+func (m *mockStorage) SetServiceLabel(ctx context.Context, serviceID int64, key, value string, revision int64) error {
+	return nil
+}
+func (m *mockStorage) RemoveServiceLabel(ctx context.Context, serviceID int64, key string, revision int64) error {
+	return nil
+}
+func (m *mockStorage) AddServiceTag(ctx context.Context, serviceID int64, tag string, revision int64) error {
+	return nil
+}
+func (m *mockStorage) RemoveServiceTag(ctx context.Context, serviceID int64, tag string, revision int64) error {
+	return nil
+}
+func (m *mockStorage) AddDependency(ctx context.Context, d *model.Dependency) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) GetDependency(ctx context.Context, id int64) (*model.Dependency, error) {
+	return nil, nil
+}
+func (m *mockStorage) ListAllDependencies(ctx context.Context) ([]model.Dependency, error) {
+	return nil, nil
+}
+func (m *mockStorage) RemoveDependency(ctx context.Context, serviceID, dependsOnID int64) error {
+	return nil
+}
+func (m *mockStorage) ListDependencies(ctx context.Context, serviceID int64, depth int) ([]model.DependencyNode, error) {
+	return nil, nil
+}
+func (m *mockStorage) ListEnvironments(ctx context.Context) ([]model.Environment, error) {
+	return nil, nil
+}
+func (m *mockStorage) CreateEnvironment(ctx context.Context, e *model.Environment) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) GetEnvironment(ctx context.Context, id int64) (*model.Environment, error) {
+	return nil, nil
+}
+func (m *mockStorage) DeleteEnvironment(ctx context.Context, id int64) error {
+	return nil
+}
+func (m *mockStorage) CreateDeployment(ctx context.Context, d *model.Deployment) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) GetDeployment(ctx context.Context, id int64) (*model.Deployment, error) {
+	return nil, nil
+}
+func (m *mockStorage) ListDeployments(ctx context.Context, serviceID int64, environment string, page, limit int) ([]model.Deployment, error) {
+	return nil, nil
+}
+func (m *mockStorage) ServiceEnvironments(ctx context.Context, serviceID int64) ([]model.EnvironmentStatus, error) {
+	return nil, nil
+}
+func (m *mockStorage) ListPromotionRules(ctx context.Context) ([]model.PromotionRule, error) {
+	return nil, nil
+}
+func (m *mockStorage) GetPromotionRule(ctx context.Context, environmentID int64) (*model.PromotionRule, error) {
+	return nil, nil
+}
+func (m *mockStorage) SetPromotionRule(ctx context.Context, r *model.PromotionRule) error {
+	return nil
+}
+func (m *mockStorage) DeletePromotionRule(ctx context.Context, environmentID int64) error {
+	return nil
+}
+func (m *mockStorage) AddApproval(ctx context.Context, a *model.Approval) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) GetApproval(ctx context.Context, id int64) (*model.Approval, error) {
+	return nil, nil
+}
+func (m *mockStorage) ListApprovals(ctx context.Context, versionID int64) ([]model.Approval, error) {
+	return nil, nil
+}
+func (m *mockStorage) PromoteVersion(ctx context.Context, versionID int64, d *model.Deployment) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) ListApprovalPolicies(ctx context.Context) ([]model.ApprovalPolicy, error) {
+	return nil, nil
+}
+func (m *mockStorage) GetApprovalPolicy(ctx context.Context, id int64) (*model.ApprovalPolicy, error) {
+	return nil, nil
+}
+func (m *mockStorage) CreateApprovalPolicy(ctx context.Context, p *model.ApprovalPolicy) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) DeleteApprovalPolicy(ctx context.Context, id int64) error {
+	return nil
+}
+func (m *mockStorage) RequiredApprovals(ctx context.Context, serviceID int64) (int, error) {
+	return 0, nil
+}
+func (m *mockStorage) CreateApprovalRequest(ctx context.Context, req *model.ApprovalRequest) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) GetApprovalRequest(ctx context.Context, id int64) (*model.ApprovalRequest, error) {
+	return nil, nil
+}
+func (m *mockStorage) ListApprovalRequests(ctx context.Context, filter storage.ApprovalRequestFilter, page, limit int) ([]model.ApprovalRequest, error) {
+	return nil, nil
+}
+func (m *mockStorage) ReviewApprovalRequest(ctx context.Context, id int64, review *model.ApprovalReview) error {
+	return nil
+}
+func (m *mockStorage) ListFreezes(ctx context.Context) ([]model.Freeze, error) {
+	return nil, nil
+}
+func (m *mockStorage) GetFreeze(ctx context.Context, id int64) (*model.Freeze, error) {
+	return nil, nil
+}
+func (m *mockStorage) CreateFreeze(ctx context.Context, f *model.Freeze) (int64, error) {
+	return 0, nil
+}
+func (m *mockStorage) DeleteFreeze(ctx context.Context, id int64) error {
+	return nil
+}
+func (m *mockStorage) ActiveFreezes(ctx context.Context, serviceID int64, at time.Time) ([]model.Freeze, error) {
+	return nil, nil
+}
+func (m *mockStorage) AddAuditEvent(ctx context.Context, e *model.AuditEvent) error {
+	return nil
+}
+func (m *mockStorage) ListAuditEvents(ctx context.Context, filter storage.AuditFilter, page, limit int) ([]model.AuditEvent, error) {
+	return nil, nil
+}
+func (m *mockStorage) ListDependents(ctx context.Context, serviceID int64, depth int) ([]model.DependencyNode, error) {
+	return nil, nil
 }
 
 func TestListServices(t *testing.T) {
@@ -100,3 +284,1419 @@ func TestGetServiceByID(t *testing.T) {
 	assert.Contains(t, rec.Body.String(), "Test Service")
 
 }
+
+// newSQLiteStore returns a migrated SQLite store for tests that need real
+// transactional behaviour rather than the mock.
+func newSQLiteStore(t *testing.T) storage.Storage {
+	t.Helper()
+	store, err := storage.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"), storage.DefaultOptions())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.DB().Close() })
+
+	m, err := storage.NewMigrator(store.DB(), "sqlite3")
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	return store
+}
+
+func TestCreateServiceRollsBackWhenVersionInsertFails(t *testing.T) {
+	store := newSQLiteStore(t)
+	_, err := store.DB().Exec(`
+		CREATE TRIGGER fail_version_insert BEFORE INSERT ON versions
+		BEGIN
+			SELECT RAISE(ABORT, 'injected failure');
+		END`)
+	require.NoError(t, err)
+
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+	req := httptest.NewRequest(http.MethodPost, "/services", strings.NewReader(`{"name":"payments","version":"v1.0.0"}`))
+	rec := httptest.NewRecorder()
+
+	h.CreateService(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	var count int
+	require.NoError(t, store.DB().Get(&count, "SELECT COUNT(*) FROM services"))
+	assert.Zero(t, count, "service must not survive a failed version insert")
+}
+
+func TestMissingServiceReturns404(t *testing.T) {
+	store := newSQLiteStore(t)
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}", h.UpdateService).Methods("PUT")
+	r.HandleFunc("/services/{id}", h.DeleteService).Methods("DELETE")
+	r.HandleFunc("/services/{id}/versions", vh.CreateVersion).Methods("POST")
+	r.HandleFunc("/services/{id}/versions", vh.ListVersions).Methods("GET")
+	r.HandleFunc("/versions/{id}", vh.DeleteVersion).Methods("DELETE")
+
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodPut, "/services/42", `{"name":"x"}`},
+		{http.MethodDelete, "/services/42", ""},
+		{http.MethodPost, "/services/42/versions", `{"version":"v1.0.0"}`},
+		{http.MethodGet, "/services/42/versions", ""},
+		{http.MethodDelete, "/versions/42", ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestErrorsAsProblemJSON(t *testing.T) {
+	store := newSQLiteStore(t)
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}", h.GetServiceByID).Methods("GET")
+
+	req := httptest.NewRequest(http.MethodGet, "/services/42", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "/problems/not-found",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "Service not found",
+		"instance": "/services/42"
+	}`, rec.Body.String())
+
+	// Without the Accept header the default envelope is kept.
+	req = httptest.NewRequest(http.MethodGet, "/services/42", nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `"error":"Service not found"`)
+}
+
+func TestCreateServiceValidation(t *testing.T) {
+	h := NewServiceHandler(&mockStorage{}, zap.NewNop().Sugar())
+
+	for _, tc := range []struct {
+		name   string
+		body   string
+		status int
+		fields []string
+	}{
+		{"missing fields", `{"description":"no name"}`, http.StatusUnprocessableEntity, []string{"name", "version"}},
+		{"unknown field", `{"name":"a","version":"v1","owner":"me"}`, http.StatusUnprocessableEntity, []string{"owner"}},
+		{"not semver", `{"name":"a","version":"release-7"}`, http.StatusUnprocessableEntity, []string{"version"}},
+		{"malformed json", `{"name":`, http.StatusBadRequest, nil},
+		{"too large", `{"name":"` + strings.Repeat("x", 2<<20) + `"}`, http.StatusRequestEntityTooLarge, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/services", strings.NewReader(tc.body))
+			req.Header.Set("Accept", "application/problem+json")
+			rec := httptest.NewRecorder()
+
+			h.CreateService(rec, req)
+
+			assert.Equal(t, tc.status, rec.Code)
+			var problem utils.Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			var fields []string
+			for _, fe := range problem.Errors {
+				fields = append(fields, fe.Field)
+			}
+			assert.Equal(t, tc.fields, fields)
+		})
+	}
+}
+
+func TestLatestAndResolveVersions(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	serviceID, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	for _, v := range []string{"v1.4.0", "v1.9.0", "v1.10.0", "v2.0.0-rc.1"} {
+		_, err := store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: v})
+		require.NoError(t, err)
+	}
+
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}/versions/latest", vh.LatestVersion).Methods("GET")
+	r.HandleFunc("/services/{id}/versions/resolve", vh.ResolveVersion).Methods("GET")
+
+	for _, tc := range []struct {
+		path    string
+		status  int
+		version string
+	}{
+		{"/services/1/versions/latest", http.StatusOK, "v1.10.0"},
+		{"/services/1/versions/latest?includePrerelease=true", http.StatusOK, "v2.0.0-rc.1"},
+		{"/services/1/versions/latest?includePrerelease=false", http.StatusOK, "v1.10.0"},
+		{"/services/1/versions/latest?includePrerelease=maybe", http.StatusBadRequest, ""},
+		{"/services/1/versions/resolve?constraint=%5E1.4", http.StatusOK, "v1.10.0"},
+		{"/services/1/versions/resolve?constraint=%3E%3D1.4%2C%3C1.10", http.StatusOK, "v1.9.0"},
+		{"/services/1/versions/resolve?constraint=%5E3", http.StatusNotFound, ""},
+		{"/services/1/versions/resolve?constraint=%5E", http.StatusBadRequest, ""},
+		{"/services/42/versions/latest", http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		require.Equal(t, tc.status, rec.Code, tc.path)
+		if tc.version != "" {
+			var body struct{ Data model.Version }
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tc.version, body.Data.Version, tc.path)
+		}
+	}
+}
+
+func TestDuplicatesReturnExistingID(t *testing.T) {
+	store := newSQLiteStore(t)
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+
+	r := mux.NewRouter()
+	r.HandleFunc("/services", h.CreateService).Methods("POST")
+	r.HandleFunc("/services/{id}", h.UpdateService).Methods("PUT")
+	r.HandleFunc("/services/{id}/versions", vh.CreateVersion).Methods("POST")
+
+	do := func(method, path, body string, problem bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if problem {
+			req.Header.Set("Accept", "application/problem+json")
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/services", `{"name":"Payments","version":"v1.0.0"}`, false).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/services", `{"name":"Billing","version":"v1.0.0"}`, false).Code)
+
+	rec := do(http.MethodPost, "/services", `{"name":"payments","version":"v2.0.0"}`, false)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"code":409,"data":{"existingId":1},"error":"service already exists","success":false}`, rec.Body.String())
+
+	rec = do(http.MethodPut, "/services/2", `{"name":"PAYMENTS"}`, true)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	var problem map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.EqualValues(t, 1, problem["existingId"])
+	assert.Equal(t, "service already exists", problem["detail"])
+
+	rec = do(http.MethodPost, "/services/1/versions", `{"version":"v1.0.0"}`, true)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.EqualValues(t, 1, problem["existingId"])
+
+	// The unique indexes back the checks up for writers that race past them.
+	_, err := store.DB().Exec(`INSERT INTO services (name) VALUES ('BILLING')`)
+	assert.Error(t, err)
+}
+
+func TestUpdateVersion(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	serviceID, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	for _, v := range []model.Version{{Version: "v1.0.0", State: model.VersionDraft}, {Version: "v1.1.0"}} {
+		v.ServiceID, v.Changelog = serviceID, "initial"
+		_, err := store.CreateVersion(ctx, &v)
+		require.NoError(t, err)
+	}
+
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/versions/{id}", vh.UpdateVersion).Methods("PUT")
+	r.HandleFunc("/versions/{id}", vh.PatchVersion).Methods("PATCH")
+
+	do := func(method, path, body string) (*httptest.ResponseRecorder, model.Version) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var resp struct{ Data model.Version }
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp.Data
+	}
+
+	rec, v := do(http.MethodPatch, "/versions/1", `{"changelog":"fixed typo"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "v1.0.0", v.Version)
+	assert.Equal(t, "fixed typo", v.Changelog)
+
+	rec, v = do(http.MethodPut, "/versions/1", `{"version":"v1.0.1"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(1), v.ID)
+	assert.Equal(t, "v1.0.1", v.Version)
+	assert.Empty(t, v.Changelog, "PUT replaces every field")
+
+	rec, _ = do(http.MethodPatch, "/versions/1", `{"version":"v1.1.0"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"existingId":2`)
+
+	rec, _ = do(http.MethodPatch, "/versions/1", `{"version":"one"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec, _ = do(http.MethodPut, "/versions/42", `{"version":"v9.0.0"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Renaming a released version would release a new one without its
+	// gates; only its changelog may change.
+	rec, _ = do(http.MethodPut, "/versions/2", `{"version":"v2.0.0"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "only drafts can change their version")
+	rec, _ = do(http.MethodPatch, "/versions/2", `{"version":"v2.0.0"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec, v = do(http.MethodPatch, "/versions/2", `{"changelog":"fixed typo"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "v1.1.0", v.Version)
+
+	latest, err := store.GetLatestVersion(ctx, serviceID, true)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", latest.Version)
+}
+
+func TestPatchService(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	_, err := store.CreateService(ctx, &model.Service{Name: "payments", Description: "Takes money"})
+	require.NoError(t, err)
+
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}", h.PatchService).Methods("PATCH")
+
+	do := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/services/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	const merge, jsonPatch = "application/merge-patch+json", "application/json-patch+json"
+
+	rec := do(merge, `{"description":"Moves money"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	svc, err := store.GetServiceById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "payments", svc.Name, "merge patch must keep fields it does not mention")
+	assert.Equal(t, "Moves money", svc.Description)
+
+	rec = do(jsonPatch, `[{"op":"test","path":"/name","value":"payments"},{"op":"replace","path":"/name","value":"billing"}]`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"name":"billing"`)
+
+	for _, tc := range []struct {
+		contentType, body string
+		status            int
+	}{
+		{jsonPatch, `[{"op":"test","path":"/name","value":"payments"}]`, http.StatusConflict},
+		{jsonPatch, `[{"op":"remove","path":"/owner"}]`, http.StatusUnprocessableEntity},
+		{jsonPatch, `[{"op":"jump"}]`, http.StatusBadRequest},
+		{merge, `{"id":7}`, http.StatusUnprocessableEntity},
+		{merge, `{"name":null}`, http.StatusUnprocessableEntity},
+		{merge, `{"owner":"me"}`, http.StatusUnprocessableEntity},
+		{merge, `"billing"`, http.StatusUnprocessableEntity},
+		{"application/json", `{"name":"x"}`, http.StatusUnsupportedMediaType},
+	} {
+		rec := do(tc.contentType, tc.body)
+		assert.Equal(t, tc.status, rec.Code, "%s %s: %s", tc.contentType, tc.body, rec.Body.String())
+	}
+
+	svc, err = store.GetServiceById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "billing", svc.Name, "failed patches must not change the service")
+}
+
+func TestConditionalRequests(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	serviceID, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	_, err = store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: "v1.0.0"})
+	require.NoError(t, err)
+
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}", h.GetServiceByID).Methods("GET")
+	r.HandleFunc("/services/{id}", h.UpdateService).Methods("PUT")
+	r.HandleFunc("/services/{id}", h.PatchService).Methods("PATCH")
+	r.HandleFunc("/services/{id}", h.DeleteService).Methods("DELETE")
+	r.HandleFunc("/versions/{id}", vh.GetVersion).Methods("GET")
+	r.HandleFunc("/versions/{id}", vh.PatchVersion).Methods("PATCH")
+	r.HandleFunc("/versions/{id}", vh.DeleteVersion).Methods("DELETE")
+
+	do := func(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Adding the version bumped the service from its initial revision.
+	rec := do(http.MethodGet, "/services/1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	rec = do(http.MethodGet, "/services/1", "", "If-None-Match", `W/"2"`)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = do(http.MethodPut, "/services/1", `{"name":"billing"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rec = do(http.MethodPut, "/services/1", `{"name":"billing"}`, "If-Match", `"2"`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	rec = do(http.MethodPatch, "/services/1", `{"description":"Moves money"}`, "If-Match", `"2"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rec = do(http.MethodPatch, "/services/1", `{"description":"Moves money"}`, "If-Match", `"3"`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+
+	rec = do(http.MethodGet, "/versions/1", "", "If-None-Match", `"1"`)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	rec = do(http.MethodPatch, "/versions/1", `{"changelog":"first"}`, "If-Match", `"7"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rec = do(http.MethodPatch, "/versions/1", `{"changelog":"first"}`, "If-Match", `"1"`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	rec = do(http.MethodDelete, "/versions/1", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = do(http.MethodDelete, "/services/1", "", "If-Match", `"4, "5"`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = do(http.MethodDelete, "/services/1", "", "If-Match", `"4"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code, "the version patch bumped the service")
+	rec = do(http.MethodDelete, "/services/1", "", "If-Match", `"5"`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodDelete, "/services/1", "", "If-Match", "*")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestVersionLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	serviceID, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	for _, v := range []string{"v1.0.0", "v1.1.0"} {
+		_, err := store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: v})
+		require.NoError(t, err)
+	}
+
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}", h.GetServiceByID).Methods("GET")
+	r.HandleFunc("/services/{id}/versions", vh.CreateVersion).Methods("POST")
+	r.HandleFunc("/services/{id}/versions", vh.ListVersions).Methods("GET")
+	r.HandleFunc("/services/{id}/versions/latest", vh.LatestVersion).Methods("GET")
+	r.HandleFunc("/versions/{id}/state", vh.TransitionVersion).Methods("POST")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	latest := func() string {
+		var resp struct{ Data model.Version }
+		require.NoError(t, json.Unmarshal(do(http.MethodGet, "/services/1/versions/latest", "").Body.Bytes(), &resp))
+		return resp.Data.Version
+	}
+
+	rec := do(http.MethodPost, "/services/1/versions", `{"version":"v2.0.0","state":"draft"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"state":"draft"`)
+	assert.Equal(t, "v1.1.0", latest(), "drafts are not published")
+
+	rec = do(http.MethodPost, "/services/1/versions", `{"version":"v2.0.1","state":"yanked"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = do(http.MethodPost, "/versions/3/state", `{"state":"released"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "v2.0.0", latest())
+
+	rec = do(http.MethodPost, "/versions/3/state", `{"state":"yanked"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "yanking needs a reason")
+	rec = do(http.MethodPost, "/versions/3/state", `{"state":"yanked","reason":"corrupts ledgers"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"stateReason":"corrupts ledgers"`)
+	assert.Equal(t, "v1.1.0", latest())
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"state":"deprecated"}`, http.StatusConflict},
+		{`{"state":"released"}`, http.StatusConflict},
+		{`{"state":"yanked","reason":"again"}`, http.StatusConflict},
+		{`{"state":"draft"}`, http.StatusUnprocessableEntity},
+	} {
+		rec := do(http.MethodPost, "/versions/3/state", tc.body)
+		assert.Equal(t, tc.status, rec.Code, "%s: %s", tc.body, rec.Body.String())
+	}
+
+	rec = do(http.MethodPost, "/versions/1/state", `{"state":"deprecated","reason":"use v2"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var svc struct{ Data model.Service }
+	require.NoError(t, json.Unmarshal(do(http.MethodGet, "/services/1", "").Body.Bytes(), &svc))
+	assert.Equal(t, []string{"v1.0.0", "v1.1.0"}, svc.Data.Versions, "yanked versions are hidden")
+
+	var list struct{ Data []model.Version }
+	rec = do(http.MethodGet, "/services/1/versions?state=yanked,deprecated", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Data, 2)
+	assert.Equal(t, "v2.0.0", list.Data[0].Version)
+	assert.Equal(t, "v1.0.0", list.Data[1].Version)
+
+	rec = do(http.MethodGet, "/services/1/versions?state=gone", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServiceOwnership(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	_, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+	th := NewTeamHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}", h.GetServiceByID).Methods("GET")
+	r.HandleFunc("/services/{id}", h.PatchService).Methods("PATCH")
+	r.HandleFunc("/services/{id}/owners", th.SetServiceOwners).Methods("PUT")
+	r.HandleFunc("/teams", th.CreateTeam).Methods("POST")
+	r.HandleFunc("/teams/{id}", th.UpdateTeam).Methods("PUT")
+	r.HandleFunc("/teams/{id}", th.DeleteTeam).Methods("DELETE")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if method == http.MethodPatch {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/teams", `{"name":"Checkout","email":"checkout@example.com","chat":"#checkout"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPost, "/teams", `{"name":"checkout"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"existingId":1`)
+	rec = do(http.MethodPost, "/teams", `{"name":"Ledger","email":"not-an-email"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"teamId":9}`, http.StatusUnprocessableEntity},
+		{`{"contacts":[{"name":"Ana"}]}`, http.StatusUnprocessableEntity},
+		{`{"teamId":1,"contacts":[{"name":"Ana","email":"ana@example.com"},{"name":"Bo","chat":"@bo"}]}`, http.StatusOK},
+	} {
+		rec := do(http.MethodPut, "/services/1/owners", tc.body)
+		assert.Equal(t, tc.status, rec.Code, "%s: %s", tc.body, rec.Body.String())
+	}
+
+	var resp struct{ Data model.Service }
+	require.NoError(t, json.Unmarshal(do(http.MethodGet, "/services/1", "").Body.Bytes(), &resp))
+	require.NotNil(t, resp.Data.Ownership)
+	require.NotNil(t, resp.Data.Ownership.Team)
+	assert.Equal(t, "Checkout", resp.Data.Ownership.Team.Name)
+	assert.Equal(t, []model.Contact{{Name: "Ana", Email: "ana@example.com"}, {Name: "Bo", Chat: "@bo"}}, resp.Data.Ownership.Contacts)
+
+	rec = do(http.MethodPatch, "/services/1", `{"description":"Moves money"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, "ownership must not block other patches: %s", rec.Body.String())
+	rec = do(http.MethodPatch, "/services/1", `{"ownership":null}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = do(http.MethodDelete, "/teams/1", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = do(http.MethodPut, "/services/1/owners", `{"contacts":[]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodDelete, "/teams/1", "")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestLabelsAndSelectors(t *testing.T) {
+	logger.InitLogger() // ListServices logs its query
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	for _, name := range []string{"payments", "ledger", "search"} {
+		_, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+	}
+
+	h := NewServiceHandler(store, zap.NewNop().Sugar())
+	lh := NewLabelHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services", h.ListServices).Methods("GET")
+	r.HandleFunc("/services/{id}", h.PatchService).Methods("PATCH")
+	r.HandleFunc("/services/{id}/labels/{key:.+}", lh.SetLabel).Methods("PUT")
+	r.HandleFunc("/services/{id}/labels/{key:.+}", lh.RemoveLabel).Methods("DELETE")
+	r.HandleFunc("/services/{id}/tags/{tag:.+}", lh.AddTag).Methods("PUT")
+	r.HandleFunc("/services/{id}/tags/{tag:.+}", lh.RemoveTag).Methods("DELETE")
+
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if method == http.MethodPatch {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPut, "/services/1/labels/tier", `{"value":"1"}`, http.StatusOK},
+		{http.MethodPut, "/services/1/labels/lang", `{"value":"go"}`, http.StatusOK},
+		{http.MethodPut, "/services/2/labels/tier", `{"value":"2"}`, http.StatusOK},
+		{http.MethodPut, "/services/2/labels/example.com/lang", `{"value":"java"}`, http.StatusOK},
+		{http.MethodPut, "/services/2/labels/lang", `{"value":"java"}`, http.StatusOK},
+		{http.MethodPut, "/services/3/labels/lang", `{"value":"go"}`, http.StatusOK},
+		{http.MethodPut, "/services/3/tags/deprecated", "", http.StatusOK},
+		{http.MethodPut, "/services/3/tags/deprecated", "", http.StatusOK},
+		{http.MethodPut, "/services/1/labels/bad%20key", `{"value":"x"}`, http.StatusUnprocessableEntity},
+		{http.MethodPut, "/services/1/labels/tier", `{"value":"not valid"}`, http.StatusUnprocessableEntity},
+		{http.MethodPut, "/services/1/tags/-bad", "", http.StatusUnprocessableEntity},
+		{http.MethodPut, "/services/9/tags/pci", "", http.StatusNotFound},
+		{http.MethodDelete, "/services/1/labels/missing", "", http.StatusNotFound},
+		{http.MethodDelete, "/services/2/labels/example.com/lang", "", http.StatusOK},
+	} {
+		rec := do(tc.method, tc.path, tc.body)
+		assert.Equal(t, tc.status, rec.Code, "%s %s: %s", tc.method, tc.path, rec.Body.String())
+	}
+
+	names := func(selector string) []string {
+		rec := do(http.MethodGet, "/services?sort=name&selector="+url.QueryEscape(selector), "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp struct{ Data []model.Service }
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		var got []string
+		for _, s := range resp.Data {
+			got = append(got, s.Name)
+		}
+		return got
+	}
+	assert.Equal(t, []string{"payments"}, names("tier=1,lang in (go,java),!deprecated"))
+	assert.Equal(t, []string{"ledger", "payments"}, names("lang in (go,java),!deprecated"))
+	assert.Equal(t, []string{"search"}, names("deprecated"))
+	assert.Equal(t, []string{"payments", "search"}, names("tier!=2"))
+	assert.Equal(t, []string{"ledger"}, names("lang notin (go),tier!=1"))
+	assert.Empty(t, names("example.com/lang"))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/services?selector=lang+in+go", "").Code)
+
+	rec := do(http.MethodGet, "/services?selector=deprecated", "")
+	assert.Contains(t, rec.Body.String(), `"labels":{"lang":"go"}`)
+	assert.Contains(t, rec.Body.String(), `"tags":["deprecated"]`)
+
+	// Label writes move the service's revision like any other change.
+	rec = do(http.MethodPut, "/services/3/tags/pci", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rec = do(http.MethodDelete, "/services/3/tags/deprecated", "", "If-Match", `"4"`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"5"`, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), `"tags":[]`)
+
+	rec = do(http.MethodPatch, "/services/1", `{"description":"Moves money"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, "labels must not block other patches: %s", rec.Body.String())
+	rec = do(http.MethodPatch, "/services/1", `{"labels":{"tier":"0"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = do(http.MethodPatch, "/services/1", `{"tags":["pci"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+// problemDetail returns the error message of an envelope response.
+func problemDetail(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp struct{ Error string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Error
+}
+
+func TestDependencies(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	for _, name := range []string{"web", "checkout", "payments", "ledger"} {
+		_, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+	}
+
+	dh := NewDependencyHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}/dependencies", dh.AddDependency).Methods("POST")
+	r.HandleFunc("/services/{id}/dependencies", dh.ListDependencies).Methods("GET")
+	r.HandleFunc("/services/{id}/dependencies/{dependsOnId}", dh.RemoveDependency).Methods("DELETE")
+	r.HandleFunc("/services/{id}/dependents", dh.ListDependents).Methods("GET")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// web -> checkout -> payments -> ledger
+	for _, tc := range []struct {
+		path, body string
+		status     int
+	}{
+		{"/services/1/dependencies", `{"dependsOnId":2,"constraint":"^1.2"}`, http.StatusOK},
+		{"/services/2/dependencies", `{"dependsOnId":3}`, http.StatusOK},
+		{"/services/3/dependencies", `{"dependsOnId":4}`, http.StatusOK},
+		{"/services/1/dependencies", `{"dependsOnId":9}`, http.StatusUnprocessableEntity},
+		{"/services/1/dependencies", `{"dependsOnId":3,"constraint":"about one"}`, http.StatusUnprocessableEntity},
+		{"/services/1/dependencies", `{"constraint":"^1"}`, http.StatusUnprocessableEntity},
+		{"/services/9/dependencies", `{"dependsOnId":1}`, http.StatusNotFound},
+	} {
+		rec := do(http.MethodPost, tc.path, tc.body)
+		assert.Equal(t, tc.status, rec.Code, "%s %s: %s", tc.path, tc.body, rec.Body.String())
+	}
+
+	rec := do(http.MethodPost, "/services/1/dependencies", `{"dependsOnId":2}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"existingId":1`)
+	rec = do(http.MethodPost, "/services/4/dependencies", `{"dependsOnId":2}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "dependency would create a cycle: ledger -> checkout -> payments -> ledger", problemDetail(t, rec))
+	rec = do(http.MethodPost, "/services/4/dependencies", `{"dependsOnId":4}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "dependency would create a cycle: ledger -> ledger", problemDetail(t, rec))
+
+	walk := func(path string) []model.DependencyNode {
+		rec := do(http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp struct{ Data []model.DependencyNode }
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Data
+	}
+	assert.Equal(t, []model.DependencyNode{{ServiceID: 2, Name: "checkout", Depth: 1, Via: 1, Constraint: "^1.2"}}, walk("/services/1/dependencies"))
+	assert.Equal(t, []model.DependencyNode{
+		{ServiceID: 2, Name: "checkout", Depth: 1, Via: 1, Constraint: "^1.2"},
+		{ServiceID: 3, Name: "payments", Depth: 2, Via: 2},
+		{ServiceID: 4, Name: "ledger", Depth: 3, Via: 3},
+	}, walk("/services/1/dependencies?depth=0"))
+	assert.Equal(t, []model.DependencyNode{
+		{ServiceID: 2, Name: "checkout", Depth: 1, Via: 3},
+		{ServiceID: 1, Name: "web", Depth: 2, Via: 2, Constraint: "^1.2"},
+	}, walk("/services/3/dependents?depth=2"))
+	assert.Empty(t, walk("/services/1/dependents"))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/services/1/dependencies?depth=-1", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/services/9/dependents", "").Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/services/2/dependencies/3", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/services/2/dependencies/3", "").Code)
+	rec = do(http.MethodPost, "/services/4/dependencies", `{"dependsOnId":2}`)
+	assert.Equal(t, http.StatusOK, rec.Code, "the cycle is gone: %s", rec.Body.String())
+}
+
+func TestGraph(t *testing.T) {
+	logger.InitLogger() // ListServices logs its query
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	for _, name := range []string{"web", "checkout", "payments", "search"} {
+		_, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+	}
+	for _, v := range []string{"v1.4.0", "v1.5.0-rc.1"} {
+		_, err := store.CreateVersion(ctx, &model.Version{ServiceID: 2, Version: v})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.SetServiceLabel(ctx, 2, "tier", "1", 0))
+	_, err := store.AddDependency(ctx, &model.Dependency{ServiceID: 1, DependsOnID: 2, Constraint: "^1.2"})
+	require.NoError(t, err)
+	_, err = store.AddDependency(ctx, &model.Dependency{ServiceID: 2, DependsOnID: 3})
+	require.NoError(t, err)
+
+	gh := NewGraphHandler(store, zap.NewNop().Sugar())
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		gh.GetGraph(rec, httptest.NewRequest(http.MethodGet, "/graph"+query, nil))
+		return rec
+	}
+	graphOf := func(query string) (names []string, edges int) {
+		rec := get(query)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp struct {
+			Data struct {
+				Nodes []struct{ Name string }
+				Edges []struct{ From, To int64 }
+			}
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		for _, n := range resp.Data.Nodes {
+			names = append(names, n.Name)
+		}
+		return names, len(resp.Data.Edges)
+	}
+
+	names, edges := graphOf("")
+	assert.Equal(t, []string{"checkout", "payments", "search", "web"}, names)
+	assert.Equal(t, 2, edges)
+	names, edges = graphOf("?root=1&depth=1")
+	assert.Equal(t, []string{"checkout", "web"}, names)
+	assert.Equal(t, 1, edges)
+	names, _ = graphOf("?root=3&direction=dependents")
+	assert.Equal(t, []string{"checkout", "payments", "web"}, names)
+	names, _ = graphOf("?root=2&depth=1&direction=both")
+	assert.Equal(t, []string{"checkout", "payments", "web"}, names)
+
+	rec := get("?format=dot&root=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/vnd.graphviz; charset=utf-8", rec.Header().Get("Content-Type"))
+	// Prereleases are not the latest version.
+	assert.Contains(t, rec.Body.String(), `s2 [label="checkout\nv1.4.0\ntier=1"];`)
+	assert.Contains(t, rec.Body.String(), `s1 -> s2 [label="^1.2"];`)
+
+	rec = get("?format=mermaid")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "  s2 --> s3\n")
+
+	assert.Equal(t, http.StatusBadRequest, get("?format=png").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?direction=up").Code)
+	assert.Equal(t, http.StatusNotFound, get("?root=99").Code)
+}
+
+func TestDeployments(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	_, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	for _, v := range []string{"v1.0.0", "v1.1.0", "v1.2.0"} {
+		_, err := store.CreateVersion(ctx, &model.Version{ServiceID: 1, Version: v})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.TransitionVersion(ctx, 3, model.VersionYanked, "broken", 0))
+
+	dh := NewDeploymentHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/environments", dh.ListEnvironments).Methods("GET")
+	r.HandleFunc("/environments", dh.CreateEnvironment).Methods("POST")
+	r.HandleFunc("/environments/{id}", dh.DeleteEnvironment).Methods("DELETE")
+	r.HandleFunc("/services/{id}/deployments", dh.CreateDeployment).Methods("POST")
+	r.HandleFunc("/services/{id}/deployments", dh.ListDeployments).Methods("GET")
+	r.HandleFunc("/services/{id}/environments", dh.ServiceEnvironments).Methods("GET")
+	r.Use(middleware.APIKeyAuth(namedKey))
+
+	// doAs sends the request with an API key named key.
+	doAs := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		return doAs("ci", method, path, body)
+	}
+
+	rec := do(http.MethodPost, "/environments", `{"name":"qa"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPost, "/environments", `{"name":"PROD"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"existingId":3`)
+
+	for _, tc := range []struct {
+		key    string
+		body   string
+		status int
+	}{
+		// 1.0.0 is the same version as v1.0.0.
+		{"ci", `{"version":"1.0.0","environment":"staging"}`, http.StatusOK},
+		{"ci", `{"version":"V1.0.0","environment":"prod"}`, http.StatusOK},
+		{"ci", `{"version":"v1.1.0","environment":"staging"}`, http.StatusOK},
+		{"ci", `{"version":"v1.1.0","environment":"Prod","triggeredBy":"ana","status":"failed"}`, http.StatusOK},
+		{"ci", `{"version":"v1.2.0","environment":"prod"}`, http.StatusConflict},
+		{"ci", `{"version":"v9.0.0","environment":"prod"}`, http.StatusUnprocessableEntity},
+		{"ci", `{"version":"v1.1.0","environment":"moon"}`, http.StatusUnprocessableEntity},
+		{"ci", `{"version":"v1.1.0","environment":"prod","status":"done"}`, http.StatusUnprocessableEntity},
+		{"ci", `{"version":"v1.1.0"}`, http.StatusUnprocessableEntity},
+		// The actor is the API key and the time is now, not something the
+		// caller can claim.
+		{"ci", `{"version":"v1.1.0","environment":"prod","actor":"ana"}`, http.StatusUnprocessableEntity},
+		{"ci", `{"version":"v1.1.0","environment":"prod","deployedAt":"2024-01-01T10:00:00Z"}`, http.StatusUnprocessableEntity},
+	} {
+		rec := doAs(tc.key, http.MethodPost, "/services/1/deployments", tc.body)
+		assert.Equal(t, tc.status, rec.Code, "%s: %s", tc.body, rec.Body.String())
+	}
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/services/9/deployments", `{"version":"v1.0.0","environment":"prod"}`).Code)
+
+	var envs struct{ Data []model.EnvironmentStatus }
+	rec = do(http.MethodGet, "/services/1/environments", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &envs))
+	current := map[string]string{}
+	for _, e := range envs.Data {
+		current[e.Environment.Name] = ""
+		if e.Current != nil {
+			current[e.Environment.Name] = e.Current.Version
+		}
+	}
+	// The failed rollout leaves v1.0.0 running in prod.
+	assert.Equal(t, map[string]string{"dev": "", "staging": "v1.1.0", "prod": "v1.0.0", "qa": ""}, current)
+
+	var history struct{ Data []model.Deployment }
+	rec = do(http.MethodGet, "/services/1/deployments?environment=prod", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+	require.Len(t, history.Data, 2)
+	assert.Equal(t, model.DeploymentFailed, history.Data[0].Status)
+	assert.Equal(t, "ci", history.Data[0].Actor)
+	assert.Equal(t, "ana", history.Data[0].TriggeredBy)
+	assert.Equal(t, "v1.0.0", history.Data[1].Version)
+	rec = do(http.MethodGet, "/services/1/deployments?limit=1&page=2", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+	require.Len(t, history.Data, 1)
+	assert.Equal(t, "staging", history.Data[0].Environment)
+	assert.Equal(t, "v1.1.0", history.Data[0].Version)
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodDelete, "/environments/3", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/environments/4", "").Code)
+}
+
+func TestPromotion(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	_, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	for _, v := range []string{"v1.0.0", "v1.1.0"} {
+		_, err := store.CreateVersion(ctx, &model.Version{ServiceID: 1, Version: v})
+		require.NoError(t, err)
+	}
+
+	ph := NewPromotionHandler(store, zap.NewNop().Sugar())
+	dh := NewDeploymentHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/promotion-rules", ph.ListRules).Methods("GET")
+	r.HandleFunc("/environments/{id}/promotion-rule", ph.SetRule).Methods("PUT")
+	r.HandleFunc("/environments/{id}/promotion-rule", ph.DeleteRule).Methods("DELETE")
+	r.HandleFunc("/environments/{id}", dh.DeleteEnvironment).Methods("DELETE")
+	r.HandleFunc("/versions/{id}/approvals", ph.ListApprovals).Methods("GET")
+	r.HandleFunc("/versions/{id}/approvals", ph.AddApproval).Methods("POST")
+	r.HandleFunc("/versions/{id}/promote", ph.Promote).Methods("POST")
+	r.HandleFunc("/services/{id}/deployments", dh.CreateDeployment).Methods("POST")
+	r.Use(middleware.APIKeyAuth(namedKey))
+
+	// doAs sends the request with an API key named key.
+	doAs := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		return doAs("ci", method, path, body)
+	}
+	gate := func(rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+		var body struct{ Data struct{ Gate string } }
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Data.Gate
+	}
+
+	// dev (1) -> staging (2) -> prod (3)
+	rec := do(http.MethodPut, "/environments/2/promotion-rule", `{"from":"dev"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPut, "/environments/3/promotion-rule", `{"from":"Staging","minSoakSeconds":3600,"requiredApprovals":2}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var rule struct{ Data model.PromotionRule }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rule))
+	assert.Equal(t, model.PromotionRule{EnvironmentID: 3, Environment: "prod", FromEnvironmentID: 2, From: "staging", MinSoakSeconds: 3600, RequiredApprovals: 2}, rule.Data)
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodPut, "/environments/1/promotion-rule", `{"from":"prod"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPut, "/environments/1/promotion-rule", `{"from":"dev"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPut, "/environments/1/promotion-rule", `{"from":"moon"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPut, "/environments/1/promotion-rule", `{"from":"prod","minSoakSeconds":-1}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/environments/9/promotion-rule", `{"from":"dev"}`).Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodDelete, "/environments/2", "").Code)
+
+	// v1.0.0 has never been to dev, so it cannot skip ahead to staging.
+	assert.Equal(t, "path", gate(do(http.MethodPost, "/versions/1/promote", `{"environment":"staging"}`)))
+	rec = do(http.MethodPost, "/versions/1/promote", `{"environment":"dev"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPost, "/versions/1/promote", `{"environment":"staging"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var promoted struct{ Data model.Deployment }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &promoted))
+	assert.Equal(t, "v1.0.0", promoted.Data.Version)
+	assert.Equal(t, model.DeploymentSucceeded, promoted.Data.Status)
+	assert.Equal(t, "ci", promoted.Data.Actor)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, "/versions/1/promote", `{"environment":"staging","actor":"ana"}`).Code)
+
+	rec = do(http.MethodPost, "/versions/1/promote", `{"environment":"prod"}`)
+	assert.Equal(t, "soak", gate(rec))
+	assert.Contains(t, problemDetail(t, rec), "prod requires 1h0m0s")
+
+	// Recording a deployment directly goes through the same gates.
+	rec = do(http.MethodPost, "/services/1/deployments", `{"version":"v1.0.0","environment":"prod"}`)
+	assert.Equal(t, "soak", gate(rec))
+	assert.Equal(t, "path", gate(do(http.MethodPost, "/services/1/deployments", `{"version":"v1.1.0","environment":"prod","status":"failed"}`)))
+
+	// v1.0.0 went out four hours ago; v1.1.0 reached dev three hours ago and
+	// staging two hours ago.
+	_, err = store.DB().Exec(`UPDATE deployments SET deployed_at = ? WHERE version_id = 1`, time.Now().UTC().Add(-4*time.Hour))
+	require.NoError(t, err)
+	for _, d := range []struct {
+		env string
+		ago time.Duration
+	}{{"dev", 3 * time.Hour}, {"staging", 2 * time.Hour}} {
+		_, err = store.CreateDeployment(ctx, &model.Deployment{ServiceID: 1, Version: "v1.1.0", Environment: d.env,
+			Status: model.DeploymentSucceeded, Actor: "ci", DeployedAt: time.Now().UTC().Add(-d.ago)})
+		require.NoError(t, err)
+	}
+	rec = do(http.MethodPost, "/versions/2/promote", `{"environment":"prod"}`)
+	assert.Equal(t, "approvals", gate(rec))
+	assert.Contains(t, problemDetail(t, rec), "has 0 of 2 approvals")
+
+	rec = doAs("ana", http.MethodPost, "/versions/2/approvals", `{"environment":"prod","decision":"rejected","comment":"wait for the freeze"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doAs("bo", http.MethodPost, "/versions/2/approvals", `{"environment":"prod","decision":"approved"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPost, "/versions/2/promote", `{"environment":"prod"}`)
+	assert.Equal(t, "approvals", gate(rec))
+	assert.Contains(t, problemDetail(t, rec), "rejected for prod by ana")
+
+	// An approver who changes their mind replaces their earlier decision.
+	rec = doAs("ana", http.MethodPost, "/versions/2/approvals", `{"environment":"prod","decision":"approved"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var approvals struct{ Data []model.Approval }
+	rec = do(http.MethodGet, "/versions/2/approvals", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &approvals))
+	require.Len(t, approvals.Data, 2)
+	assert.Equal(t, "bo", approvals.Data[0].Approver)
+	assert.Equal(t, model.ApprovalApproved, approvals.Data[1].Decision)
+
+	rec = do(http.MethodPost, "/versions/2/promote", `{"environment":"prod"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// Once v1.0.0 is back in staging, v1.1.0 is no longer running there and
+	// its soak time there no longer counts.
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/versions/1/promote", `{"environment":"dev"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/versions/1/promote", `{"environment":"staging"}`).Code)
+	rec = do(http.MethodPost, "/versions/2/promote", `{"environment":"prod"}`)
+	assert.Equal(t, "path", gate(rec))
+	assert.Contains(t, problemDetail(t, rec), "must be running in staging")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, doAs("ana", http.MethodPost, "/versions/2/approvals", `{"environment":"prod","decision":"maybe"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, "/versions/2/promote", `{"environment":"moon"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/versions/9/promote", `{"environment":"prod"}`).Code)
+
+	// Without a rule, any version may go straight to the environment.
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/environments/3/promotion-rule", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/environments/3/promotion-rule", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/versions/1/promote", `{"environment":"prod"}`).Code)
+
+	var rules struct{ Data []model.PromotionRule }
+	require.NoError(t, json.Unmarshal(do(http.MethodGet, "/promotion-rules", "").Body.Bytes(), &rules))
+	require.Len(t, rules.Data, 1)
+	assert.Equal(t, "staging", rules.Data[0].Environment)
+}
+
+// namedKey accepts any API key, naming it after the key itself.
+func namedKey(key string) (*model.APIKey, error) {
+	return &model.APIKey{Name: key}, nil
+}
+
+func TestReleaseApprovals(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	for _, name := range []string{"payments", "search", "ledger"} {
+		_, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.SetServiceLabel(ctx, 1, "tier", "critical", 0))
+
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+	ah := NewApprovalHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services/{id}/versions", vh.CreateVersion).Methods("POST")
+	r.HandleFunc("/versions/{id}", vh.GetVersion).Methods("GET")
+	r.HandleFunc("/versions/{id}/state", vh.TransitionVersion).Methods("POST")
+	r.HandleFunc("/approval-policies", ah.ListPolicies).Methods("GET")
+	r.HandleFunc("/approval-policies", ah.CreatePolicy).Methods("POST")
+	r.HandleFunc("/approval-policies/{id}", ah.DeletePolicy).Methods("DELETE")
+	r.HandleFunc("/versions/{id}/approval-requests", ah.RequestApproval).Methods("POST")
+	r.HandleFunc("/versions/{id}/approval-requests", ah.ListVersionRequests).Methods("GET")
+	r.HandleFunc("/approval-requests", ah.ListRequests).Methods("GET")
+	r.HandleFunc("/approval-requests/{id}", ah.GetRequest).Methods("GET")
+	r.HandleFunc("/approval-requests/{id}/approve", ah.Approve).Methods("POST")
+	r.HandleFunc("/approval-requests/{id}/reject", ah.Reject).Methods("POST")
+	r.HandleFunc("/services/{id}/deployments", NewDeploymentHandler(store, zap.NewNop().Sugar()).CreateDeployment).Methods("POST")
+	r.HandleFunc("/versions/{id}/promote", NewPromotionHandler(store, zap.NewNop().Sugar()).Promote).Methods("POST")
+	r.Use(middleware.APIKeyAuth(namedKey))
+
+	doAs := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	request := func(rec *httptest.ResponseRecorder) model.ApprovalRequest {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body struct{ Data model.ApprovalRequest }
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Data
+	}
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"labelKey":"tier","labelValue":"critical","requiredApprovals":2}`, http.StatusOK},
+		{`{"serviceId":2,"requiredApprovals":1}`, http.StatusOK},
+		{`{"labelKey":"tier","labelValue":"critical","requiredApprovals":3}`, http.StatusConflict},
+		{`{"requiredApprovals":1}`, http.StatusUnprocessableEntity},
+		{`{"serviceId":9,"requiredApprovals":1}`, http.StatusUnprocessableEntity},
+		{`{"serviceId":1,"labelKey":"tier","requiredApprovals":1}`, http.StatusUnprocessableEntity},
+		{`{"labelKey":"tier","labelValue":"critical"}`, http.StatusUnprocessableEntity},
+		{`{"labelKey":"tier","requiredApprovals":1}`, http.StatusOK},
+	} {
+		rec := doAs("admin", http.MethodPost, "/approval-policies", tc.body)
+		assert.Equal(t, tc.status, rec.Code, "%s: %s", tc.body, rec.Body.String())
+	}
+
+	// Creating a released version of a critical service opens a request
+	// and leaves the version a draft.
+	rec := doAs("dev", http.MethodPost, "/services/1/versions", `{"version":"v1.0.0"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Equal(t, "/approval-requests/1", rec.Header().Get("Location"))
+	var created struct{ Data model.Version }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, model.VersionDraft, created.Data.State)
+
+	var pending struct{ Data []model.ApprovalRequest }
+	require.NoError(t, json.Unmarshal(doAs("ana", http.MethodGet, "/approval-requests", "").Body.Bytes(), &pending))
+	require.Len(t, pending.Data, 1)
+	assert.Equal(t, "dev", pending.Data[0].RequestedBy)
+	assert.Equal(t, 2, pending.Data[0].RequiredApprovals)
+	assert.Equal(t, "v1.0.0", pending.Data[0].Version)
+
+	rec = doAs("dev", http.MethodPost, "/versions/1/state", `{"state":"released"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, problemDetail(t, rec), "needs 2 approvals")
+	assert.Equal(t, http.StatusConflict, doAs("dev", http.MethodPost, "/approval-requests/1/approve", `{}`).Code)
+
+	// A draft awaiting approval cannot be deployed or promoted.
+	rec = doAs("dev", http.MethodPost, "/services/1/deployments", `{"version":"v1.0.0","environment":"prod"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, problemDetail(t, rec), "version v1.0.0 is draft")
+	rec = doAs("dev", http.MethodPost, "/versions/1/promote", `{"environment":"prod"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, problemDetail(t, rec), "version v1.0.0 is draft")
+
+	req := request(doAs("ana", http.MethodPost, "/approval-requests/1/approve", `{"comment":"looks good"}`))
+	assert.Equal(t, model.ApprovalRequestPending, req.Status)
+	req = request(doAs("ana", http.MethodPost, "/approval-requests/1/approve", `{"comment":"still good"}`))
+	assert.Equal(t, model.ApprovalRequestPending, req.Status)
+	require.Len(t, req.Reviews, 1)
+	assert.Equal(t, "still good", req.Reviews[0].Comment)
+
+	req = request(doAs("bo", http.MethodPost, "/approval-requests/1/approve", `{}`))
+	assert.Equal(t, model.ApprovalRequestApproved, req.Status)
+	assert.NotNil(t, req.ResolvedAt)
+	version, err := store.GetVersionByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, model.VersionReleased, version.State)
+	assert.Equal(t, "approved by ana, bo", version.StateReason)
+	rec = doAs("dev", http.MethodPost, "/services/1/deployments", `{"version":"v1.0.0","environment":"prod"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusConflict, doAs("cy", http.MethodPost, "/approval-requests/1/reject", `{}`).Code)
+
+	require.NoError(t, json.Unmarshal(doAs("ana", http.MethodGet, "/approval-requests", "").Body.Bytes(), &pending))
+	assert.Empty(t, pending.Data)
+	require.NoError(t, json.Unmarshal(doAs("ana", http.MethodGet, "/approval-requests?status=approved&service=1", "").Body.Bytes(), &pending))
+	assert.Len(t, pending.Data, 1)
+	assert.Equal(t, http.StatusBadRequest, doAs("ana", http.MethodGet, "/approval-requests?status=maybe", "").Code)
+
+	// Drafts are submitted explicitly, and one rejection is final.
+	rec = doAs("dev", http.MethodPost, "/services/1/versions", `{"version":"v1.1.0","state":"draft"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	req = request(doAs("dev", http.MethodPost, "/versions/2/approval-requests", `{"comment":"please"}`))
+	assert.Equal(t, "please", req.Comment)
+	rec = doAs("dev", http.MethodPost, "/versions/2/approval-requests", `{}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), fmt.Sprintf(`"existingId":%d`, req.ID))
+	req = request(doAs("ana", http.MethodPost, fmt.Sprintf("/approval-requests/%d/reject", req.ID), `{"comment":"missing changelog"}`))
+	assert.Equal(t, model.ApprovalRequestRejected, req.Status)
+	var history struct{ Data []model.ApprovalRequest }
+	require.NoError(t, json.Unmarshal(doAs("dev", http.MethodGet, "/versions/2/approval-requests", "").Body.Bytes(), &history))
+	require.Len(t, history.Data, 1)
+	assert.Equal(t, "missing changelog", history.Data[0].Reviews[0].Comment)
+
+	// Services without a policy release straight away.
+	rec = doAs("dev", http.MethodPost, "/services/3/versions", `{"version":"v1.0.0"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusConflict, doAs("dev", http.MethodPost, "/versions/3/approval-requests", `{}`).Code)
+	rec = doAs("dev", http.MethodPost, "/services/3/versions", `{"version":"v1.1.0","state":"draft"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusOK, doAs("dev", http.MethodPost, "/versions/4/state", `{"state":"released"}`).Code)
+	assert.Equal(t, http.StatusNotFound, doAs("dev", http.MethodGet, "/versions/9/approval-requests", "").Code)
+
+	assert.Equal(t, http.StatusOK, doAs("admin", http.MethodDelete, "/approval-policies/2", "").Code)
+	assert.Equal(t, http.StatusNotFound, doAs("admin", http.MethodDelete, "/approval-policies/2", "").Code)
+	rec = doAs("dev", http.MethodPost, "/services/2/versions", `{"version":"v1.0.0"}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestFreezes(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	for _, name := range []string{"payments", "search", "ledger"} {
+		_, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.SetServiceLabel(ctx, 1, "tier", "critical", 0))
+	teamID, err := store.CreateTeam(ctx, &model.Team{Name: "core"})
+	require.NoError(t, err)
+	require.NoError(t, store.SetServiceOwnership(ctx, 2, storage.OwnershipUpdate{TeamID: teamID}))
+
+	sh := NewServiceHandler(store, zap.NewNop().Sugar())
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+	dh := NewDeploymentHandler(store, zap.NewNop().Sugar())
+	lh := NewLabelHandler(store, zap.NewNop().Sugar())
+	th := NewTeamHandler(store, zap.NewNop().Sugar())
+	fh := NewFreezeHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services", sh.CreateService).Methods("POST")
+	r.HandleFunc("/services/{id}/labels/{key}", lh.SetLabel).Methods("PUT")
+	r.HandleFunc("/services/{id}/labels/{key}", lh.RemoveLabel).Methods("DELETE")
+	r.HandleFunc("/services/{id}/owners", th.SetServiceOwners).Methods("PUT")
+	r.HandleFunc("/services/{id}/versions", vh.CreateVersion).Methods("POST")
+	r.HandleFunc("/versions/{id}/state", vh.TransitionVersion).Methods("POST")
+	r.HandleFunc("/services/{id}/deployments", dh.CreateDeployment).Methods("POST")
+	r.HandleFunc("/freezes", fh.ListFreezes).Methods("GET")
+	r.HandleFunc("/freezes", fh.CreateFreeze).Methods("POST")
+	r.HandleFunc("/freezes/active", fh.ActiveFreezes).Methods("GET")
+	r.HandleFunc("/freezes/{id}", fh.DeleteFreeze).Methods("DELETE")
+	// Only root's key is elevated.
+	r.Use(middleware.APIKeyAuth(func(key string) (*model.APIKey, error) {
+		return &model.APIKey{Name: key, Elevated: key == "root"}, nil
+	}))
+
+	doAs := func(key, override, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		if override != "" {
+			req.Header.Set("X-Freeze-Override", override)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	frozen := func(rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+		var body struct {
+			Error string
+			Data  struct{ Gate string }
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "freeze", body.Data.Gate)
+		return body.Error
+	}
+	active := func(path string) []model.Freeze {
+		t.Helper()
+		rec := doAs("ci", "", http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body struct{ Data []model.Freeze }
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Data
+	}
+
+	now := time.Now().UTC()
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{fmt.Sprintf(`{"startsAt":%q,"endsAt":%q,"selector":"tier=critical","reason":"holidays"}`, at(-time.Hour), at(24*time.Hour)), http.StatusOK},
+		{fmt.Sprintf(`{"startsAt":%q,"endsAt":%q,"teamId":%d,"reason":"offsite"}`, at(48*time.Hour), at(72*time.Hour), teamID), http.StatusOK},
+		{fmt.Sprintf(`{"startsAt":%q,"endsAt":%q,"reason":"backwards"}`, at(time.Hour), at(-time.Hour)), http.StatusUnprocessableEntity},
+		{fmt.Sprintf(`{"startsAt":%q,"endsAt":%q,"selector":"tier in (","reason":"typo"}`, at(0), at(time.Hour)), http.StatusUnprocessableEntity},
+		{fmt.Sprintf(`{"startsAt":%q,"endsAt":%q,"teamId":9,"reason":"nobody"}`, at(0), at(time.Hour)), http.StatusUnprocessableEntity},
+		{fmt.Sprintf(`{"startsAt":%q,"endsAt":%q}`, at(0), at(time.Hour)), http.StatusUnprocessableEntity},
+		{`{"reason":"forever"}`, http.StatusUnprocessableEntity},
+	} {
+		rec := doAs("ops", "", http.MethodPost, "/freezes", tc.body)
+		assert.Equal(t, tc.status, rec.Code, "%s: %s", tc.body, rec.Body.String())
+	}
+	assert.Len(t, active("/freezes"), 2)
+	assert.Len(t, active("/freezes/active"), 1)
+	freezes := active("/freezes/active?service=1")
+	require.Len(t, freezes, 1)
+	assert.Equal(t, "ops", freezes[0].CreatedBy)
+	assert.Empty(t, active("/freezes/active?service=2"))
+	assert.Equal(t, http.StatusNotFound, doAs("ci", "", http.MethodGet, "/freezes/active?service=9", "").Code)
+	assert.Equal(t, http.StatusBadRequest, doAs("ci", "", http.MethodGet, "/freezes/active?service=x", "").Code)
+
+	// Releasing a frozen service is refused; drafts are not releases.
+	msg := frozen(doAs("dev", "", http.MethodPost, "/services/1/versions", `{"version":"v1.0.0"}`))
+	assert.Contains(t, msg, "holidays")
+	rec := doAs("dev", "", http.MethodPost, "/services/1/versions", `{"version":"v1.0.0","state":"draft"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	frozen(doAs("dev", "", http.MethodPost, "/versions/1/state", `{"state":"released"}`))
+
+	// Only an elevated key may override the freeze.
+	assert.Equal(t, http.StatusForbidden, doAs("dev", "hotfix", http.MethodPost, "/versions/1/state", `{"state":"released"}`).Code)
+	rec = doAs("root", "hotfix", http.MethodPost, "/versions/1/state", `{"state":"released"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	deploy := `{"version":"v1.0.0","environment":"prod"}`
+	frozen(doAs("ci", "", http.MethodPost, "/services/1/deployments", deploy))
+	assert.Equal(t, http.StatusOK, doAs("root", "hotfix", http.MethodPost, "/services/1/deployments", deploy).Code)
+
+	// Each override is audited on the freeze, with the key and the reason.
+	events, err := store.ListAuditEvents(ctx, storage.AuditFilter{EntityType: "freeze", EntityID: freezes[0].ID}, 1, 10)
+	require.NoError(t, err)
+	var overrides []model.AuditEvent
+	for _, e := range events {
+		if e.Action == model.AuditOverride {
+			overrides = append(overrides, e)
+		}
+	}
+	require.Len(t, overrides, 2)
+	assert.Equal(t, "root", overrides[0].Actor)
+	assert.JSONEq(t, `{"serviceId":1,"reason":"hotfix"}`, string(overrides[1].After))
+
+	// Relabelling cannot take a service out of a freeze, but other labels
+	// may change.
+	msg = frozen(doAs("dev", "", http.MethodDelete, "/services/1/labels/tier", ""))
+	assert.Contains(t, msg, "cannot leave a freeze")
+	frozen(doAs("dev", "", http.MethodPut, "/services/1/labels/tier", `{"value":"low"}`))
+	assert.Equal(t, http.StatusOK, doAs("dev", "", http.MethodPut, "/services/1/labels/lang", `{"value":"go"}`).Code)
+	assert.Len(t, active("/freezes/active?service=1"), 1)
+
+	// Nor can handing it to another team.
+	teamFreeze, err := store.CreateFreeze(ctx, &model.Freeze{StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), TeamID: &teamID, Reason: "audit"})
+	require.NoError(t, err)
+	frozen(doAs("dev", "", http.MethodPut, "/services/2/owners", `{"contacts":[]}`))
+	assert.Equal(t, http.StatusOK, doAs("root", "reorg", http.MethodPut, "/services/2/owners", `{"contacts":[]}`).Code)
+	assert.Empty(t, active("/freezes/active?service=2"))
+	require.NoError(t, store.DeleteFreeze(ctx, teamFreeze))
+
+	// Services outside the freeze carry on.
+	assert.Equal(t, http.StatusOK, doAs("dev", "", http.MethodPost, "/services/3/versions", `{"version":"v1.0.0"}`).Code)
+	assert.Equal(t, http.StatusOK, doAs("ci", "", http.MethodPost, "/services/3/deployments", deploy).Code)
+
+	// Lifting a freeze early is as privileged as overriding it.
+	assert.Equal(t, http.StatusForbidden, doAs("ops", "", http.MethodDelete, fmt.Sprintf("/freezes/%d", freezes[0].ID), "").Code)
+	assert.Equal(t, http.StatusOK, doAs("root", "", http.MethodDelete, fmt.Sprintf("/freezes/%d", freezes[0].ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, doAs("root", "", http.MethodDelete, fmt.Sprintf("/freezes/%d", freezes[0].ID), "").Code)
+	ended, err := store.CreateFreeze(ctx, &model.Freeze{StartsAt: now.Add(-48 * time.Hour), EndsAt: now.Add(-24 * time.Hour), Reason: "over"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, doAs("ops", "", http.MethodDelete, fmt.Sprintf("/freezes/%d", ended), "").Code)
+	assert.Equal(t, http.StatusOK, doAs("dev", "", http.MethodPost, "/services/1/versions", `{"version":"v1.1.0"}`).Code)
+
+	// A freeze over every service holds back new services too, since they
+	// come with a released version.
+	_, err = store.CreateFreeze(ctx, &model.Freeze{StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), Reason: "migration"})
+	require.NoError(t, err)
+	msg = frozen(doAs("dev", "", http.MethodPost, "/services", `{"name":"billing","version":"v1.0.0"}`))
+	assert.Contains(t, msg, "migration")
+	_, err = store.GetServiceById(ctx, 4)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "a refused service must not be left without its version")
+	assert.Equal(t, http.StatusOK, doAs("root", "launch", http.MethodPost, "/services", `{"name":"billing","version":"v1.0.0"}`).Code)
+}
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+
+	sh := NewServiceHandler(store, zap.NewNop().Sugar())
+	vh := NewVersionHandler(store, zap.NewNop().Sugar())
+	ah := NewApprovalHandler(store, zap.NewNop().Sugar())
+	audh := NewAuditHandler(store, zap.NewNop().Sugar())
+	r := mux.NewRouter()
+	r.HandleFunc("/services", sh.CreateService).Methods("POST")
+	r.HandleFunc("/services/{id}", sh.DeleteService).Methods("DELETE")
+	r.HandleFunc("/services/{id}/versions", vh.CreateVersion).Methods("POST")
+	r.HandleFunc("/approval-requests/{id}/approve", ah.Approve).Methods("POST")
+	r.HandleFunc("/audit", audh.ListEvents).Methods("GET")
+	r.Use(middleware.RequestID)
+	r.Use(middleware.APIKeyAuth(namedKey))
+
+	doAs := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	events := func(query string) []model.AuditEvent {
+		t.Helper()
+		rec := doAs("auditor", http.MethodGet, "/audit?"+query, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body struct{ Data []model.AuditEvent }
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Data
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/services", strings.NewReader(`{"name":"payments","version":"v1.0.0"}`))
+	req.Header.Set("X-API-Key", "ana")
+	req.Header.Set("X-Request-ID", "deploy-42")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "deploy-42", rec.Header().Get("X-Request-ID"))
+
+	// Writes made outside a request have no actor.
+	require.NoError(t, store.SetServiceLabel(ctx, 1, "tier", "critical", 0))
+	_, err := store.CreateApprovalPolicy(ctx, &model.ApprovalPolicy{LabelKey: "tier", LabelValue: "critical", RequiredApprovals: 1})
+	require.NoError(t, err)
+
+	rec = doAs("dev", http.MethodPost, "/services/1/versions", `{"version":"v1.1.0"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Len(t, rec.Header().Get("X-Request-ID"), 32)
+	require.Equal(t, http.StatusOK, doAs("bo", http.MethodPost, "/approval-requests/1/approve", `{}`).Code)
+	require.Equal(t, http.StatusOK, doAs("bo", http.MethodDelete, "/services/1", "").Code)
+
+	// The approval that released the version is on record for both.
+	versionEvents := events("entity=version:2")
+	require.Len(t, versionEvents, 2)
+	assert.Equal(t, "dev", versionEvents[0].Actor)
+	assert.Equal(t, model.AuditCreate, versionEvents[0].Action)
+	assert.Equal(t, "bo", versionEvents[1].Actor)
+	var released model.Version
+	require.NoError(t, json.Unmarshal(versionEvents[1].After, &released))
+	assert.Equal(t, model.VersionReleased, released.State)
+	requestEvents := events("entity=approval_request:1")
+	require.Len(t, requestEvents, 2)
+	assert.Equal(t, versionEvents[1].RequestID, requestEvents[1].RequestID)
+
+	serviceEvents := events("entity=service:1")
+	require.Len(t, serviceEvents, 3)
+	assert.Equal(t, "ana", serviceEvents[0].Actor)
+	assert.Equal(t, "deploy-42", serviceEvents[0].RequestID)
+	assert.Equal(t, "", serviceEvents[1].Actor)
+	assert.Equal(t, model.AuditDelete, serviceEvents[2].Action)
+	assert.Equal(t, "bo", serviceEvents[2].Actor)
+	assert.Nil(t, serviceEvents[2].After)
+
+	assert.Len(t, events("entity=service"), 3)
+	assert.Len(t, events("actor=bo"), 3)
+	assert.Len(t, events("entity=service&limit=2&page=2"), 1)
+	assert.Empty(t, events("since="+url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))))
+	for _, query := range []string{"entity=service:x", "entity=:1", "entity=service:0", "since=yesterday"} {
+		assert.Equal(t, http.StatusBadRequest, doAs("auditor", http.MethodGet, "/audit?"+query, "").Code, query)
+	}
+}
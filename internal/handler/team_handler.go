@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type TeamHandler struct {
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
+}
+
+func NewTeamHandler(store storage.Storage, logger *zap.SugaredLogger) *TeamHandler {
+	return &TeamHandler{
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
+	}
+}
+
+// GET /teams
+func (h *TeamHandler) ListTeams(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teams, err := h.Store.ListTeams(ctx)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Team not found", "Failed to fetch teams")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, teams, "")
+}
+
+// POST /teams
+func (h *TeamHandler) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var input teamInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	team := model.Team{
+		Name:        input.Name,
+		Description: input.Description,
+		Email:       input.Email,
+		Chat:        input.Chat,
+	}
+	var created *model.Team
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		id, err := tx.CreateTeam(ctx, &team)
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetTeam(ctx, id)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Team not found", "Failed to create team")
+		return
+	}
+	h.Logger.Infow("Team created successfully", "team_id", created.ID)
+	utils.WriteJSON(w, http.StatusOK, created, "")
+}
+
+// GET /teams/{id}
+func (h *TeamHandler) GetTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := h.teamID(w, r)
+	if !ok {
+		return
+	}
+	team, err := h.Store.GetTeam(ctx, teamID)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Team not found", "Failed to fetch team")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, team, "")
+}
+
+// PUT /teams/{id}
+func (h *TeamHandler) UpdateTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := h.teamID(w, r)
+	if !ok {
+		return
+	}
+	var input teamInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	team := model.Team{
+		Name:        input.Name,
+		Description: input.Description,
+		Email:       input.Email,
+		Chat:        input.Chat,
+	}
+	var updated *model.Team
+	err := h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.UpdateTeam(ctx, teamID, &team); err != nil {
+			return err
+		}
+		var err error
+		updated, err = tx.GetTeam(ctx, teamID)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Team not found", "Failed to update team")
+		return
+	}
+	h.Logger.Infow("Team updated successfully", "team_id", teamID)
+	utils.WriteJSON(w, http.StatusOK, updated, "")
+}
+
+// DELETE /teams/{id}
+func (h *TeamHandler) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := h.teamID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Store.DeleteTeam(ctx, teamID); err != nil {
+		writeStoreError(w, r, h.Logger, err, "Team not found", "Failed to delete team")
+		return
+	}
+	h.Logger.Infow("Team deleted successfully", "team_id", teamID)
+	utils.WriteJSON(w, http.StatusOK, "team deleted successfully", "")
+}
+
+// GET /services/{id}/owners
+func (h *TeamHandler) GetServiceOwners(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+		return
+	}
+	ownership, err := h.Store.GetServiceOwnership(ctx, serviceID)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to fetch owners")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, ownership, "")
+}
+
+// PUT /services/{id}/owners
+//
+// Replaces the owning team and contacts of a service. It changes the
+// service's revision, so If-Match takes the service ETag. Moving the service
+// out of a team's freeze needs a freeze override.
+func (h *TeamHandler) SetServiceOwners(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+		return
+	}
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	override, ok := freezeOverride(w, r)
+	if !ok {
+		return
+	}
+	var input ownershipInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	update := storage.OwnershipUpdate{TeamID: input.TeamID, Contacts: input.Contacts, Revision: revision}
+	var svc *model.Service
+	err = h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		err := keepFrozen(ctx, tx, h.Logger, serviceID, override, func() error {
+			return tx.SetServiceOwnership(ctx, serviceID, update)
+		})
+		if err != nil {
+			return err
+		}
+		svc, err = tx.GetServiceById(ctx, int(serviceID))
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to update owners")
+		return
+	}
+	h.Logger.Infow("Service owners updated", "service_id", serviceID)
+	w.Header().Set("ETag", etag(svc.Revision))
+	utils.WriteJSON(w, http.StatusOK, svc.Ownership, "")
+}
+
+func (h *TeamHandler) teamID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid team ID", "team_id", idStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid team ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -1,29 +1,34 @@
 package handler
 
 import (
-	"database/sql"
-	"encoding/json"
-	"errors"
+	"context"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/codecrafted007/service-catalog-api/internal/middleware"
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
 	"github.com/codecrafted007/service-catalog-api/internal/storage"
 	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/internal/validation"
 	"github.com/codecrafted007/service-catalog-api/model"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
 type VersionHandler struct {
-	Store  storage.Storage
-	Logger *zap.SugaredLogger
+	Store     storage.Storage
+	Logger    *zap.SugaredLogger
+	Validator *validation.Validator
 }
 
 func NewVersionHandler(store storage.Storage, logger *zap.SugaredLogger) *VersionHandler {
 	return &VersionHandler{
-		Store:  store,
-		Logger: logger,
+		Store:     store,
+		Logger:    logger,
+		Validator: validation.Default(),
 	}
 }
 
@@ -33,18 +38,16 @@ func (h *VersionHandler) CreateVersion(w http.ResponseWriter, r *http.Request) {
 	serviceID, err := strconv.ParseInt(serviceIDStr, 10, 64)
 	if err != nil {
 		h.Logger.Warnw("Invalid service ID", "service_id", serviceIDStr, "error", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid service ID")
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
 		return
 	}
 
-	var input struct {
-		Version   string `json:"version"`
-		Changelog string `json:"changelog,omitempty"`
+	override, ok := freezeOverride(w, r)
+	if !ok {
+		return
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.Logger.Warnw("Failed to decode CreateVersion payload", "error", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid input")
+	var input createVersionInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
 		return
 	}
 
@@ -53,19 +56,68 @@ func (h *VersionHandler) CreateVersion(w http.ResponseWriter, r *http.Request) {
 		Version:   input.Version,
 		Changelog: input.Changelog,
 		CreatedAt: time.Now(),
+		State:     model.VersionState(input.State),
 	}
+	if newVersion.State == "" {
+		newVersion.State = model.VersionReleased
+	}
+
+	// A service whose policies require approvals gets a draft and an
+	// approval request instead; approving the request releases the version.
+	var request *model.ApprovalRequest
+	err = h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if newVersion.State == model.VersionReleased {
+			required, err := tx.RequiredApprovals(ctx, serviceID)
+			if err != nil {
+				return err
+			}
+			if required > 0 {
+				newVersion.State = model.VersionDraft
+				request = &model.ApprovalRequest{}
+			} else if err := checkFreeze(ctx, tx, h.Logger, serviceID, override); err != nil {
+				return err
+			}
+		}
 
-	insertedID, err := h.Store.CreateVersion(ctx, &newVersion)
+		insertedID, err := tx.CreateVersion(ctx, &newVersion)
+		if err != nil {
+			return err
+		}
+		newVersion.ID = insertedID
+		if request == nil {
+			return nil
+		}
+		request.VersionID = insertedID
+		if request.RequestedBy, err = h.requester(r); err != nil {
+			return err
+		}
+		request.ID, err = tx.CreateApprovalRequest(ctx, request)
+		return err
+	})
 	if err != nil {
-		h.Logger.Errorw("DB error creating version", "service_id", serviceID, "error", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "Failed to create version")
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to create version")
 		return
 	}
-	newVersion.ID = insertedID
-	h.Logger.Infow("Version created successfully", "version_id", insertedID, "service_id", serviceID)
+	if request != nil {
+		h.Logger.Infow("Version created pending approval", "version_id", newVersion.ID, "service_id", serviceID, "request_id", request.ID)
+		w.Header().Set("Location", fmt.Sprintf("/approval-requests/%d", request.ID))
+		utils.WriteJSON(w, http.StatusAccepted, newVersion, "")
+		return
+	}
+	h.Logger.Infow("Version created successfully", "version_id", newVersion.ID, "service_id", serviceID)
 	utils.WriteJSON(w, http.StatusOK, newVersion, "")
 }
 
+// requester names the caller for an approval request opened on its
+// behalf. Unauthenticated requests cannot open one.
+func (h *VersionHandler) requester(r *http.Request) (string, error) {
+	key := middleware.APIKeyFromContext(r.Context())
+	if key == nil {
+		return "", storage.NewError(storage.ErrConflict, "releasing this version needs approvals, which need an API key", nil)
+	}
+	return key.Name, nil
+}
+
 // GET /services/{id}/versions
 func (h *VersionHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -73,20 +125,102 @@ func (h *VersionHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	serviceID, err := strconv.ParseInt(serviceIDStr, 10, 64)
 	if err != nil {
 		h.Logger.Warnw("Invalid service ID", "service_id", serviceIDStr, "error", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid service ID")
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
 		return
 	}
 
-	versions, err := h.Store.GetVersionsByServiceID(ctx, serviceID)
+	// ?state=released,deprecated; repeating the parameter works too.
+	var states []model.VersionState
+	for _, raw := range r.URL.Query()["state"] {
+		for _, name := range strings.Split(raw, ",") {
+			state := model.VersionState(strings.TrimSpace(name))
+			if !state.Valid() {
+				utils.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid state %q", name))
+				return
+			}
+			states = append(states, state)
+		}
+	}
+
+	versions, err := h.Store.GetVersionsByServiceID(ctx, serviceID, states)
 	if err != nil {
-		h.Logger.Errorw("Error while fetching versions", "service_id", serviceID, "error", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "Failed to fetch versions")
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to fetch versions")
 		return
 	}
 	h.Logger.Infow("Versions fetched succesfully", "service_id", serviceID, "count", len(versions))
 	utils.WriteJSON(w, http.StatusOK, versions, "")
 }
 
+// GET /services/{id}/versions/latest?includePrerelease=
+//
+// Prereleases are skipped unless includePrerelease is true.
+func (h *VersionHandler) LatestVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceIDStr := mux.Vars(r)["id"]
+	serviceID, err := strconv.ParseInt(serviceIDStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid service ID", "service_id", serviceIDStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+		return
+	}
+
+	var includePrerelease bool
+	if raw := r.URL.Query().Get("includePrerelease"); raw != "" {
+		if includePrerelease, err = strconv.ParseBool(raw); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, "Invalid includePrerelease parameter")
+			return
+		}
+	}
+
+	version, err := h.Store.GetLatestVersion(ctx, serviceID, includePrerelease)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to fetch latest version")
+		return
+	}
+	if version == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "Service has no matching version")
+		return
+	}
+	h.Logger.Infow("Latest version fetched successfully", "service_id", serviceID, "version", version.Version)
+	utils.WriteJSON(w, http.StatusOK, version, "")
+}
+
+// GET /services/{id}/versions/resolve?constraint=^1.4
+func (h *VersionHandler) ResolveVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceIDStr := mux.Vars(r)["id"]
+	serviceID, err := strconv.ParseInt(serviceIDStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid service ID", "service_id", serviceIDStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid service ID")
+		return
+	}
+
+	raw := r.URL.Query().Get("constraint")
+	if raw == "" {
+		utils.WriteError(w, r, http.StatusBadRequest, "Missing constraint parameter")
+		return
+	}
+	constraint, err := semver.ParseConstraint(raw)
+	if err != nil {
+		h.Logger.Warnw("Invalid version constraint", "constraint", raw, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid constraint: "+err.Error())
+		return
+	}
+
+	version, err := h.Store.ResolveVersion(ctx, serviceID, constraint)
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Service not found", "Failed to resolve version")
+		return
+	}
+	if version == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "No version satisfies "+raw)
+		return
+	}
+	h.Logger.Infow("Version resolved successfully", "service_id", serviceID, "constraint", raw, "version", version.Version)
+	utils.WriteJSON(w, http.StatusOK, version, "")
+}
+
 // GET /versions/{id}
 func (h *VersionHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -94,25 +228,107 @@ func (h *VersionHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
 	versionID, err := strconv.ParseInt(versionIDStr, 10, 64)
 	if err != nil {
 		h.Logger.Warnw("Invalid version ID", "version_id", versionIDStr, "error", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid version ID")
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid version ID")
 		return
 	}
 
 	version, err := h.Store.GetVersionByID(ctx, versionID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			h.Logger.Warnw("Version not found", "version_id", versionID)
-			utils.WriteJSON(w, http.StatusNotFound, nil, "Version not found")
-		} else {
-			h.Logger.Errorw("Error while fetching version", "version_id", versionID, "error", err)
-			utils.WriteJSON(w, http.StatusInternalServerError, nil, "Failed to fetch version")
-		}
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to fetch version")
+		return
+	}
+	if notModified(w, r, version.Revision) {
 		return
 	}
 	h.Logger.Infow("Version fetched successfully", "version_id", versionID)
 	utils.WriteJSON(w, http.StatusOK, version, "")
 }
 
+// PUT /versions/{id}
+func (h *VersionHandler) UpdateVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	versionIDStr := mux.Vars(r)["id"]
+	versionID, err := strconv.ParseInt(versionIDStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid version ID for update", "version_id", versionIDStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid version ID")
+		return
+	}
+
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	var input versionInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	var updated *model.Version
+	err = h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.UpdateVersion(ctx, versionID, &model.Version{Version: input.Version, Changelog: input.Changelog, Revision: revision}); err != nil {
+			return err
+		}
+		updated, err = tx.GetVersionByID(ctx, versionID)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to update version")
+		return
+	}
+	h.Logger.Infow("Version updated successfully", "version_id", versionID)
+	w.Header().Set("ETag", etag(updated.Revision))
+	utils.WriteJSON(w, http.StatusOK, updated, "")
+}
+
+// PATCH /versions/{id}
+func (h *VersionHandler) PatchVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	versionIDStr := mux.Vars(r)["id"]
+	versionID, err := strconv.ParseInt(versionIDStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid version ID for patch", "version_id", versionIDStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid version ID")
+		return
+	}
+
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	var input versionPatchInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	// Read, merge and write in one transaction so the response is exactly
+	// what was stored.
+	var updated *model.Version
+	err = h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		current, err := tx.GetVersionByID(ctx, versionID)
+		if err != nil {
+			return err
+		}
+		if revision != 0 && current.Revision != revision {
+			return storage.NewError(storage.ErrPreconditionFailed, "version has been modified", nil)
+		}
+		input.apply(current)
+		current.Revision = revision
+		if err := tx.UpdateVersion(ctx, versionID, current); err != nil {
+			return err
+		}
+		updated, err = tx.GetVersionByID(ctx, versionID)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to update version")
+		return
+	}
+	h.Logger.Infow("Version patched successfully", "version_id", versionID)
+	w.Header().Set("ETag", etag(updated.Revision))
+	utils.WriteJSON(w, http.StatusOK, updated, "")
+}
+
 // DELETE /versions/{id}
 func (h *VersionHandler) DeleteVersion(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -120,22 +336,92 @@ func (h *VersionHandler) DeleteVersion(w http.ResponseWriter, r *http.Request) {
 	versionID, err := strconv.ParseInt(versionIDStr, 10, 64)
 	if err != nil {
 		h.Logger.Warnw("Invalid version ID for delete", "version_id", versionIDStr, "error", err)
-		utils.WriteJSON(w, http.StatusBadRequest, nil, "Invalid version ID")
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid version ID")
 		return
 	}
 
-	deleted, err := h.Store.DeleteVersionByID(ctx, versionID)
-	if err != nil {
-		h.Logger.Errorw("Error while deleting version", "version_id", versionID, "error", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, nil, "Failed to delete version")
+	revision, ok := requiredRevision(w, r)
+	if !ok {
 		return
 	}
-
-	if !deleted {
-		h.Logger.Warnw("Version not found for deletion", "version_id", versionID)
-		utils.WriteJSON(w, http.StatusNotFound, nil, "Version not found")
+	if err := h.Store.DeleteVersionByID(ctx, versionID, revision); err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to delete version")
 		return
 	}
 	h.Logger.Infow("Version deleted succesfully", "version_id", versionID)
 	utils.WriteJSON(w, http.StatusNoContent, nil, "")
 }
+
+// POST /versions/{id}/state
+//
+// Moves a version through its lifecycle: draft to released to deprecated,
+// or from any state to yanked with a reason.
+func (h *VersionHandler) TransitionVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	versionIDStr := mux.Vars(r)["id"]
+	versionID, err := strconv.ParseInt(versionIDStr, 10, 64)
+	if err != nil {
+		h.Logger.Warnw("Invalid version ID for transition", "version_id", versionIDStr, "error", err)
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid version ID")
+		return
+	}
+
+	revision, ok := requiredRevision(w, r)
+	if !ok {
+		return
+	}
+	override, ok := freezeOverride(w, r)
+	if !ok {
+		return
+	}
+	var input transitionInput
+	if !decodeInput(w, r, h.Validator, h.Logger, &input) {
+		return
+	}
+
+	var updated *model.Version
+	err = h.Store.WithTx(ctx, func(tx storage.Storage) error {
+		if model.VersionState(input.State) == model.VersionReleased {
+			if err := releaseApproved(ctx, tx, versionID); err != nil {
+				return err
+			}
+			v, err := tx.GetVersionByID(ctx, versionID)
+			if err != nil {
+				return err
+			}
+			if err := checkFreeze(ctx, tx, h.Logger, v.ServiceID, override); err != nil {
+				return err
+			}
+		}
+		if err := tx.TransitionVersion(ctx, versionID, model.VersionState(input.State), input.Reason, revision); err != nil {
+			return err
+		}
+		updated, err = tx.GetVersionByID(ctx, versionID)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, r, h.Logger, err, "Version not found", "Failed to change version state")
+		return
+	}
+	h.Logger.Infow("Version state changed", "version_id", versionID, "state", updated.State)
+	w.Header().Set("ETag", etag(updated.Revision))
+	utils.WriteJSON(w, http.StatusOK, updated, "")
+}
+
+// releaseApproved fails with ErrConflict when the version's service
+// requires approvals and no approval request for the version was approved.
+func releaseApproved(ctx context.Context, tx storage.Storage, versionID int64) error {
+	v, err := tx.GetVersionByID(ctx, versionID)
+	if err != nil {
+		return err
+	}
+	required, err := tx.RequiredApprovals(ctx, v.ServiceID)
+	if err != nil || required == 0 {
+		return err
+	}
+	approved, err := tx.ListApprovalRequests(ctx, storage.ApprovalRequestFilter{VersionID: versionID, Status: model.ApprovalRequestApproved}, 1, 1)
+	if err != nil || len(approved) > 0 {
+		return err
+	}
+	return storage.NewError(storage.ErrConflict, fmt.Sprintf("version %s needs %d approvals before it is released", v.Version, required), nil)
+}
@@ -0,0 +1,46 @@
+// Package labels validates service labels and tags and parses
+// Kubernetes-style label selectors over them.
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const maxNameLength = 63
+
+var (
+	namePattern   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	prefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+)
+
+// ValidateKey checks a label key or tag: an optional DNS-style prefix and a
+// slash, then up to 63 letters, digits, '-', '_' or '.', starting and ending
+// with a letter or digit. "tier" and "example.com/team" are both keys.
+func ValidateKey(key string) error {
+	name := key
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		prefix := key[:i]
+		if len(prefix) > 253 || !prefixPattern.MatchString(prefix) {
+			return fmt.Errorf("%q has an invalid prefix", key)
+		}
+		name = key[i+1:]
+	}
+	if len(name) > maxNameLength || !namePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid key (letters, digits, '-', '_' and '.', at most %d characters)", key, maxNameLength)
+	}
+	return nil
+}
+
+// ValidateValue checks a label value, which follows the same rules as a key
+// name but may also be empty.
+func ValidateValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > maxNameLength || !namePattern.MatchString(value) {
+		return fmt.Errorf("%q is not a valid value (letters, digits, '-', '_' and '.', at most %d characters)", value, maxNameLength)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Operator is how a Requirement tests a key.
+type Operator string
+
+const (
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+)
+
+// Requirement is one comma-separated term of a selector.
+type Requirement struct {
+	Key    string
+	Op     Operator
+	Values []string
+}
+
+// Selector is a conjunction of requirements; the empty selector matches
+// everything. Tags take part as labels without a value: they satisfy
+// "key" and fail "!key", but never match a value.
+//
+//	tier=1              label tier is 1 (== works too)
+//	tier!=1             tier is missing or not 1
+//	lang in (go,java)   lang is go or java
+//	lang notin (php)    lang is missing or not php
+//	deprecated          label or tag deprecated is present
+//	!deprecated         neither is
+type Selector []Requirement
+
+var setPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// Parse reads a selector such as "tier=1,lang in (go,java),!deprecated".
+func Parse(s string) (Selector, error) {
+	var sel Selector
+	for _, term := range splitTerms(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			if strings.TrimSpace(s) == "" {
+				break
+			}
+			return nil, fmt.Errorf("invalid selector %q: empty requirement", s)
+		}
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// splitTerms splits on commas that are not inside a value set.
+func splitTerms(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	var req Requirement
+	switch {
+	case strings.HasPrefix(term, "!") && !strings.Contains(term, "="):
+		req = Requirement{Key: strings.TrimSpace(term[1:]), Op: DoesNotExist}
+	case strings.Contains(term, "!="):
+		key, value, _ := strings.Cut(term, "!=")
+		req = Requirement{Key: strings.TrimSpace(key), Op: NotEquals, Values: []string{strings.TrimSpace(value)}}
+	case strings.Contains(term, "="):
+		key, value, _ := strings.Cut(term, "=")
+		value = strings.TrimPrefix(value, "=")
+		req = Requirement{Key: strings.TrimSpace(key), Op: Equals, Values: []string{strings.TrimSpace(value)}}
+	case setPattern.MatchString(term):
+		m := setPattern.FindStringSubmatch(term)
+		req = Requirement{Key: m[1], Op: Operator(m[2])}
+		for _, v := range strings.Split(m[3], ",") {
+			req.Values = append(req.Values, strings.TrimSpace(v))
+		}
+	case strings.ContainsAny(term, " ()"):
+		return req, fmt.Errorf("cannot parse %q", term)
+	default:
+		req = Requirement{Key: term, Op: Exists}
+	}
+
+	if err := ValidateKey(req.Key); err != nil {
+		return req, err
+	}
+	if (req.Op == In || req.Op == NotIn) && len(req.Values) == 1 && req.Values[0] == "" {
+		return req, fmt.Errorf("%q needs at least one value", term)
+	}
+	for _, v := range req.Values {
+		if err := ValidateValue(v); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
+// Matches reports whether a service with these labels and tags satisfies
+// every requirement.
+func (s Selector) Matches(labels map[string]string, tags []string) bool {
+	for _, req := range s {
+		if !req.Matches(labels, tags) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether a service with these labels and tags satisfies
+// the requirement.
+func (r Requirement) Matches(labels map[string]string, tags []string) bool {
+	value, ok := labels[r.Key]
+	switch r.Op {
+	case Exists:
+		return ok || contains(tags, r.Key)
+	case DoesNotExist:
+		return !ok && !contains(tags, r.Key)
+	case Equals, In:
+		return ok && contains(r.Values, value)
+	case NotEquals, NotIn:
+		return !ok || !contains(r.Values, value)
+	}
+	return false
+}
+
+func (s Selector) String() string {
+	terms := make([]string, len(s))
+	for i, r := range s {
+		switch r.Op {
+		case Exists:
+			terms[i] = r.Key
+		case DoesNotExist:
+			terms[i] = "!" + r.Key
+		case Equals, NotEquals:
+			terms[i] = r.Key + string(r.Op) + r.Values[0]
+		default:
+			terms[i] = r.Key + " " + string(r.Op) + " (" + strings.Join(r.Values, ",") + ")"
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	sel, err := Parse("tier=1, lang in (go, java),!deprecated,team!=core,env notin (dev),example.com/owner,x==y")
+	require.NoError(t, err)
+	assert.Equal(t, Selector{
+		{Key: "tier", Op: Equals, Values: []string{"1"}},
+		{Key: "lang", Op: In, Values: []string{"go", "java"}},
+		{Key: "deprecated", Op: DoesNotExist},
+		{Key: "team", Op: NotEquals, Values: []string{"core"}},
+		{Key: "env", Op: NotIn, Values: []string{"dev"}},
+		{Key: "example.com/owner", Op: Exists},
+		{Key: "x", Op: Equals, Values: []string{"y"}},
+	}, sel)
+	assert.Equal(t, "tier=1,lang in (go,java),!deprecated,team!=core,env notin (dev),example.com/owner,x=y", sel.String())
+
+	empty, err := Parse("  ")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for _, bad := range []string{"tier=1,", "lang in ()", "lang in go", "=1", "-bad", "tier=a b", "lang in (go", ",tier"} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	labels := map[string]string{"tier": "1", "lang": "go"}
+	tags := []string{"pci"}
+	for selector, want := range map[string]bool{
+		"tier=1,lang in (go,java)": true,
+		"tier=2":                   false,
+		"tier!=2,team!=core":       true,
+		"lang notin (go)":          false,
+		"pci":                      true,
+		"!pci":                     false,
+		"!deprecated":              true,
+		"pci=":                     false,
+		"":                         true,
+	} {
+		sel, err := Parse(selector)
+		require.NoError(t, err, selector)
+		assert.Equal(t, want, sel.Matches(labels, tags), selector)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, ok := range []string{"tier", "app.kubernetes.io/name", "a", "under_score"} {
+		assert.NoError(t, ValidateKey(ok), ok)
+	}
+	for _, bad := range []string{"", "-x", "x-", "Upper.Case/x", "/x", "a b", string(make([]byte, 64))} {
+		assert.Error(t, ValidateKey(bad), bad)
+	}
+}
@@ -1,32 +1,56 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
 	"github.com/codecrafted007/service-catalog-api/internal/utils"
+	"github.com/codecrafted007/service-catalog-api/model"
 )
 
-func APIKeyAuth(validateKeyFunc func(string) bool) func(http.Handler) http.Handler {
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext returns the key that authenticated the request, or nil
+// when the request did not pass through APIKeyAuth.
+func APIKeyFromContext(ctx context.Context) *model.APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*model.APIKey)
+	return key
+}
+
+// WithAPIKey returns a copy of ctx carrying key. Writes made with it are
+// audited under the key's name.
+func WithAPIKey(ctx context.Context, key *model.APIKey) context.Context {
+	ctx = storage.WithActor(ctx, key.Name)
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+func APIKeyAuth(lookupKeyFunc func(string) (*model.APIKey, error)) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			AuthMiddleware(w, r, next, validateKeyFunc)
+			AuthMiddleware(w, r, next, lookupKeyFunc)
 		})
 	}
 }
 
-func AuthMiddleware(w http.ResponseWriter, r *http.Request, next http.Handler, validateKeyFunc func(string) bool) {
+func AuthMiddleware(w http.ResponseWriter, r *http.Request, next http.Handler, lookupKeyFunc func(string) (*model.APIKey, error)) {
 	authHeader := r.Header.Get("X-API-Key")
 	if authHeader == "" {
-		utils.WriteJSON(w, http.StatusUnauthorized, nil, "API key is missing")
+		utils.WriteError(w, r, http.StatusUnauthorized, "API key is missing")
 		return
 	}
 
 	apiKey := strings.TrimSpace(authHeader)
-	if !validateKeyFunc(apiKey) {
-		utils.WriteJSON(w, http.StatusForbidden, nil, "Invalid API key")
+	key, err := lookupKeyFunc(apiKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.WriteError(w, r, http.StatusForbidden, "Invalid API key")
+		return
+	} else if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to check API key")
 		return
 	}
 
-	next.ServeHTTP(w, r)
+	next.ServeHTTP(w, r.WithContext(WithAPIKey(r.Context(), key)))
 }
@@ -0,0 +1,337 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fileNamePattern matches migration files such as 0002_add_owner.up.sql.
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+const createTrackingTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+// Migration is a single numbered schema change with its rollback.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a known migration has been applied.
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Step is Go code run as part of a migration's up direction, in its
+// transaction, for data changes SQL alone cannot make.
+type Step func(ctx context.Context, tx *sqlx.Tx) error
+
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+	steps      map[int]Step
+}
+
+// New loads the migrations for driver from fsys, which is expected to hold
+// one directory per driver (sqlite3/, postgres/, mysql/).
+func New(db *sqlx.DB, driver string, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys, driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, steps: make(map[int]Step)}, nil
+}
+
+// AfterUp runs step after the up script of migration version, in the same
+// transaction. Steps are not part of Schema, which only holds the DDL.
+func (m *Migrator) AfterUp(version int, step Step) {
+	m.steps[version] = step
+}
+
+// Load reads and orders the migrations for driver. Every version must have
+// both an up and a down file.
+func Load(fsys fs.FS, driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, driver)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %s: %w", driver, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(driver, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing its up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Schema concatenates the up scripts for driver, i.e. the DDL a fresh
+// database ends up with.
+func Schema(fsys fs.FS, driver string) (string, error) {
+	migrations, err := Load(fsys, driver)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, m := range migrations {
+		fmt.Fprintf(&b, "-- %04d_%s\n", m.Version, m.Name)
+		b.WriteString(strings.TrimSpace(m.Up))
+		b.WriteString("\n\n")
+	}
+	return b.String(), nil
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Current returns the highest applied migration version, 0 for a fresh DB.
+func (m *Migrator) Current(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.migrateTo(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	current, err := m.Current(ctx)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
+	}
+	target := 0
+	for _, mig := range m.migrations {
+		if mig.Version < current {
+			target = mig.Version
+		}
+	}
+	_, err = m.migrateTo(ctx, target)
+	return err
+}
+
+// To migrates up or down until target is the highest applied version.
+func (m *Migrator) To(ctx context.Context, target int) (int, error) {
+	if target != 0 && !m.known(target) {
+		return 0, fmt.Errorf("unknown migration version %d", target)
+	}
+	return m.migrateTo(ctx, target)
+}
+
+// Status lists every known migration along with when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			st.Applied = true
+			appliedAt := at
+			st.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) known(version int) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Migrator) migrateTo(ctx context.Context, target int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok || mig.Version > target {
+			continue
+		}
+		if err := m.apply(ctx, mig, true); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok || mig.Version <= target {
+			continue
+		}
+		if err := m.apply(ctx, mig, false); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// apply runs one direction of a migration together with its bookkeeping row
+// in a single transaction. MySQL commits DDL implicitly, so a failure there
+// can leave a partially applied migration that needs manual cleanup.
+func (m *Migrator) apply(ctx context.Context, mig Migration, up bool) error {
+	script, direction := mig.Up, "up"
+	if !up {
+		script, direction = mig.Down, "down"
+	}
+
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range SplitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %04d_%s (%s) failed: %w", mig.Version, mig.Name, direction, err)
+		}
+	}
+	if step := m.steps[mig.Version]; up && step != nil {
+		if err := step(ctx, tx); err != nil {
+			return fmt.Errorf("migration %04d_%s (%s) failed: %w", mig.Version, mig.Name, direction, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, m.db.Rebind(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`), mig.Version, mig.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, m.db.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	if _, err := m.db.ExecContext(ctx, createTrackingTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := m.db.SelectContext(ctx, &rows, `SELECT version, applied_at FROM schema_migrations`); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r.AppliedAt
+	}
+	return applied, nil
+}
+
+// SplitStatements breaks a script into statements terminated by a semicolon
+// at the end of a line. Drivers differ in whether they accept several
+// statements per Exec (MySQL does not by default), so they are always run
+// one at a time.
+func SplitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		hasSQL     bool
+	)
+	flush := func() {
+		if hasSQL {
+			statements = append(statements, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+		hasSQL = false
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		hasSQL = true
+		if strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	flush()
+	return statements
+}
@@ -0,0 +1,111 @@
+package migrations
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = fstest.MapFS{
+	"sqlite3/0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER PRIMARY KEY);")},
+	"sqlite3/0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	"sqlite3/0002_create_b.up.sql": {Data: []byte(`-- two statements; one file
+CREATE TABLE b (id INTEGER PRIMARY KEY);
+CREATE INDEX idx_b_id ON b(id);`)},
+	"sqlite3/0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, *sqlx.DB) {
+	t.Helper()
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, "sqlite3", testMigrations)
+	require.NoError(t, err)
+	return m, db
+}
+
+func tableExists(t *testing.T, db *sqlx.DB, name string) bool {
+	t.Helper()
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name))
+	return count == 1
+}
+
+func TestUpDownAndTo(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, applied)
+	assert.True(t, tableExists(t, db, "b"))
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, applied, "second run must be a no-op")
+
+	require.NoError(t, m.Down(ctx))
+	current, err := m.Current(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, current)
+	assert.False(t, tableExists(t, db, "b"))
+	assert.True(t, tableExists(t, db, "a"))
+
+	_, err = m.To(ctx, 0)
+	require.NoError(t, err)
+	assert.False(t, tableExists(t, db, "a"))
+
+	_, err = m.To(ctx, 2)
+	require.NoError(t, err)
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.True(t, statuses[1].Applied)
+
+	_, err = m.To(ctx, 7)
+	assert.Error(t, err)
+}
+
+func TestAfterUp(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t)
+	m.AfterUp(1, func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO a (id) VALUES (1)`)
+		return err
+	})
+	m.AfterUp(2, func(context.Context, *sqlx.Tx) error {
+		return assert.AnError
+	})
+
+	// A failing step rolls back its migration with it.
+	_, err := m.Up(ctx)
+	assert.ErrorIs(t, err, assert.AnError)
+	current, err := m.Current(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, current)
+	assert.False(t, tableExists(t, db, "b"))
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM a`))
+	assert.Equal(t, 1, count)
+}
+
+func TestLoadRejectsMissingDown(t *testing.T) {
+	_, err := Load(fstest.MapFS{
+		"sqlite3/0001_only_up.up.sql": {Data: []byte("SELECT 1;")},
+	}, "sqlite3")
+	assert.Error(t, err)
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := SplitStatements("-- comment; with semicolon\nCREATE TABLE x (\n  id INT\n);\n\nDROP TABLE y")
+	assert.Equal(t, []string{"CREATE TABLE x (\n  id INT\n);", "DROP TABLE y"}, stmts)
+}
@@ -0,0 +1,54 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Cases from RFC 7386 appendix A.
+	for _, tc := range []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+	} {
+		got, err := MergePatch([]byte(tc.doc), []byte(tc.patch))
+		require.NoError(t, err)
+		assert.JSONEq(t, tc.want, string(got), tc.patch)
+	}
+
+	_, err := MergePatch([]byte(`{}`), []byte(`{"a":`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"name":"payments","tags":["a","b"],"meta":{"x":1}}`
+	for _, tc := range []struct{ patch, want string }{
+		{`[{"op":"replace","path":"/name","value":"billing"}]`, `{"name":"billing","tags":["a","b"],"meta":{"x":1}}`},
+		{`[{"op":"add","path":"/tags/1","value":"z"}]`, `{"name":"payments","tags":["a","z","b"],"meta":{"x":1}}`},
+		{`[{"op":"add","path":"/tags/-","value":"c"}]`, `{"name":"payments","tags":["a","b","c"],"meta":{"x":1}}`},
+		{`[{"op":"remove","path":"/tags/0"}]`, `{"name":"payments","tags":["b"],"meta":{"x":1}}`},
+		{`[{"op":"move","from":"/meta/x","path":"/x"}]`, `{"name":"payments","tags":["a","b"],"meta":{},"x":1}`},
+		{`[{"op":"copy","from":"/tags","path":"/meta/tags"}]`, `{"name":"payments","tags":["a","b"],"meta":{"x":1,"tags":["a","b"]}}`},
+		{`[{"op":"test","path":"/meta/x","value":1},{"op":"add","path":"/a~1b","value":null}]`, `{"name":"payments","tags":["a","b"],"meta":{"x":1},"a/b":null}`},
+	} {
+		got, err := JSONPatch([]byte(doc), []byte(tc.patch))
+		require.NoError(t, err, tc.patch)
+		assert.JSONEq(t, tc.want, string(got), tc.patch)
+	}
+
+	_, err := JSONPatch([]byte(doc), []byte(`[{"op":"test","path":"/name","value":"other"}]`))
+	assert.ErrorIs(t, err, ErrTestFailed)
+	_, err = JSONPatch([]byte(doc), []byte(`[{"op":"remove","path":"/missing"}]`))
+	assert.ErrorIs(t, err, ErrPathNotFound)
+	_, err = JSONPatch([]byte(doc), []byte(`[{"op":"frobnicate","path":"/name"}]`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+	_, err = JSONPatch([]byte(doc), []byte(`[{"op":"replace","path":"/name"}]`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
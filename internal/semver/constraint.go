@@ -0,0 +1,316 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a version range in the npm/Cargo style:
+//
+//	^1.4        >=1.4.0 <2.0.0
+//	~1.4.2      >=1.4.2 <1.5.0
+//	1.2.x, 1.2  >=1.2.0 <1.3.0
+//	>=1.2 <1.5  comparators separated by spaces or commas must all hold
+//	1.2 - 1.4   hyphen range, inclusive
+//	^1 || ^2    either range
+//
+// A bare full version such as 1.2.3 is an exact match, as in npm. Prerelease
+// versions only match when a comparator in the same range names a
+// prerelease of the same MAJOR.MINOR.PATCH, so ^1.4.0 never picks 1.5.0-rc.1
+// but >=1.5.0-rc.0 does.
+type Constraint struct {
+	raw  string
+	sets [][]comparator
+}
+
+type operator int
+
+const (
+	opEQ operator = iota
+	opGT
+	opGTE
+	opLT
+	opLTE
+)
+
+type comparator struct {
+	op      operator
+	version Version
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case opGT:
+		return cmp > 0
+	case opGTE:
+		return cmp >= 0
+	case opLT:
+		return cmp < 0
+	case opLTE:
+		return cmp <= 0
+	}
+	return cmp == 0
+}
+
+// ParseConstraint parses a range expression, see Constraint.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		set, err := parseRange(alt)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Check reports whether v satisfies the constraint.
+func (c Constraint) Check(v Version) bool {
+	for _, set := range c.sets {
+		if setMatches(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func setMatches(set []comparator, v Version) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	if !v.IsPrerelease() {
+		return true
+	}
+	for _, c := range set {
+		cv := c.version
+		if cv.IsPrerelease() && cv.Major == v.Major && cv.Minor == v.Minor && cv.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRange(s string) ([]comparator, error) {
+	tokens := tokenize(s)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty range")
+	}
+
+	if len(tokens) == 3 && tokens[1] == "-" {
+		lo, err := parsePartial(tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		hi, err := parsePartial(tokens[2])
+		if err != nil {
+			return nil, err
+		}
+		return append(expand(opGTE, lo), expand(opLTE, hi)...), nil
+	}
+
+	var set []comparator
+	for _, tok := range tokens {
+		if tok == "-" {
+			return nil, fmt.Errorf("misplaced hyphen range")
+		}
+		cs, err := parseComparator(tok)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+// tokenize splits a range on spaces and commas and glues operators written
+// apart from their version (">= 1.2") back together.
+func tokenize(s string) []string {
+	fields := strings.Fields(strings.ReplaceAll(s, ",", " "))
+	var tokens []string
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.Trim(f, "<>=~^") == "" && f != "-" && i+1 < len(fields) {
+			f += fields[i+1]
+			i++
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+func parseComparator(tok string) ([]comparator, error) {
+	var prefix string
+	for _, p := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(tok, p) {
+			prefix = p
+			break
+		}
+	}
+	p, err := parsePartial(strings.TrimPrefix(tok, prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	switch prefix {
+	case "^":
+		return caret(p), nil
+	case "~":
+		return tilde(p), nil
+	case ">=":
+		return expand(opGTE, p), nil
+	case ">":
+		return expand(opGT, p), nil
+	case "<=":
+		return expand(opLTE, p), nil
+	case "<":
+		return expand(opLT, p), nil
+	}
+	return expand(opEQ, p), nil
+}
+
+// partial is a version that may leave out or wildcard its trailing parts.
+type partial struct {
+	parts []uint64 // 0 to 3 known components
+	pre   []string
+}
+
+func (p partial) version() Version {
+	v := Version{Prerelease: p.pre}
+	nums := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, n := range p.parts {
+		*nums[i] = n
+	}
+	return v
+}
+
+// bump returns the smallest version above every version matching the first
+// n components of p.
+func (p partial) bump(n int) Version {
+	v := p.version()
+	v.Prerelease = nil
+	switch n {
+	case 1:
+		return Version{Major: v.Major + 1}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+func parsePartial(s string) (partial, error) {
+	var p partial
+	full := s
+	// One "v" or "V" at most, as Parse allows.
+	if s != "" && (s[0] == 'v' || s[0] == 'V') {
+		s = s[1:]
+	}
+	if s == "" {
+		return p, fmt.Errorf("missing version")
+	}
+	if isWildcard(s) {
+		return p, nil
+	}
+
+	if strings.ContainsAny(s, "-+") {
+		// Prerelease and build only make sense on a full version.
+		v, err := Parse(full)
+		if err != nil {
+			return p, err
+		}
+		return partial{parts: []uint64{v.Major, v.Minor, v.Patch}, pre: v.Prerelease}, nil
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return p, fmt.Errorf("%q has too many components", s)
+	}
+	for _, f := range fields {
+		if isWildcard(f) {
+			break
+		}
+		n, err := parseNumeric(f)
+		if err != nil {
+			return p, err
+		}
+		p.parts = append(p.parts, n)
+	}
+	return p, nil
+}
+
+func isWildcard(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
+
+// expand turns an operator on a partial version into comparators on full
+// versions, e.g. <=1.2 becomes <1.3.0 and 1.2 becomes >=1.2.0 <1.3.0.
+func expand(op operator, p partial) []comparator {
+	n := len(p.parts)
+	if n == 3 {
+		return []comparator{{op, p.version()}}
+	}
+	if n == 0 {
+		// Wildcard: anything for =, >=, <=; nothing for > and <.
+		if op == opGT || op == opLT {
+			return []comparator{{opLT, Version{}}}
+		}
+		return []comparator{{opGTE, Version{}}}
+	}
+
+	lo, hi := p.version(), p.bump(n)
+	switch op {
+	case opGT:
+		return []comparator{{opGTE, hi}}
+	case opLTE:
+		return []comparator{{opLT, hi}}
+	case opGTE:
+		return []comparator{{opGTE, lo}}
+	case opLT:
+		return []comparator{{opLT, lo}}
+	}
+	return []comparator{{opGTE, lo}, {opLT, hi}}
+}
+
+// caret allows changes that do not modify the left-most non-zero component.
+func caret(p partial) []comparator {
+	if len(p.parts) == 0 {
+		return expand(opGTE, p)
+	}
+	lo := p.version()
+	n := len(p.parts)
+	var hi Version
+	switch {
+	case p.parts[0] > 0 || n == 1:
+		hi = p.bump(1)
+	case n == 2 || p.parts[1] > 0:
+		hi = p.bump(2)
+	default:
+		hi = p.bump(3)
+	}
+	return []comparator{{opGTE, lo}, {opLT, hi}}
+}
+
+// tilde allows patch-level changes, or minor-level ones when only the major
+// version is given.
+func tilde(p partial) []comparator {
+	switch len(p.parts) {
+	case 0:
+		return expand(opGTE, p)
+	case 1:
+		return []comparator{{opGTE, p.version()}, {opLT, p.bump(1)}}
+	}
+	return []comparator{{opGTE, p.version()}, {opLT, p.bump(2)}}
+}
+
+// Satisfies is a convenience for callers holding plain strings.
+func Satisfies(version string, c Constraint) bool {
+	v, err := Parse(version)
+	return err == nil && c.Check(v)
+}
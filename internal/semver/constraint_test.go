@@ -0,0 +1,47 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraintCheck(t *testing.T) {
+	for _, tc := range []struct {
+		constraint string
+		match      []string
+		reject     []string
+	}{
+		{"^1.4", []string{"1.4.0", "v1.9.3"}, []string{"1.3.9", "2.0.0", "1.5.0-rc.1"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~1.4.2", []string{"1.4.2", "1.4.9"}, []string{"1.5.0", "1.4.1"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{"1.2.x", []string{"1.2.0", "1.2.7"}, []string{"1.3.0"}},
+		{"1.2.3", []string{"1.2.3", "v1.2.3+build"}, []string{"1.2.4"}},
+		{">=1.2, <1.5", []string{"1.2.0", "1.4.9"}, []string{"1.5.0", "1.1.0"}},
+		{">= 1.2 <= 1.4", []string{"1.4.7"}, []string{"1.5.0"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"1.2 - 1.4", []string{"1.2.0", "1.4.5"}, []string{"1.5.0"}},
+		{"^1 || ^3", []string{"1.2.0", "3.0.0"}, []string{"2.0.0"}},
+		{"*", []string{"0.0.1", "9.0.0"}, []string{"1.0.0-beta"}},
+		{">=1.5.0-rc.0", []string{"1.5.0-rc.1", "1.5.0", "2.0.0"}, []string{"1.6.0-rc.1"}},
+	} {
+		c, err := ParseConstraint(tc.constraint)
+		require.NoError(t, err, tc.constraint)
+		for _, v := range tc.match {
+			assert.True(t, Satisfies(v, c), "%s should satisfy %s", v, tc.constraint)
+		}
+		for _, v := range tc.reject {
+			assert.False(t, Satisfies(v, c), "%s should not satisfy %s", v, tc.constraint)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, bad := range []string{"", "^", ">=1.2.3.4", "1.2 -", "^1 ||", "~abc", "vV1.2", "^Vv1", ">=vV1.2.3-rc.1"} {
+		_, err := ParseConstraint(bad)
+		assert.Error(t, err, bad)
+	}
+}
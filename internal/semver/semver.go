@@ -0,0 +1,202 @@
+// Package semver parses and orders Semantic Versioning 2.0.0 strings.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version. The optional "v" prefix is accepted
+// on input but not kept, so String returns the canonical form.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      []string
+}
+
+// Parse accepts MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD] with an optional
+// leading "v" or "V".
+func Parse(s string) (Version, error) {
+	var v Version
+	rest := s
+	if rest != "" && (rest[0] == 'v' || rest[0] == 'V') {
+		rest = rest[1:]
+	}
+
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		build, err := splitIdentifiers(rest[i+1:], false)
+		if err != nil {
+			return v, fmt.Errorf("invalid build metadata in %q: %w", s, err)
+		}
+		v.Build = build
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		pre, err := splitIdentifiers(rest[i+1:], true)
+		if err != nil {
+			return v, fmt.Errorf("invalid prerelease in %q: %w", s, err)
+		}
+		v.Prerelease = pre
+		rest = rest[:i]
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("%q is not a semantic version (want MAJOR.MINOR.PATCH)", s)
+	}
+	nums := make([]uint64, 3)
+	for i, p := range parts {
+		n, err := parseNumeric(p)
+		if err != nil {
+			return v, fmt.Errorf("%q is not a semantic version: %w", s, err)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// MustParse is Parse for constants in tests and tables; it panics on error.
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if len(v.Build) > 0 {
+		s += "+" + strings.Join(v.Build, ".")
+	}
+	return s
+}
+
+// IsPrerelease reports whether v has prerelease identifiers.
+func (v Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Compare returns -1, 0 or +1 following semver precedence. Build metadata
+// is ignored.
+func (v Version) Compare(o Version) int {
+	if c := cmpUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := cmpUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := cmpUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// A release has higher precedence than any of its prereleases.
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := compareIdentifier(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpInt(len(v.Prerelease), len(o.Prerelease))
+}
+
+// CompareStrings orders arbitrary version strings: valid semantic versions
+// by precedence, and above anything that does not parse, which falls back to
+// plain string order. It is meant for sorting legacy data, not validation.
+func CompareStrings(a, b string) int {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	switch {
+	case errA == nil && errB == nil:
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	case errA == nil:
+		return 1
+	case errB == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareIdentifier(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return cmpUint(na, nb)
+	case errA == nil:
+		// Numeric identifiers have lower precedence than alphanumeric ones.
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func splitIdentifiers(s string, prerelease bool) ([]string, error) {
+	ids := strings.Split(s, ".")
+	for _, id := range ids {
+		if id == "" {
+			return nil, fmt.Errorf("empty identifier")
+		}
+		for _, r := range id {
+			if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+				return nil, fmt.Errorf("invalid character %q in identifier %q", r, id)
+			}
+		}
+		if prerelease && isDigits(id) && len(id) > 1 && id[0] == '0' {
+			return nil, fmt.Errorf("numeric identifier %q has a leading zero", id)
+		}
+	}
+	return ids, nil
+}
+
+func parseNumeric(s string) (uint64, error) {
+	if s == "" || !isDigits(s) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("%q has a leading zero", s)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func cmpInt(a, b int) int {
+	return cmpUint(uint64(a), uint64(b))
+}
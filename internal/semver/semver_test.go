@@ -0,0 +1,40 @@
+package semver
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.10.0-rc.1+build.5")
+	require.NoError(t, err)
+	assert.Equal(t, Version{Major: 1, Minor: 10, Prerelease: []string{"rc", "1"}, Build: []string{"build", "5"}}, v)
+	assert.Equal(t, "1.10.0-rc.1+build.5", v.String())
+
+	for _, bad := range []string{"", "v1", "1.2", "1.2.3.4", "01.2.3", "1.2.3-", "1.2.3-01", "1.2.3+", "1.2.x", "1.2.3-rc_1", "vV1.2.3", "vv1.2.3"} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	// Ascending order from the semver 2.0.0 spec, plus the v1.9/v1.10 case.
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0",
+		"v1.9.0", "v1.10.0", "2.0.0",
+	}
+	for i := 1; i < len(ordered); i++ {
+		assert.Equal(t, -1, MustParse(ordered[i-1]).Compare(MustParse(ordered[i])), "%s < %s", ordered[i-1], ordered[i])
+	}
+	assert.Zero(t, MustParse("1.0.0+a").Compare(MustParse("v1.0.0+b")))
+}
+
+func TestCompareStringsPutsLegacyLast(t *testing.T) {
+	versions := []string{"v1.10.0", "latest", "v1.9.0", "v2.0.0-rc.1"}
+	sort.Slice(versions, func(i, j int) bool { return CompareStrings(versions[i], versions[j]) < 0 })
+	assert.Equal(t, []string{"latest", "v1.9.0", "v1.10.0", "v2.0.0-rc.1"}, versions)
+}
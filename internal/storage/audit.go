@@ -0,0 +1,446 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+// AuditFilter narrows ListAuditEvents; zero fields match every event.
+type AuditFilter struct {
+	EntityType string
+	// EntityID only applies together with EntityType.
+	EntityID int64
+	Actor    string
+	// Since keeps the events recorded at or after it.
+	Since time.Time
+}
+
+type actorContextKey struct{}
+
+type requestIDContextKey struct{}
+
+// WithActor returns a copy of ctx whose writes are audited as made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// WithRequestID returns a copy of ctx whose writes are audited as part of
+// the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+func actorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// RecordFreezeOverride records that a change to the service went ahead
+// during freeze f, and the reason given for it, as an override event on the
+// freeze. Call it in the transaction of the change, so the event is only
+// kept if the change is.
+func RecordFreezeOverride(ctx context.Context, tx Storage, f model.Freeze, serviceID int64, reason string) error {
+	after, err := json.Marshal(struct {
+		ServiceID int64  `json:"serviceId"`
+		Reason    string `json:"reason"`
+	}{serviceID, reason})
+	if err != nil {
+		return err
+	}
+	return tx.AddAuditEvent(ctx, &model.AuditEvent{
+		Actor:      actorFromContext(ctx),
+		Action:     model.AuditOverride,
+		EntityType: "freeze",
+		EntityID:   f.ID,
+		After:      after,
+		RequestID:  requestIDFromContext(ctx),
+		CreatedAt:  time.Now().UTC(),
+	})
+}
+
+// auditStore records an audit event for every create, update and delete,
+// in the same transaction as the write. Open wraps each backend in one, so
+// the backends only store and list events.
+//
+// An event covers what the storage method did as a whole: deleting a
+// service records the service, not each version that went with it. Updates
+// that leave the record unchanged are not recorded.
+//
+// Every write method added to Storage must be overridden here too: the
+// embedded Storage would otherwise pass it straight to the backend. The
+// compiler cannot tell, but TestAuditStoreCoversWrites fails until the
+// method is overridden or listed there as a read.
+type auditStore struct {
+	Storage
+}
+
+func (a *auditStore) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return a.Storage.WithTx(ctx, func(tx Storage) error {
+		return fn(&auditStore{tx})
+	})
+}
+
+// loader reads the record an event is about, for its snapshots.
+type loader func(ctx context.Context, tx Storage, id int64) (interface{}, error)
+
+// record runs write and records it as action on the entity with the given
+// ID, or, when id is 0, on the one whose ID write returns. The record is
+// loaded before and after the write; an update of a record that did not
+// exist yet is recorded as a create.
+func (a *auditStore) record(ctx context.Context, action model.AuditAction, entity string, id int64, load loader, write func(tx Storage) (int64, error)) (int64, error) {
+	err := a.Storage.WithTx(ctx, func(tx Storage) error {
+		var before, after model.Snapshot
+		var err error
+		if id != 0 {
+			if before, err = snapshot(ctx, tx, load, id); err != nil {
+				return err
+			}
+		}
+		created, err := write(tx)
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			id = created
+		}
+		if action != model.AuditDelete {
+			if after, err = snapshot(ctx, tx, load, id); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case action == model.AuditUpdate && before == nil:
+			action = model.AuditCreate
+		case action == model.AuditUpdate && bytes.Equal(before, after):
+			return nil
+		}
+		return tx.AddAuditEvent(ctx, &model.AuditEvent{
+			Actor:      actorFromContext(ctx),
+			Action:     action,
+			EntityType: entity,
+			EntityID:   id,
+			Before:     before,
+			After:      after,
+			RequestID:  requestIDFromContext(ctx),
+			CreatedAt:  time.Now().UTC(),
+		})
+	})
+	return id, err
+}
+
+// snapshot loads a record as JSON, or nil when it does not exist.
+func snapshot(ctx context.Context, tx Storage, load loader, id int64) (model.Snapshot, error) {
+	v, err := load(ctx, tx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// update and remove adapt writes that return no ID to record.
+func (a *auditStore) update(ctx context.Context, entity string, id int64, load loader, write func(tx Storage) error) error {
+	_, err := a.record(ctx, model.AuditUpdate, entity, id, load, func(tx Storage) (int64, error) {
+		return 0, write(tx)
+	})
+	return err
+}
+
+func (a *auditStore) remove(ctx context.Context, entity string, id int64, load loader, write func(tx Storage) error) error {
+	_, err := a.record(ctx, model.AuditDelete, entity, id, load, func(tx Storage) (int64, error) {
+		return 0, write(tx)
+	})
+	return err
+}
+
+func loadService(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetServiceById(ctx, int(id))
+}
+
+func loadVersion(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetVersionByID(ctx, id)
+}
+
+func loadTeam(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetTeam(ctx, id)
+}
+
+func loadDependency(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetDependency(ctx, id)
+}
+
+func loadEnvironment(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetEnvironment(ctx, id)
+}
+
+func loadDeployment(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetDeployment(ctx, id)
+}
+
+func loadPromotionRule(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetPromotionRule(ctx, id)
+}
+
+func loadApproval(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetApproval(ctx, id)
+}
+
+func loadApprovalPolicy(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetApprovalPolicy(ctx, id)
+}
+
+func loadApprovalRequest(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetApprovalRequest(ctx, id)
+}
+
+func loadFreeze(ctx context.Context, tx Storage, id int64) (interface{}, error) {
+	return tx.GetFreeze(ctx, id)
+}
+
+func (a *auditStore) CreateService(ctx context.Context, s *model.Service) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "service", 0, loadService, func(tx Storage) (int64, error) {
+		return tx.CreateService(ctx, s)
+	})
+}
+
+func (a *auditStore) UpdateService(ctx context.Context, id int, s *model.Service) error {
+	return a.update(ctx, "service", int64(id), loadService, func(tx Storage) error {
+		return tx.UpdateService(ctx, id, s)
+	})
+}
+
+func (a *auditStore) PatchService(ctx context.Context, id int, p ServicePatch) error {
+	return a.update(ctx, "service", int64(id), loadService, func(tx Storage) error {
+		return tx.PatchService(ctx, id, p)
+	})
+}
+
+func (a *auditStore) DeleteService(ctx context.Context, id int, revision int64) error {
+	return a.remove(ctx, "service", int64(id), loadService, func(tx Storage) error {
+		return tx.DeleteService(ctx, id, revision)
+	})
+}
+
+func (a *auditStore) CreateVersion(ctx context.Context, v *model.Version) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "version", 0, loadVersion, func(tx Storage) (int64, error) {
+		return tx.CreateVersion(ctx, v)
+	})
+}
+
+func (a *auditStore) UpdateVersion(ctx context.Context, versionID int64, v *model.Version) error {
+	return a.update(ctx, "version", versionID, loadVersion, func(tx Storage) error {
+		return tx.UpdateVersion(ctx, versionID, v)
+	})
+}
+
+func (a *auditStore) DeleteVersionByID(ctx context.Context, versionID int64, revision int64) error {
+	return a.remove(ctx, "version", versionID, loadVersion, func(tx Storage) error {
+		return tx.DeleteVersionByID(ctx, versionID, revision)
+	})
+}
+
+func (a *auditStore) TransitionVersion(ctx context.Context, versionID int64, to model.VersionState, reason string, revision int64) error {
+	return a.update(ctx, "version", versionID, loadVersion, func(tx Storage) error {
+		return tx.TransitionVersion(ctx, versionID, to, reason, revision)
+	})
+}
+
+func (a *auditStore) CreateTeam(ctx context.Context, t *model.Team) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "team", 0, loadTeam, func(tx Storage) (int64, error) {
+		return tx.CreateTeam(ctx, t)
+	})
+}
+
+func (a *auditStore) UpdateTeam(ctx context.Context, id int64, t *model.Team) error {
+	return a.update(ctx, "team", id, loadTeam, func(tx Storage) error {
+		return tx.UpdateTeam(ctx, id, t)
+	})
+}
+
+func (a *auditStore) DeleteTeam(ctx context.Context, id int64) error {
+	return a.remove(ctx, "team", id, loadTeam, func(tx Storage) error {
+		return tx.DeleteTeam(ctx, id)
+	})
+}
+
+// Ownership, labels and tags are part of the service, so changing them is
+// recorded as an update of the service.
+
+func (a *auditStore) SetServiceOwnership(ctx context.Context, serviceID int64, o OwnershipUpdate) error {
+	return a.update(ctx, "service", serviceID, loadService, func(tx Storage) error {
+		return tx.SetServiceOwnership(ctx, serviceID, o)
+	})
+}
+
+func (a *auditStore) SetServiceLabel(ctx context.Context, serviceID int64, key, value string, revision int64) error {
+	return a.update(ctx, "service", serviceID, loadService, func(tx Storage) error {
+		return tx.SetServiceLabel(ctx, serviceID, key, value, revision)
+	})
+}
+
+func (a *auditStore) RemoveServiceLabel(ctx context.Context, serviceID int64, key string, revision int64) error {
+	return a.update(ctx, "service", serviceID, loadService, func(tx Storage) error {
+		return tx.RemoveServiceLabel(ctx, serviceID, key, revision)
+	})
+}
+
+func (a *auditStore) AddServiceTag(ctx context.Context, serviceID int64, tag string, revision int64) error {
+	return a.update(ctx, "service", serviceID, loadService, func(tx Storage) error {
+		return tx.AddServiceTag(ctx, serviceID, tag, revision)
+	})
+}
+
+func (a *auditStore) RemoveServiceTag(ctx context.Context, serviceID int64, tag string, revision int64) error {
+	return a.update(ctx, "service", serviceID, loadService, func(tx Storage) error {
+		return tx.RemoveServiceTag(ctx, serviceID, tag, revision)
+	})
+}
+
+func (a *auditStore) AddDependency(ctx context.Context, d *model.Dependency) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "dependency", 0, loadDependency, func(tx Storage) (int64, error) {
+		return tx.AddDependency(ctx, d)
+	})
+}
+
+func (a *auditStore) RemoveDependency(ctx context.Context, serviceID, dependsOnID int64) error {
+	return a.Storage.WithTx(ctx, func(tx Storage) error {
+		edges, err := tx.ListAllDependencies(ctx)
+		if err != nil {
+			return err
+		}
+		for _, d := range edges {
+			if d.ServiceID == serviceID && d.DependsOnID == dependsOnID {
+				return (&auditStore{tx}).remove(ctx, "dependency", d.ID, loadDependency, func(tx Storage) error {
+					return tx.RemoveDependency(ctx, serviceID, dependsOnID)
+				})
+			}
+		}
+		// No such edge: nothing to record, and the backend reports it.
+		return tx.RemoveDependency(ctx, serviceID, dependsOnID)
+	})
+}
+
+func (a *auditStore) CreateEnvironment(ctx context.Context, e *model.Environment) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "environment", 0, loadEnvironment, func(tx Storage) (int64, error) {
+		return tx.CreateEnvironment(ctx, e)
+	})
+}
+
+func (a *auditStore) DeleteEnvironment(ctx context.Context, id int64) error {
+	return a.remove(ctx, "environment", id, loadEnvironment, func(tx Storage) error {
+		return tx.DeleteEnvironment(ctx, id)
+	})
+}
+
+func (a *auditStore) CreateDeployment(ctx context.Context, d *model.Deployment) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "deployment", 0, loadDeployment, func(tx Storage) (int64, error) {
+		return tx.CreateDeployment(ctx, d)
+	})
+}
+
+func (a *auditStore) SetPromotionRule(ctx context.Context, r *model.PromotionRule) error {
+	return a.update(ctx, "promotion_rule", r.EnvironmentID, loadPromotionRule, func(tx Storage) error {
+		return tx.SetPromotionRule(ctx, r)
+	})
+}
+
+func (a *auditStore) DeletePromotionRule(ctx context.Context, environmentID int64) error {
+	return a.remove(ctx, "promotion_rule", environmentID, loadPromotionRule, func(tx Storage) error {
+		return tx.DeletePromotionRule(ctx, environmentID)
+	})
+}
+
+func (a *auditStore) AddApproval(ctx context.Context, ap *model.Approval) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "approval", 0, loadApproval, func(tx Storage) (int64, error) {
+		return tx.AddApproval(ctx, ap)
+	})
+}
+
+func (a *auditStore) PromoteVersion(ctx context.Context, versionID int64, d *model.Deployment) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "deployment", 0, loadDeployment, func(tx Storage) (int64, error) {
+		return tx.PromoteVersion(ctx, versionID, d)
+	})
+}
+
+func (a *auditStore) CreateApprovalPolicy(ctx context.Context, p *model.ApprovalPolicy) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "approval_policy", 0, loadApprovalPolicy, func(tx Storage) (int64, error) {
+		return tx.CreateApprovalPolicy(ctx, p)
+	})
+}
+
+func (a *auditStore) DeleteApprovalPolicy(ctx context.Context, id int64) error {
+	return a.remove(ctx, "approval_policy", id, loadApprovalPolicy, func(tx Storage) error {
+		return tx.DeleteApprovalPolicy(ctx, id)
+	})
+}
+
+func (a *auditStore) CreateApprovalRequest(ctx context.Context, req *model.ApprovalRequest) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "approval_request", 0, loadApprovalRequest, func(tx Storage) (int64, error) {
+		return tx.CreateApprovalRequest(ctx, req)
+	})
+}
+
+// ReviewApprovalRequest also records the release of the version when the
+// review approves the request.
+func (a *auditStore) ReviewApprovalRequest(ctx context.Context, id int64, review *model.ApprovalReview) error {
+	return a.update(ctx, "approval_request", id, loadApprovalRequest, func(tx Storage) error {
+		req, err := tx.GetApprovalRequest(ctx, id)
+		if err != nil {
+			return err
+		}
+		return (&auditStore{tx}).update(ctx, "version", req.VersionID, loadVersion, func(tx Storage) error {
+			return tx.ReviewApprovalRequest(ctx, id, review)
+		})
+	})
+}
+
+func (a *auditStore) CreateFreeze(ctx context.Context, f *model.Freeze) (int64, error) {
+	return a.record(ctx, model.AuditCreate, "freeze", 0, loadFreeze, func(tx Storage) (int64, error) {
+		return tx.CreateFreeze(ctx, f)
+	})
+}
+
+func (a *auditStore) DeleteFreeze(ctx context.Context, id int64) error {
+	return a.remove(ctx, "freeze", id, loadFreeze, func(tx Storage) error {
+		return tx.DeleteFreeze(ctx, id)
+	})
+}
+
+// CreateAPIKey records the key's name and whether it is elevated, never the
+// key itself.
+func (a *auditStore) CreateAPIKey(ctx context.Context, key, name string, elevated bool) error {
+	_, err := a.record(ctx, model.AuditCreate, "api_key", 0, loadAPIKeyName(name, elevated), func(tx Storage) (int64, error) {
+		if err := tx.CreateAPIKey(ctx, key, name, elevated); err != nil {
+			return 0, err
+		}
+		k, err := tx.GetAPIKey(key)
+		if err != nil {
+			return 0, err
+		}
+		return k.ID, nil
+	})
+	return err
+}
+
+// loadAPIKeyName snapshots an API key as its name and elevated flag; there
+// is no reading a key back by ID.
+func loadAPIKeyName(name string, elevated bool) loader {
+	return func(context.Context, Storage, int64) (interface{}, error) {
+		return struct {
+			Name     string `json:"name"`
+			Elevated bool   `json:"elevated"`
+		}{name, elevated}, nil
+	}
+}
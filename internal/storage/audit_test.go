@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unaudited lists the Storage methods auditStore passes straight to the
+// backend: the reads, and the audit log itself.
+var unaudited = map[string]bool{
+	"DB":                     true,
+	"ListServices":           true,
+	"GetServiceById":         true,
+	"GetAPIKey":              true,
+	"GetVersionsByServiceID": true,
+	"GetVersionByID":         true,
+	"GetLatestVersion":       true,
+	"LatestVersions":         true,
+	"ResolveVersion":         true,
+	"ListTeams":              true,
+	"GetTeam":                true,
+	"GetServiceOwnership":    true,
+	"GetDependency":          true,
+	"ListAllDependencies":    true,
+	"ListDependencies":       true,
+	"ListDependents":         true,
+	"ListEnvironments":       true,
+	"GetEnvironment":         true,
+	"GetDeployment":          true,
+	"ListDeployments":        true,
+	"ServiceEnvironments":    true,
+	"ListPromotionRules":     true,
+	"GetPromotionRule":       true,
+	"GetApproval":            true,
+	"ListApprovals":          true,
+	"ListApprovalPolicies":   true,
+	"GetApprovalPolicy":      true,
+	"RequiredApprovals":      true,
+	"GetApprovalRequest":     true,
+	"ListApprovalRequests":   true,
+	"ListFreezes":            true,
+	"GetFreeze":              true,
+	"ActiveFreezes":          true,
+	"AddAuditEvent":          true,
+	"ListAuditEvents":        true,
+}
+
+// Every Storage method is either a read listed above or overridden by
+// auditStore, so a new write cannot slip past the audit log through the
+// embedded Storage.
+func TestAuditStoreCoversWrites(t *testing.T) {
+	iface := reflect.TypeOf((*Storage)(nil)).Elem()
+	audit := reflect.TypeOf(&auditStore{})
+	for i := 0; i < iface.NumMethod(); i++ {
+		name := iface.Method(i).Name
+		if unaudited[name] {
+			continue
+		}
+		m, _ := audit.MethodByName(name)
+		// Methods promoted from the embedded Storage are compiler-generated.
+		file, _ := runtime.FuncForPC(m.Func.Pointer()).FileLine(m.Func.Pointer())
+		assert.NotEqual(t, "<autogenerated>", file, "auditStore must record %s, or list it as a read in unaudited", name)
+	}
+	for name := range unaudited {
+		_, ok := iface.MethodByName(name)
+		assert.True(t, ok, "unaudited lists %s, which Storage no longer has", name)
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import "github.com/codecrafted007/service-catalog-api/model"
+
+// DependencyEdge is a service_dependencies row seen from the Near end, with
+// the name of the service at the Far end.
+type DependencyEdge struct {
+	Near       int64  `db:"near"`
+	Far        int64  `db:"far"`
+	Name       string `db:"name"`
+	Constraint string `db:"version_constraint"`
+}
+
+// WalkDependencies walks the graph breadth-first from start for up to depth
+// levels (0 for no limit), asking edges for the edges leaving each level.
+// Every service other than start is reported once, at the depth it is
+// first reached, so cycles end the walk instead of looping.
+func WalkDependencies(start int64, depth int, edges func(frontier []int64) ([]DependencyEdge, error)) ([]model.DependencyNode, error) {
+	nodes := []model.DependencyNode{}
+	seen := map[int64]bool{start: true}
+	frontier := []int64{start}
+	for level := 1; len(frontier) > 0 && (depth == 0 || level <= depth); level++ {
+		next, err := edges(frontier)
+		if err != nil {
+			return nil, err
+		}
+		frontier = nil
+		for _, e := range next {
+			if seen[e.Far] {
+				continue
+			}
+			seen[e.Far] = true
+			frontier = append(frontier, e.Far)
+			nodes = append(nodes, model.DependencyNode{
+				ServiceID:  e.Far,
+				Name:       e.Name,
+				Depth:      level,
+				Via:        e.Near,
+				Constraint: e.Constraint,
+			})
+		}
+	}
+	return nodes, nil
+}
+
+// DependencyPath returns the names along the walk from the start of nodes
+// to target, excluding the start, or nil if the walk never reached target.
+func DependencyPath(nodes []model.DependencyNode, target int64) []string {
+	byID := make(map[int64]model.DependencyNode, len(nodes))
+	for _, n := range nodes {
+		byID[n.ServiceID] = n
+	}
+	var path []string
+	for n, ok := byID[target]; ok; n, ok = byID[n.Via] {
+		path = append([]string{n.Name}, path...)
+	}
+	return path
+}
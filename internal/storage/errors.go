@@ -0,0 +1,88 @@
+package storage
+
+import "errors"
+
+// Sentinel error kinds every backend reports. Callers should test for them
+// with errors.Is; backends wrap driver errors in *Error so the kind survives.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrInvalid            = errors.New("invalid")
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// Error carries a sentinel kind, a message that is safe to show to API
+// clients, and the underlying driver error (if any) for logging.
+type Error struct {
+	Kind error
+	Msg  string
+	Err  error
+}
+
+func NewError(kind error, msg string, cause error) *Error {
+	return &Error{Kind: kind, Msg: msg, Err: cause}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Msg
+	}
+	return e.Msg + ": " + e.Err.Error()
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError reports a write that would duplicate an existing record.
+// ExistingID is the ID of that record, or 0 when the backend only learned
+// about the duplicate from a constraint violation. It matches ErrConflict
+// with errors.Is.
+type ConflictError struct {
+	Entity     string
+	ExistingID int64
+	Err        error
+}
+
+func NewConflictError(entity string, existingID int64, cause error) *ConflictError {
+	return &ConflictError{Entity: entity, ExistingID: existingID, Err: cause}
+}
+
+func (e *ConflictError) Error() string {
+	if e.Err == nil {
+		return e.Entity + " already exists"
+	}
+	return e.Entity + " already exists: " + e.Err.Error()
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// GateError reports a change refused by one of its gates: the promotion
+// gates "path", "soak" and "approvals", or a change "freeze". It matches
+// ErrConflict with errors.Is.
+type GateError struct {
+	Gate string
+	Msg  string
+}
+
+func NewGateError(gate, msg string) *GateError {
+	return &GateError{Gate: gate, Msg: msg}
+}
+
+func (e *GateError) Error() string {
+	return e.Msg
+}
+
+func (e *GateError) Is(target error) bool {
+	return target == ErrConflict
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"github.com/codecrafted007/service-catalog-api/internal/labels"
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+// FreezeCovers reports whether f applies to svc, which must include its
+// ownership, labels and tags. A selector that no longer parses covers
+// every service, so a bad freeze errs on the side of blocking changes.
+func FreezeCovers(f model.Freeze, svc *model.Service) bool {
+	if f.TeamID != nil {
+		if svc.Ownership == nil || svc.Ownership.Team == nil || svc.Ownership.Team.ID != *f.TeamID {
+			return false
+		}
+	}
+	sel, err := labels.Parse(f.Selector)
+	if err != nil {
+		return true
+	}
+	return sel.Matches(svc.Labels, svc.Tags)
+}
@@ -2,23 +2,221 @@ package storage
 
 import (
 	"context"
+	"time"
 
+	"github.com/codecrafted007/service-catalog-api/internal/labels"
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
 	"github.com/codecrafted007/service-catalog-api/model"
 	"github.com/jmoiron/sqlx"
 )
 
+// ServicePatch is a partial service update; nil fields keep their value.
+type ServicePatch struct {
+	Name        *string
+	Description *string
+	// Revision, when non-zero, must match the stored revision.
+	Revision int64
+}
+
+// ServiceFilter narrows ListServices; empty fields match every service.
+type ServiceFilter struct {
+	// Text matches part of the name or description.
+	Text string
+	// Owner matches the owning team's name or a contact's email or chat
+	// handle, ignoring case.
+	Owner string
+	// Selector matches labels and tags; nil matches everything.
+	Selector labels.Selector
+}
+
+// OwnershipUpdate replaces who owns a service.
+type OwnershipUpdate struct {
+	// TeamID is the owning team, or 0 for none.
+	TeamID   int64
+	Contacts []model.Contact
+	// Revision, when non-zero, must match the service's stored revision.
+	Revision int64
+}
+
+// ApprovalRequestFilter narrows ListApprovalRequests; zero fields match
+// every request.
+type ApprovalRequestFilter struct {
+	Status    model.ApprovalRequestStatus
+	ServiceID int64
+	VersionID int64
+}
+
+// Storage is implemented by each database backend.
+//
+// Every write bumps the revision of the record it touches, and version
+// writes also bump their service's. Writes that take an expected revision
+// skip the check when it is 0 and otherwise fail with ErrPreconditionFailed
+// if the stored revision differs.
 type Storage interface {
-	ListServices(ctx context.Context, filter string, sort string, page, limit int) ([]model.Service, error)
+	ListServices(ctx context.Context, filter ServiceFilter, sort string, page, limit int) ([]model.Service, error)
+	// GetServiceById includes the service's ownership, labels and tags.
 	GetServiceById(ctx context.Context, id int) (*model.Service, error)
 	CreateService(ctx context.Context, s *model.Service) (int64, error)
+	// UpdateService replaces name and description; s.Revision is the
+	// expected revision.
 	UpdateService(ctx context.Context, id int, s *model.Service) error
-	DeleteService(ctx context.Context, id int) error
+	// PatchService changes only the fields set in p.
+	PatchService(ctx context.Context, id int, p ServicePatch) error
+	DeleteService(ctx context.Context, id int, revision int64) error
 
-	IsValidAPIKey(key string) bool
+	// WithTx runs fn against a Storage bound to a single transaction, which
+	// is committed when fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+
+	// GetAPIKey returns who uses key; an unknown key is ErrNotFound.
+	GetAPIKey(key string) (*model.APIKey, error)
+	// CreateAPIKey stores key under name, which must be unique. Only an
+	// elevated key may override a change freeze.
+	CreateAPIKey(ctx context.Context, key, name string, elevated bool) error
 	DB() *sqlx.DB
 
 	CreateVersion(ctx context.Context, v *model.Version) (int64, error)
-	GetVersionsByServiceID(ctx context.Context, serviceID int64) ([]*model.Version, error)
+	// GetVersionsByServiceID lists a service's versions, newest first,
+	// limited to the given states unless states is empty.
+	GetVersionsByServiceID(ctx context.Context, serviceID int64, states []model.VersionState) ([]*model.Version, error)
 	GetVersionByID(ctx context.Context, versionID int64) (*model.Version, error)
-	DeleteVersionByID(ctx context.Context, versionID int64) (bool, error)
+	// UpdateVersion replaces the version string and changelog of a version;
+	// its service and creation time never change. v.Revision is the expected
+	// revision. Only drafts may change their version string; for any other
+	// state that is ErrConflict.
+	UpdateVersion(ctx context.Context, versionID int64, v *model.Version) error
+	DeleteVersionByID(ctx context.Context, versionID int64, revision int64) error
+	// TransitionVersion moves a version to another lifecycle state, failing
+	// with ErrConflict when model.VersionState.CanTransitionTo forbids it.
+	// reason replaces the stored state reason.
+	TransitionVersion(ctx context.Context, versionID int64, to model.VersionState, reason string, revision int64) error
+
+	// GetLatestVersion returns the highest released or deprecated semantic
+	// version of a service, or nil when it has none. Prereleases are skipped
+	// unless includePrerelease.
+	GetLatestVersion(ctx context.Context, serviceID int64, includePrerelease bool) (*model.Version, error)
+	// LatestVersions is GetLatestVersion for many services in one query,
+	// keyed by service ID. Services with no such version, or that do not
+	// exist, are left out.
+	LatestVersions(ctx context.Context, serviceIDs []int64, includePrerelease bool) (map[int64]*model.Version, error)
+	// ResolveVersion returns the highest released or deprecated version
+	// satisfying c, or nil when none does.
+	ResolveVersion(ctx context.Context, serviceID int64, c semver.Constraint) (*model.Version, error)
+
+	ListTeams(ctx context.Context) ([]model.Team, error)
+	GetTeam(ctx context.Context, id int64) (*model.Team, error)
+	CreateTeam(ctx context.Context, t *model.Team) (int64, error)
+	UpdateTeam(ctx context.Context, id int64, t *model.Team) error
+	// DeleteTeam fails with ErrConflict while the team owns any service or
+	// any freeze names it.
+	DeleteTeam(ctx context.Context, id int64) error
+	GetServiceOwnership(ctx context.Context, serviceID int64) (*model.Ownership, error)
+	// SetServiceOwnership replaces the owners of a service and bumps its
+	// revision. An unknown team is ErrInvalid.
+	SetServiceOwnership(ctx context.Context, serviceID int64, o OwnershipUpdate) error
+
+	// Label and tag writes bump the service's revision. Removing a label or
+	// tag the service does not have is ErrNotFound; setting one it already
+	// has just bumps the revision.
+	SetServiceLabel(ctx context.Context, serviceID int64, key, value string, revision int64) error
+	RemoveServiceLabel(ctx context.Context, serviceID int64, key string, revision int64) error
+	AddServiceTag(ctx context.Context, serviceID int64, tag string, revision int64) error
+	RemoveServiceTag(ctx context.Context, serviceID int64, tag string, revision int64) error
+
+	// AddDependency records d and returns its ID. An unknown DependsOnID is
+	// ErrInvalid; an edge that already exists, or that would close a
+	// cycle, is ErrConflict.
+	AddDependency(ctx context.Context, d *model.Dependency) (int64, error)
+	GetDependency(ctx context.Context, id int64) (*model.Dependency, error)
+	RemoveDependency(ctx context.Context, serviceID, dependsOnID int64) error
+	// ListAllDependencies returns every edge in the catalog.
+	ListAllDependencies(ctx context.Context) ([]model.Dependency, error)
+	// ListDependencies and ListDependents walk the graph from a service for
+	// up to depth levels, or all of them when depth is 0.
+	ListDependencies(ctx context.Context, serviceID int64, depth int) ([]model.DependencyNode, error)
+	ListDependents(ctx context.Context, serviceID int64, depth int) ([]model.DependencyNode, error)
+
+	ListEnvironments(ctx context.Context) ([]model.Environment, error)
+	CreateEnvironment(ctx context.Context, e *model.Environment) (int64, error)
+	GetEnvironment(ctx context.Context, id int64) (*model.Environment, error)
+	// DeleteEnvironment fails with ErrConflict once anything was deployed
+	// to the environment, or while another environment is promoted from it.
+	DeleteEnvironment(ctx context.Context, id int64) error
+	// CreateDeployment records d, finding the version and environment by
+	// d.Version and d.Environment. Unknown names are ErrInvalid, and a
+	// version that is not released or deprecated is ErrConflict. A deployment to an environment with a
+	// promotion rule must pass it as of d.DeployedAt; a gate that fails is
+	// a *GateError.
+	CreateDeployment(ctx context.Context, d *model.Deployment) (int64, error)
+	GetDeployment(ctx context.Context, id int64) (*model.Deployment, error)
+	// ListDeployments returns a service's deployment history, newest first,
+	// optionally only for one environment.
+	ListDeployments(ctx context.Context, serviceID int64, environment string, page, limit int) ([]model.Deployment, error)
+	// ServiceEnvironments reports the current deployment of a service in
+	// every environment.
+	ServiceEnvironments(ctx context.Context, serviceID int64) ([]model.EnvironmentStatus, error)
+
+	ListPromotionRules(ctx context.Context) ([]model.PromotionRule, error)
+	GetPromotionRule(ctx context.Context, environmentID int64) (*model.PromotionRule, error)
+	// SetPromotionRule replaces the rule into r.EnvironmentID, finding the
+	// source environment by r.From. An unknown source is ErrInvalid and a
+	// rule that would make the path loop is ErrConflict.
+	SetPromotionRule(ctx context.Context, r *model.PromotionRule) error
+	DeletePromotionRule(ctx context.Context, environmentID int64) error
+	// AddApproval records a decision on promoting a version to
+	// a.Environment, replacing the approver's earlier decision.
+	AddApproval(ctx context.Context, a *model.Approval) (int64, error)
+	GetApproval(ctx context.Context, id int64) (*model.Approval, error)
+	ListApprovals(ctx context.Context, versionID int64) ([]model.Approval, error)
+	// PromoteVersion records a successful deployment of a version to
+	// d.Environment, by d.Actor, once it passes the environment's promotion
+	// rule. A gate that fails is a *GateError.
+	PromoteVersion(ctx context.Context, versionID int64, d *model.Deployment) (int64, error)
+
+	ListApprovalPolicies(ctx context.Context) ([]model.ApprovalPolicy, error)
+	GetApprovalPolicy(ctx context.Context, id int64) (*model.ApprovalPolicy, error)
+	// CreateApprovalPolicy records p, which names either a service or a
+	// label. An unknown service is ErrInvalid and a second policy for the
+	// same service or label is ErrConflict.
+	CreateApprovalPolicy(ctx context.Context, p *model.ApprovalPolicy) (int64, error)
+	DeleteApprovalPolicy(ctx context.Context, id int64) error
+	// RequiredApprovals is the largest count among the policies that apply
+	// to a service, or 0 when none does. A label policy without a value
+	// applies whatever the service's value for the key.
+	RequiredApprovals(ctx context.Context, serviceID int64) (int, error)
+
+	// CreateApprovalRequest asks for the draft version req.VersionID to be
+	// released, taking the required count from the service's policies. It
+	// is ErrConflict when the version is not a draft, already has a pending
+	// request, or needs no approvals.
+	CreateApprovalRequest(ctx context.Context, req *model.ApprovalRequest) (int64, error)
+	// GetApprovalRequest includes the request's reviews.
+	GetApprovalRequest(ctx context.Context, id int64) (*model.ApprovalRequest, error)
+	// ListApprovalRequests returns matching requests with their reviews,
+	// oldest first.
+	ListApprovalRequests(ctx context.Context, filter ApprovalRequestFilter, page, limit int) ([]model.ApprovalRequest, error)
+	// ReviewApprovalRequest records a reviewer's decision, replacing their
+	// earlier one. A rejection rejects the request; the approval that
+	// reaches the required count approves it and releases the version.
+	// Reviewing a request that is no longer pending, or approving one's own
+	// request, is ErrConflict.
+	ReviewApprovalRequest(ctx context.Context, id int64, review *model.ApprovalReview) error
+
+	// ListFreezes returns every freeze, past ones included, by start time.
+	ListFreezes(ctx context.Context) ([]model.Freeze, error)
+	GetFreeze(ctx context.Context, id int64) (*model.Freeze, error)
+	// CreateFreeze records f. One that does not end after it starts, or names
+	// an unknown team, is ErrInvalid.
+	CreateFreeze(ctx context.Context, f *model.Freeze) (int64, error)
+	DeleteFreeze(ctx context.Context, id int64) error
+	// ActiveFreezes returns the freezes in force at at, latest ending first,
+	// only those covering the service unless serviceID is 0.
+	ActiveFreezes(ctx context.Context, serviceID int64, at time.Time) ([]model.Freeze, error)
+
+	// AddAuditEvent stores e as given. Backends need not call it: Open
+	// wraps them so that every create, update and delete records an event
+	// in its transaction.
+	AddAuditEvent(ctx context.Context, e *model.AuditEvent) error
+	// ListAuditEvents returns matching events, oldest first.
+	ListAuditEvents(ctx context.Context, filter AuditFilter, page, limit int) ([]model.AuditEvent, error)
 }
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/labels"
+)
+
+// SelectorConditions translates a label selector into SQL conditions on the
+// services table aliased as s, with ? placeholders, for every backend.
+func SelectorConditions(sel labels.Selector) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, req := range sel {
+		switch req.Op {
+		case labels.Exists, labels.DoesNotExist:
+			cond := "(EXISTS (SELECT 1 FROM service_labels l WHERE l.service_id = s.id AND l.name = ?)" +
+				" OR EXISTS (SELECT 1 FROM service_tags t WHERE t.service_id = s.id AND t.tag = ?))"
+			if req.Op == labels.DoesNotExist {
+				cond = "NOT " + cond
+			}
+			conditions = append(conditions, cond)
+			args = append(args, req.Key, req.Key)
+		default:
+			// != and notin also match services without the label, so they are
+			// the negation of = and in.
+			cond := "EXISTS (SELECT 1 FROM service_labels l WHERE l.service_id = s.id AND l.name = ? AND l.value IN (?" +
+				strings.Repeat(", ?", len(req.Values)-1) + "))"
+			if req.Op == labels.NotEquals || req.Op == labels.NotIn {
+				cond = "NOT " + cond
+			}
+			conditions = append(conditions, cond)
+			args = append(args, req.Key)
+			for _, v := range req.Values {
+				args = append(args, v)
+			}
+		}
+	}
+	return conditions, args
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/codecrafted007/service-catalog-api/db"
+	"github.com/codecrafted007/service-catalog-api/internal/migrations"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewMigrator returns the migrator for the catalog schema on a database of
+// driver: the SQL migrations embedded in package db, plus the Go steps that
+// fill in data the SQL cannot compute.
+func NewMigrator(conn *sqlx.DB, driver string) (*migrations.Migrator, error) {
+	m, err := migrations.New(conn, driver, db.Migrations())
+	if err != nil {
+		return nil, err
+	}
+	// 0002_version_semver adds the semver columns.
+	m.AfterUp(2, backfillSemver)
+	return m, nil
+}
+
+// backfillSemver fills the semver columns of the versions that existed
+// before they did. Versions that are not semantic versions keep NULLs, as
+// they would if created now.
+func backfillSemver(ctx context.Context, tx *sqlx.Tx) error {
+	var versions []struct {
+		ID      int64  `db:"id"`
+		Version string `db:"version"`
+	}
+	if err := tx.SelectContext(ctx, &versions, `SELECT id, version FROM versions WHERE major IS NULL`); err != nil {
+		return err
+	}
+	update := tx.Rebind(`UPDATE versions SET major = ?, minor = ?, patch = ?, prerelease = ? WHERE id = ?`)
+	for _, v := range versions {
+		major, minor, patch, prerelease := SemverColumns(v.Version)
+		if major == nil {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, update, major, minor, patch, prerelease, v.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
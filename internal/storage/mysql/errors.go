@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// MySQL server error numbers we translate.
+const (
+	errDupEntry          = 1062
+	errNoReferencedRow   = 1452
+	errBadNull           = 1048
+	errDataTooLong       = 1406
+	errCheckConstraint   = 3819
+	errTruncatedWrongVal = 1292
+)
+
+// mapError translates go-sql-driver errors into the storage error kinds, with a
+// client-safe message about entity ("service", "version", ...).
+func mapError(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.NewError(storage.ErrNotFound, entity+" not found", err)
+	}
+
+	var me *mysqldriver.MySQLError
+	if !errors.As(err, &me) {
+		return err
+	}
+	switch me.Number {
+	case errDupEntry:
+		return storage.NewConflictError(entity, 0, err)
+	case errNoReferencedRow:
+		return storage.NewError(storage.ErrNotFound, entity+" references a record that does not exist", err)
+	case errBadNull, errDataTooLong, errCheckConstraint, errTruncatedWrongVal:
+		return storage.NewError(storage.ErrInvalid, "invalid "+entity, err)
+	}
+	return err
+}
@@ -0,0 +1,650 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/logger"
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/storage/sqlstore"
+	"github.com/codecrafted007/service-catalog-api/model"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// groupConcatMaxLen raises MySQL's default 1024 byte GROUP_CONCAT limit,
+// which otherwise silently truncates the versions list of busy services.
+const groupConcatMaxLen = "1048576"
+
+func init() {
+	storage.Register("mysql", New)
+}
+
+type mysqlStore struct {
+	*sqlstore.Store
+
+	db *sqlx.DB
+	// q is db itself, or the open transaction for stores handed out by WithTx.
+	q sqlx.ExtContext
+}
+
+func New(dsn string, opts storage.Options) (storage.Storage, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mysql dsn: %w", err)
+	}
+	// Without parseTime DATETIME columns are returned as []byte and cannot
+	// be scanned into time.Time.
+	cfg.ParseTime = true
+	// Report matched rather than changed rows so an UPDATE that keeps the
+	// same values is not mistaken for a missing row.
+	cfg.ClientFoundRows = true
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	// Unknown DSN params are sent as SET statements on every new connection.
+	if _, ok := cfg.Params["group_concat_max_len"]; !ok {
+		cfg.Params["group_concat_max_len"] = groupConcatMaxLen
+	}
+
+	db, err := sqlx.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	return newStore(db, db), nil
+}
+
+// newStore returns a store running queries on q, which is db or one of its
+// transactions.
+func newStore(db *sqlx.DB, q sqlx.ExtContext) *mysqlStore {
+	s := &mysqlStore{db: db, q: q}
+	s.Store = sqlstore.New(db, q, sqlstore.Dialect{MapError: mapError}, s)
+	return s
+}
+
+func (ms *mysqlStore) DB() *sqlx.DB {
+	return ms.db
+}
+
+func (ms *mysqlStore) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	// Already inside a transaction: join it instead of nesting.
+	if _, ok := ms.q.(*sqlx.Tx); ok {
+		return fn(ms)
+	}
+
+	tx, err := ms.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(newStore(ms.db, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ms *mysqlStore) ListServices(ctx context.Context, filter storage.ServiceFilter, sort string, page, limit int) ([]model.Service, error) {
+	offset := (page - 1) * limit
+
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString(`
+		SELECT s.id, s.name, s.description, s.created_at, s.revision,
+		GROUP_CONCAT(v.version ORDER BY v.created_at SEPARATOR ',') AS versions
+		FROM services s
+		LEFT JOIN versions v ON s.id = v.service_id AND v.state <> 'yanked'`)
+
+	args := make([]interface{}, 0)
+	conditions := make([]string, 0)
+
+	if filter.Text != "" {
+		conditions = append(conditions, "(s.name LIKE ? OR s.description LIKE ?)")
+		filterValue := fmt.Sprintf("%%%s%%", filter.Text)
+		args = append(args, filterValue, filterValue)
+	}
+
+	if filter.Owner != "" {
+		// The column collation already ignores case.
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM service_owners o LEFT JOIN teams t ON t.id = o.team_id
+			WHERE o.service_id = s.id AND (t.name = ? OR o.email = ? OR o.chat = ?))`)
+		args = append(args, filter.Owner, filter.Owner, filter.Owner)
+	}
+
+	selConditions, selArgs := storage.SelectorConditions(filter.Selector)
+	conditions = append(conditions, selConditions...)
+	args = append(args, selArgs...)
+
+	if len(conditions) > 0 {
+		queryBuilder.WriteString(" WHERE ")
+		queryBuilder.WriteString(strings.Join(conditions, " AND "))
+	}
+
+	queryBuilder.WriteString(" GROUP BY s.id")
+
+	// GROUP BY no longer implies ORDER BY since MySQL 8, so fall back to the
+	// primary key to keep pagination stable.
+	sortBy := "s.id"
+	if sort != "" {
+		sortBy = "s.name"
+		if strings.ToLower(sort) == "createdat" {
+			sortBy = "s.created_at"
+		}
+	}
+	queryBuilder.WriteString(" ORDER BY " + sortBy)
+
+	queryBuilder.WriteString(" LIMIT ? OFFSET ?")
+	args = append(args, limit, offset)
+	logger.L().Infow("Executing query", "query", queryBuilder.String(), "args", args)
+	rows, err := ms.q.QueryContext(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []model.Service
+
+	for rows.Next() {
+		var svc model.Service
+		var versionsCSV sql.NullString
+
+		err := rows.Scan(&svc.ID, &svc.Name, &svc.Description, &svc.CreatedAt, &svc.Revision, &versionsCSV)
+		if err != nil {
+			return nil, err
+		}
+		if versionsCSV.Valid {
+			svc.Versions = strings.Split(versionsCSV.String, ",")
+		} else {
+			svc.Versions = []string{}
+		}
+		storage.SortVersionStrings(svc.Versions)
+		services = append(services, svc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	loaded := make([]*model.Service, len(services))
+	for i := range services {
+		loaded[i] = &services[i]
+	}
+	if err := ms.AttachLabels(ctx, loaded...); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (ms *mysqlStore) GetServiceById(ctx context.Context, serviceId int) (*model.Service, error) {
+	rows, err := ms.q.QueryxContext(ctx, `
+		SELECT
+			s.id AS service_id, s.name, s.description, s.created_at AS service_created_at, s.revision AS service_revision,
+			v.id AS version_id, v.version, v.created_at AS version_created_at
+		FROM services s
+		LEFT JOIN versions v ON s.id = v.service_id AND v.state <> 'yanked'
+		WHERE s.id = ?
+		ORDER BY v.created_at
+	`, serviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var svc *model.Service
+	for rows.Next() {
+		var (
+			sid                        int
+			name, desc                 string
+			revision                   int64
+			svcCreatedAt, verCreatedAt sql.NullTime
+			verID                      sql.NullInt64
+			verStr                     sql.NullString
+		)
+		err := rows.Scan(&sid, &name, &desc, &svcCreatedAt, &revision, &verID, &verStr, &verCreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if svc == nil {
+			svc = &model.Service{
+				ID:          sid,
+				Name:        name,
+				Description: desc,
+				CreatedAt:   svcCreatedAt.Time,
+				Revision:    revision,
+			}
+		}
+
+		if verID.Valid && verStr.Valid && verCreatedAt.Valid {
+			svc.Versions = append(svc.Versions, verStr.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if svc == nil {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+	storage.SortVersionStrings(svc.Versions)
+
+	if svc.Ownership, err = ms.Ownership(ctx, int64(svc.ID)); err != nil {
+		return nil, err
+	}
+	if err := ms.AttachLabels(ctx, svc); err != nil {
+		return nil, err
+	}
+
+	return svc, nil
+}
+
+// `key` is a reserved word in MySQL and has to be quoted.
+func (ms *mysqlStore) GetAPIKey(key string) (*model.APIKey, error) {
+	var k model.APIKey
+	err := sqlx.GetContext(context.Background(), ms.q, &k, "SELECT id, name, elevated, created_at FROM api_keys WHERE `key` = ?", key)
+	if err != nil {
+		return nil, mapError(err, "API key")
+	}
+	return &k, nil
+}
+
+func (ms *mysqlStore) CreateAPIKey(ctx context.Context, key, name string, elevated bool) error {
+	_, err := ms.q.ExecContext(ctx, "INSERT INTO api_keys (`key`, name, elevated) VALUES (?, ?, ?)", key, name, elevated)
+	return mapError(err, "API key")
+}
+
+func (ms *mysqlStore) CreateService(ctx context.Context, service *model.Service) (int64, error) {
+	if id, err := ms.existingServiceID(ctx, service.Name, 0); err != nil {
+		return 0, err
+	} else if id != 0 {
+		return 0, storage.NewConflictError("service", id, nil)
+	}
+
+	result, err := ms.q.ExecContext(ctx, `
+		INSERT INTO services (name, description, created_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, service.Name, service.Description)
+	if err != nil {
+		return 0, mapError(err, "service")
+	}
+	// The driver reports the AUTO_INCREMENT value from the OK packet, so no
+	// extra SELECT LAST_INSERT_ID() round trip is needed.
+	return result.LastInsertId()
+}
+
+func (ms *mysqlStore) UpdateService(ctx context.Context, id int, service *model.Service) error {
+	if existing, err := ms.existingServiceID(ctx, service.Name, id); err != nil {
+		return err
+	} else if existing != 0 {
+		return storage.NewConflictError("service", existing, nil)
+	}
+
+	result, err := ms.q.ExecContext(ctx, `
+		UPDATE services
+		SET name = ?, description = ?, revision = revision + 1
+		WHERE id = ? AND (? = 0 OR revision = ?)
+	`, service.Name, service.Description, id, service.Revision, service.Revision)
+
+	if err != nil {
+		return mapError(err, "service")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ms.NotUpdated(ctx, "services", "service", id)
+	}
+
+	return nil
+}
+
+func (ms *mysqlStore) PatchService(ctx context.Context, id int, p storage.ServicePatch) error {
+	var (
+		sets []string
+		args []interface{}
+	)
+	if p.Name != nil {
+		if existing, err := ms.existingServiceID(ctx, *p.Name, id); err != nil {
+			return err
+		} else if existing != 0 {
+			return storage.NewConflictError("service", existing, nil)
+		}
+		sets = append(sets, "name = ?")
+		args = append(args, *p.Name)
+	}
+	if p.Description != nil {
+		sets = append(sets, "description = ?")
+		args = append(args, *p.Description)
+	}
+	if len(sets) == 0 {
+		// Nothing to change, but the service must still exist and match.
+		var revision int64
+		if err := sqlx.GetContext(ctx, ms.q, &revision, `SELECT revision FROM services WHERE id = ?`, id); err != nil {
+			return mapError(err, "service")
+		}
+		if p.Revision != 0 && p.Revision != revision {
+			return storage.NewError(storage.ErrPreconditionFailed, "service has been modified", nil)
+		}
+		return nil
+	}
+
+	sets = append(sets, "revision = revision + 1")
+	query := "UPDATE services SET " + strings.Join(sets, ", ") + " WHERE id = ? AND (? = 0 OR revision = ?)"
+	args = append(args, id, p.Revision, p.Revision)
+	result, err := ms.q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return mapError(err, "service")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ms.NotUpdated(ctx, "services", "service", id)
+	}
+	return nil
+}
+
+func (ms *mysqlStore) DeleteService(ctx context.Context, id int, revision int64) error {
+	result, err := ms.q.ExecContext(ctx, `
+		DELETE FROM services
+		WHERE id = ? AND (? = 0 OR revision = ?)
+	`, id, revision, revision)
+	if err != nil {
+		return mapError(err, "service")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ms.NotUpdated(ctx, "services", "service", id)
+	}
+	return nil
+}
+
+func (ms *mysqlStore) CreateVersion(ctx context.Context, v *model.Version) (int64, error) {
+	var id int64
+	err := ms.inTx(ctx, func(tx *mysqlStore) error {
+		var err error
+		if id, err = tx.insertVersion(ctx, v); err != nil {
+			return err
+		}
+		return tx.touchService(ctx, v.ServiceID)
+	})
+	return id, err
+}
+
+func (ms *mysqlStore) insertVersion(ctx context.Context, v *model.Version) (int64, error) {
+	if id, err := ms.ExistingVersionID(ctx, v.ServiceID, v.Version); err != nil {
+		return 0, err
+	} else if id != 0 {
+		return 0, storage.NewConflictError("version", id, nil)
+	}
+
+	if v.State == "" {
+		v.State = model.VersionReleased
+	}
+	major, minor, patch, prerelease := storage.SemverColumns(v.Version)
+	result, err := ms.q.ExecContext(ctx, `
+		INSERT INTO versions (service_id, version, changelog, created_at, major, minor, patch, prerelease, state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, v.ServiceID, v.Version, v.Changelog, v.CreatedAt, major, minor, patch, prerelease, v.State)
+	if err != nil {
+		return 0, mapError(err, "version")
+	}
+	return result.LastInsertId()
+}
+
+func (ms *mysqlStore) UpdateVersion(ctx context.Context, versionID int64, v *model.Version) error {
+	return ms.inTx(ctx, func(tx *mysqlStore) error {
+		var current struct {
+			ServiceID int64              `db:"service_id"`
+			Version   string             `db:"version"`
+			State     model.VersionState `db:"state"`
+		}
+		if err := sqlx.GetContext(ctx, tx.q, &current, `SELECT service_id, version, state FROM versions WHERE id = ?`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+		// Renaming a released version would publish a new one past the
+		// approval and freeze gates.
+		if v.Version != current.Version && current.State != model.VersionDraft {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version %s is %s; only drafts can change their version", current.Version, current.State), nil)
+		}
+		serviceID := current.ServiceID
+		if id, err := tx.ExistingVersionID(ctx, serviceID, v.Version); err != nil {
+			return err
+		} else if id != 0 && id != versionID {
+			return storage.NewConflictError("version", id, nil)
+		}
+
+		major, minor, patch, prerelease := storage.SemverColumns(v.Version)
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE versions
+			SET version = ?, changelog = ?, major = ?, minor = ?, patch = ?, prerelease = ?, revision = revision + 1
+			WHERE id = ? AND (? = 0 OR revision = ?)
+		`, v.Version, v.Changelog, major, minor, patch, prerelease, versionID, v.Revision, v.Revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, serviceID)
+	})
+}
+
+func (ms *mysqlStore) GetVersionsByServiceID(ctx context.Context, serviceID int64, states []model.VersionState) ([]*model.Version, error) {
+	var exists bool
+	if err := sqlx.GetContext(ctx, ms.q, &exists, `SELECT EXISTS(SELECT 1 FROM services WHERE id = ?)`, serviceID); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE service_id = ?`
+	args := []interface{}{serviceID}
+	if len(states) > 0 {
+		query += " AND state IN (?" + strings.Repeat(", ?", len(states)-1) + ")"
+		for _, state := range states {
+			args = append(args, state)
+		}
+	}
+	query += " ORDER BY major IS NULL, major DESC, minor DESC, patch DESC, created_at DESC"
+
+	var versions []*model.Version
+	err := sqlx.SelectContext(ctx, ms.q, &versions, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	// SQL cannot order prerelease identifiers, so finish the job in Go.
+	storage.SortVersions(versions)
+	return versions, nil
+}
+
+func (ms *mysqlStore) GetVersionByID(ctx context.Context, versionID int64) (*model.Version, error) {
+	var version model.Version
+	err := sqlx.GetContext(ctx, ms.q, &version, `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE id = ?
+	`, versionID)
+	if err != nil {
+		return nil, mapError(err, "version")
+	}
+	return &version, nil
+}
+
+func (ms *mysqlStore) DeleteVersionByID(ctx context.Context, versionID int64, revision int64) error {
+	return ms.inTx(ctx, func(tx *mysqlStore) error {
+		var serviceID int64
+		if err := sqlx.GetContext(ctx, tx.q, &serviceID, `SELECT service_id FROM versions WHERE id = ?`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+
+		result, err := tx.q.ExecContext(ctx, `DELETE FROM versions WHERE id = ? AND (? = 0 OR revision = ?)`, versionID, revision, revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, serviceID)
+	})
+}
+
+func (ms *mysqlStore) TransitionVersion(ctx context.Context, versionID int64, to model.VersionState, reason string, revision int64) error {
+	return ms.inTx(ctx, func(tx *mysqlStore) error {
+		var current struct {
+			ServiceID int64              `db:"service_id"`
+			State     model.VersionState `db:"state"`
+		}
+		if err := sqlx.GetContext(ctx, tx.q, &current, `SELECT service_id, state FROM versions WHERE id = ?`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+		if !current.State.CanTransitionTo(to) {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version cannot move from %s to %s", current.State, to), nil)
+		}
+
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE versions
+			SET state = ?, state_reason = ?, revision = revision + 1
+			WHERE id = ? AND (? = 0 OR revision = ?)
+		`, to, reason, versionID, revision, revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, current.ServiceID)
+	})
+}
+
+func (ms *mysqlStore) GetLatestVersion(ctx context.Context, serviceID int64, includePrerelease bool) (*model.Version, error) {
+	candidates, err := ms.semverVersions(ctx, serviceID, !includePrerelease)
+	if err != nil {
+		return nil, err
+	}
+	return storage.HighestVersion(candidates, func(semver.Version) bool { return true }), nil
+}
+
+func (ms *mysqlStore) LatestVersions(ctx context.Context, serviceIDs []int64, includePrerelease bool) (map[int64]*model.Version, error) {
+	latest := make(map[int64]*model.Version, len(serviceIDs))
+	if len(serviceIDs) == 0 {
+		return latest, nil
+	}
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE service_id IN (?) AND major IS NOT NULL AND state IN ('released', 'deprecated')`
+	if !includePrerelease {
+		query += " AND prerelease = ''"
+	}
+	query, args, err := sqlx.In(query, serviceIDs)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*model.Version
+	if err := sqlx.SelectContext(ctx, ms.q, &versions, ms.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	byService := make(map[int64][]*model.Version)
+	for _, v := range versions {
+		byService[v.ServiceID] = append(byService[v.ServiceID], v)
+	}
+	for id, candidates := range byService {
+		latest[id] = storage.HighestVersion(candidates, func(semver.Version) bool { return true })
+	}
+	return latest, nil
+}
+
+func (ms *mysqlStore) ResolveVersion(ctx context.Context, serviceID int64, c semver.Constraint) (*model.Version, error) {
+	candidates, err := ms.semverVersions(ctx, serviceID, false)
+	if err != nil {
+		return nil, err
+	}
+	return storage.HighestVersion(candidates, c.Check), nil
+}
+
+// semverVersions loads the published (released or deprecated) versions of a
+// service that have normalized semver columns, optionally without
+// prereleases.
+func (ms *mysqlStore) semverVersions(ctx context.Context, serviceID int64, releasesOnly bool) ([]*model.Version, error) {
+	var exists bool
+	if err := sqlx.GetContext(ctx, ms.q, &exists, `SELECT EXISTS(SELECT 1 FROM services WHERE id = ?)`, serviceID); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE service_id = ? AND major IS NOT NULL AND state IN ('released', 'deprecated')`
+	if releasesOnly {
+		query += " AND prerelease = ''"
+	}
+	var versions []*model.Version
+	err := sqlx.SelectContext(ctx, ms.q, &versions, query, serviceID)
+	return versions, err
+}
+
+// existingServiceID returns the ID of a service other than exceptID that
+// already uses name (compared with the column's case-insensitive collation), or 0. Checking up front
+// lets conflicts carry the existing ID, which a failed INSERT cannot report
+// portably; the unique index still catches concurrent writers.
+func (ms *mysqlStore) existingServiceID(ctx context.Context, name string, exceptID int) (int64, error) {
+	var id int64
+	err := sqlx.GetContext(ctx, ms.q, &id, `SELECT id FROM services WHERE name = ? AND id <> ?`, name, exceptID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// inTx runs fn with a store bound to a transaction, joining the current one
+// if there is one.
+func (ms *mysqlStore) inTx(ctx context.Context, fn func(tx *mysqlStore) error) error {
+	return ms.WithTx(ctx, func(tx storage.Storage) error {
+		return fn(tx.(*mysqlStore))
+	})
+}
+
+// touchService bumps the revision of a service whose versions changed, so
+// its ETag changes along with its versions array.
+func (ms *mysqlStore) touchService(ctx context.Context, serviceID int64) error {
+	_, err := ms.q.ExecContext(ctx, `UPDATE services SET revision = revision + 1 WHERE id = ?`, serviceID)
+	return err
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/lib/pq"
+)
+
+// mapError translates lib/pq errors into the storage error kinds, with a
+// client-safe message about entity ("service", "version", ...).
+func mapError(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.NewError(storage.ErrNotFound, entity+" not found", err)
+	}
+
+	var pe *pq.Error
+	if !errors.As(err, &pe) {
+		return err
+	}
+	switch {
+	case pe.Code == "23505": // unique_violation
+		return storage.NewConflictError(entity, 0, err)
+	case pe.Code == "23503": // foreign_key_violation
+		return storage.NewError(storage.ErrNotFound, entity+" references a record that does not exist", err)
+	case pe.Code == "23502", pe.Code == "23514", strings.HasPrefix(string(pe.Code), "22"):
+		// not_null_violation, check_violation and the data_exception class
+		return storage.NewError(storage.ErrInvalid, "invalid "+entity, err)
+	}
+	return err
+}
@@ -0,0 +1,627 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/logger"
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/storage/sqlstore"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func init() {
+	storage.Register("postgres", New)
+}
+
+type postgresStore struct {
+	*sqlstore.Store
+
+	db *sqlx.DB
+	// q is db itself, or the open transaction for stores handed out by WithTx.
+	q sqlx.ExtContext
+}
+
+func New(dsn string, opts storage.Options) (storage.Storage, error) {
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return newStore(db, db), nil
+}
+
+// newStore returns a store running queries on q, which is db or one of its
+// transactions.
+func newStore(db *sqlx.DB, q sqlx.ExtContext) *postgresStore {
+	s := &postgresStore{db: db, q: q}
+	s.Store = sqlstore.New(db, q, sqlstore.Dialect{MapError: mapError, Returning: true}, s)
+	return s
+}
+
+func (ps *postgresStore) DB() *sqlx.DB {
+	return ps.db
+}
+
+func (ps *postgresStore) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	// Already inside a transaction: join it instead of nesting.
+	if _, ok := ps.q.(*sqlx.Tx); ok {
+		return fn(ps)
+	}
+
+	tx, err := ps.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(newStore(ps.db, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ps *postgresStore) ListServices(ctx context.Context, filter storage.ServiceFilter, sort string, page, limit int) ([]model.Service, error) {
+	offset := (page - 1) * limit
+
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString(`
+		SELECT s.id, s.name, s.description, s.created_at, s.revision,
+		COALESCE(ARRAY_AGG(v.version ORDER BY v.created_at) FILTER (WHERE v.id IS NOT NULL), '{}') AS versions
+		FROM services s
+		LEFT JOIN versions v ON s.id = v.service_id AND v.state <> 'yanked'`)
+
+	args := make([]interface{}, 0)
+	conditions := make([]string, 0)
+
+	if filter.Text != "" {
+		// ILIKE keeps the case-insensitive matching SQLite's LIKE gives us.
+		conditions = append(conditions, "(s.name ILIKE ? OR s.description ILIKE ?)")
+		filterValue := fmt.Sprintf("%%%s%%", filter.Text)
+		args = append(args, filterValue, filterValue)
+	}
+
+	if filter.Owner != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM service_owners o LEFT JOIN teams t ON t.id = o.team_id
+			WHERE o.service_id = s.id AND (LOWER(t.name) = LOWER(?) OR LOWER(o.email) = LOWER(?) OR LOWER(o.chat) = LOWER(?)))`)
+		args = append(args, filter.Owner, filter.Owner, filter.Owner)
+	}
+
+	selConditions, selArgs := storage.SelectorConditions(filter.Selector)
+	conditions = append(conditions, selConditions...)
+	args = append(args, selArgs...)
+
+	if len(conditions) > 0 {
+		queryBuilder.WriteString(" WHERE ")
+		queryBuilder.WriteString(strings.Join(conditions, " AND "))
+	}
+
+	queryBuilder.WriteString(" GROUP BY s.id")
+
+	// Postgres gives no ordering guarantee for grouped rows, so fall back to
+	// the primary key to keep pagination stable.
+	sortBy := "s.id"
+	if sort != "" {
+		sortBy = "s.name"
+		if strings.ToLower(sort) == "createdat" {
+			sortBy = "s.created_at"
+		}
+	}
+	queryBuilder.WriteString(" ORDER BY " + sortBy)
+
+	queryBuilder.WriteString(" LIMIT ? OFFSET ?")
+	args = append(args, limit, offset)
+
+	query := ps.db.Rebind(queryBuilder.String())
+	logger.L().Infow("Executing query", "query", query, "args", args)
+	rows, err := ps.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []model.Service
+
+	for rows.Next() {
+		var svc model.Service
+		var versions pq.StringArray
+
+		err := rows.Scan(&svc.ID, &svc.Name, &svc.Description, &svc.CreatedAt, &svc.Revision, &versions)
+		if err != nil {
+			return nil, err
+		}
+		svc.Versions = []string(versions)
+		storage.SortVersionStrings(svc.Versions)
+		services = append(services, svc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	loaded := make([]*model.Service, len(services))
+	for i := range services {
+		loaded[i] = &services[i]
+	}
+	if err := ps.AttachLabels(ctx, loaded...); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (ps *postgresStore) GetServiceById(ctx context.Context, serviceId int) (*model.Service, error) {
+	rows, err := ps.q.QueryxContext(ctx, `
+		SELECT
+			s.id AS service_id, s.name, s.description, s.created_at AS service_created_at, s.revision AS service_revision,
+			v.id AS version_id, v.version, v.created_at AS version_created_at
+		FROM services s
+		LEFT JOIN versions v ON s.id = v.service_id AND v.state <> 'yanked'
+		WHERE s.id = $1
+		ORDER BY v.created_at
+	`, serviceId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var svc *model.Service
+	for rows.Next() {
+		var (
+			sid                        int
+			name, desc                 string
+			revision                   int64
+			svcCreatedAt, verCreatedAt sql.NullTime
+			verID                      sql.NullInt64
+			verStr                     sql.NullString
+		)
+		err := rows.Scan(&sid, &name, &desc, &svcCreatedAt, &revision, &verID, &verStr, &verCreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if svc == nil {
+			svc = &model.Service{
+				ID:          sid,
+				Name:        name,
+				Description: desc,
+				CreatedAt:   svcCreatedAt.Time,
+				Revision:    revision,
+			}
+		}
+
+		if verID.Valid && verStr.Valid && verCreatedAt.Valid {
+			svc.Versions = append(svc.Versions, verStr.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if svc == nil {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+	storage.SortVersionStrings(svc.Versions)
+
+	if svc.Ownership, err = ps.Ownership(ctx, int64(svc.ID)); err != nil {
+		return nil, err
+	}
+	if err := ps.AttachLabels(ctx, svc); err != nil {
+		return nil, err
+	}
+
+	return svc, nil
+}
+
+func (ps *postgresStore) GetAPIKey(key string) (*model.APIKey, error) {
+	var k model.APIKey
+	err := sqlx.GetContext(context.Background(), ps.q, &k, `SELECT id, name, elevated, created_at FROM api_keys WHERE key = $1`, key)
+	if err != nil {
+		return nil, mapError(err, "API key")
+	}
+	return &k, nil
+}
+
+func (ps *postgresStore) CreateAPIKey(ctx context.Context, key, name string, elevated bool) error {
+	_, err := ps.q.ExecContext(ctx, `INSERT INTO api_keys (key, name, elevated) VALUES ($1, $2, $3)`, key, name, elevated)
+	return mapError(err, "API key")
+}
+
+func (ps *postgresStore) CreateService(ctx context.Context, service *model.Service) (int64, error) {
+	if id, err := ps.existingServiceID(ctx, service.Name, 0); err != nil {
+		return 0, err
+	} else if id != 0 {
+		return 0, storage.NewConflictError("service", id, nil)
+	}
+
+	var id int64
+	err := ps.q.QueryRowxContext(ctx, `
+		INSERT INTO services (name, description, created_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		RETURNING id
+	`, service.Name, service.Description).Scan(&id)
+	if err != nil {
+		return 0, mapError(err, "service")
+	}
+	return id, nil
+}
+
+func (ps *postgresStore) UpdateService(ctx context.Context, id int, service *model.Service) error {
+	if existing, err := ps.existingServiceID(ctx, service.Name, id); err != nil {
+		return err
+	} else if existing != 0 {
+		return storage.NewConflictError("service", existing, nil)
+	}
+
+	result, err := ps.q.ExecContext(ctx, `
+		UPDATE services
+		SET name = $1, description = $2, revision = revision + 1
+		WHERE id = $3 AND ($4 = 0 OR revision = $5)
+	`, service.Name, service.Description, id, service.Revision, service.Revision)
+
+	if err != nil {
+		return mapError(err, "service")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ps.NotUpdated(ctx, "services", "service", id)
+	}
+
+	return nil
+}
+
+func (ps *postgresStore) PatchService(ctx context.Context, id int, p storage.ServicePatch) error {
+	var (
+		sets []string
+		args []interface{}
+	)
+	if p.Name != nil {
+		if existing, err := ps.existingServiceID(ctx, *p.Name, id); err != nil {
+			return err
+		} else if existing != 0 {
+			return storage.NewConflictError("service", existing, nil)
+		}
+		sets = append(sets, "name = ?")
+		args = append(args, *p.Name)
+	}
+	if p.Description != nil {
+		sets = append(sets, "description = ?")
+		args = append(args, *p.Description)
+	}
+	if len(sets) == 0 {
+		// Nothing to change, but the service must still exist and match.
+		var revision int64
+		if err := sqlx.GetContext(ctx, ps.q, &revision, `SELECT revision FROM services WHERE id = $1`, id); err != nil {
+			return mapError(err, "service")
+		}
+		if p.Revision != 0 && p.Revision != revision {
+			return storage.NewError(storage.ErrPreconditionFailed, "service has been modified", nil)
+		}
+		return nil
+	}
+
+	sets = append(sets, "revision = revision + 1")
+	query := "UPDATE services SET " + strings.Join(sets, ", ") + " WHERE id = ? AND (? = 0 OR revision = ?)"
+	args = append(args, id, p.Revision, p.Revision)
+	result, err := ps.q.ExecContext(ctx, ps.db.Rebind(query), args...)
+	if err != nil {
+		return mapError(err, "service")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ps.NotUpdated(ctx, "services", "service", id)
+	}
+	return nil
+}
+
+func (ps *postgresStore) DeleteService(ctx context.Context, id int, revision int64) error {
+	result, err := ps.q.ExecContext(ctx, `
+		DELETE FROM services
+		WHERE id = $1 AND ($2 = 0 OR revision = $3)
+	`, id, revision, revision)
+	if err != nil {
+		return mapError(err, "service")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ps.NotUpdated(ctx, "services", "service", id)
+	}
+	return nil
+}
+
+func (ps *postgresStore) CreateVersion(ctx context.Context, v *model.Version) (int64, error) {
+	var id int64
+	err := ps.inTx(ctx, func(tx *postgresStore) error {
+		var err error
+		if id, err = tx.insertVersion(ctx, v); err != nil {
+			return err
+		}
+		return tx.touchService(ctx, v.ServiceID)
+	})
+	return id, err
+}
+
+func (ps *postgresStore) insertVersion(ctx context.Context, v *model.Version) (int64, error) {
+	if id, err := ps.ExistingVersionID(ctx, v.ServiceID, v.Version); err != nil {
+		return 0, err
+	} else if id != 0 {
+		return 0, storage.NewConflictError("version", id, nil)
+	}
+
+	if v.State == "" {
+		v.State = model.VersionReleased
+	}
+	major, minor, patch, prerelease := storage.SemverColumns(v.Version)
+	var id int64
+	err := ps.q.QueryRowxContext(ctx, `
+		INSERT INTO versions (service_id, version, changelog, created_at, major, minor, patch, prerelease, state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, v.ServiceID, v.Version, v.Changelog, v.CreatedAt, major, minor, patch, prerelease, v.State).Scan(&id)
+	if err != nil {
+		return 0, mapError(err, "version")
+	}
+	return id, nil
+}
+
+func (ps *postgresStore) UpdateVersion(ctx context.Context, versionID int64, v *model.Version) error {
+	return ps.inTx(ctx, func(tx *postgresStore) error {
+		var current struct {
+			ServiceID int64              `db:"service_id"`
+			Version   string             `db:"version"`
+			State     model.VersionState `db:"state"`
+		}
+		if err := sqlx.GetContext(ctx, tx.q, &current, `SELECT service_id, version, state FROM versions WHERE id = $1`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+		// Renaming a released version would publish a new one past the
+		// approval and freeze gates.
+		if v.Version != current.Version && current.State != model.VersionDraft {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version %s is %s; only drafts can change their version", current.Version, current.State), nil)
+		}
+		serviceID := current.ServiceID
+		if id, err := tx.ExistingVersionID(ctx, serviceID, v.Version); err != nil {
+			return err
+		} else if id != 0 && id != versionID {
+			return storage.NewConflictError("version", id, nil)
+		}
+
+		major, minor, patch, prerelease := storage.SemverColumns(v.Version)
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE versions
+			SET version = $1, changelog = $2, major = $3, minor = $4, patch = $5, prerelease = $6, revision = revision + 1
+			WHERE id = $7 AND ($8 = 0 OR revision = $9)
+		`, v.Version, v.Changelog, major, minor, patch, prerelease, versionID, v.Revision, v.Revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, serviceID)
+	})
+}
+
+func (ps *postgresStore) GetVersionsByServiceID(ctx context.Context, serviceID int64, states []model.VersionState) ([]*model.Version, error) {
+	var exists bool
+	if err := sqlx.GetContext(ctx, ps.q, &exists, `SELECT EXISTS(SELECT 1 FROM services WHERE id = $1)`, serviceID); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE service_id = ?`
+	args := []interface{}{serviceID}
+	if len(states) > 0 {
+		query += " AND state IN (?" + strings.Repeat(", ?", len(states)-1) + ")"
+		for _, state := range states {
+			args = append(args, state)
+		}
+	}
+	query += " ORDER BY major IS NULL, major DESC, minor DESC, patch DESC, created_at DESC"
+
+	var versions []*model.Version
+	err := sqlx.SelectContext(ctx, ps.q, &versions, ps.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	// SQL cannot order prerelease identifiers, so finish the job in Go.
+	storage.SortVersions(versions)
+	return versions, nil
+}
+
+func (ps *postgresStore) GetVersionByID(ctx context.Context, versionID int64) (*model.Version, error) {
+	var version model.Version
+	err := sqlx.GetContext(ctx, ps.q, &version, `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE id = $1
+	`, versionID)
+	if err != nil {
+		return nil, mapError(err, "version")
+	}
+	return &version, nil
+}
+
+func (ps *postgresStore) DeleteVersionByID(ctx context.Context, versionID int64, revision int64) error {
+	return ps.inTx(ctx, func(tx *postgresStore) error {
+		var serviceID int64
+		if err := sqlx.GetContext(ctx, tx.q, &serviceID, `SELECT service_id FROM versions WHERE id = $1`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+
+		result, err := tx.q.ExecContext(ctx, `DELETE FROM versions WHERE id = $1 AND ($2 = 0 OR revision = $3)`, versionID, revision, revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, serviceID)
+	})
+}
+
+func (ps *postgresStore) TransitionVersion(ctx context.Context, versionID int64, to model.VersionState, reason string, revision int64) error {
+	return ps.inTx(ctx, func(tx *postgresStore) error {
+		var current struct {
+			ServiceID int64              `db:"service_id"`
+			State     model.VersionState `db:"state"`
+		}
+		if err := sqlx.GetContext(ctx, tx.q, &current, `SELECT service_id, state FROM versions WHERE id = $1`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+		if !current.State.CanTransitionTo(to) {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version cannot move from %s to %s", current.State, to), nil)
+		}
+
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE versions
+			SET state = $1, state_reason = $2, revision = revision + 1
+			WHERE id = $3 AND ($4 = 0 OR revision = $5)
+		`, to, reason, versionID, revision, revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, current.ServiceID)
+	})
+}
+
+func (ps *postgresStore) GetLatestVersion(ctx context.Context, serviceID int64, includePrerelease bool) (*model.Version, error) {
+	candidates, err := ps.semverVersions(ctx, serviceID, !includePrerelease)
+	if err != nil {
+		return nil, err
+	}
+	return storage.HighestVersion(candidates, func(semver.Version) bool { return true }), nil
+}
+
+func (ps *postgresStore) LatestVersions(ctx context.Context, serviceIDs []int64, includePrerelease bool) (map[int64]*model.Version, error) {
+	latest := make(map[int64]*model.Version, len(serviceIDs))
+	if len(serviceIDs) == 0 {
+		return latest, nil
+	}
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE service_id IN (?) AND major IS NOT NULL AND state IN ('released', 'deprecated')`
+	if !includePrerelease {
+		query += " AND prerelease = ''"
+	}
+	query, args, err := sqlx.In(query, serviceIDs)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*model.Version
+	if err := sqlx.SelectContext(ctx, ps.q, &versions, ps.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	byService := make(map[int64][]*model.Version)
+	for _, v := range versions {
+		byService[v.ServiceID] = append(byService[v.ServiceID], v)
+	}
+	for id, candidates := range byService {
+		latest[id] = storage.HighestVersion(candidates, func(semver.Version) bool { return true })
+	}
+	return latest, nil
+}
+
+func (ps *postgresStore) ResolveVersion(ctx context.Context, serviceID int64, c semver.Constraint) (*model.Version, error) {
+	candidates, err := ps.semverVersions(ctx, serviceID, false)
+	if err != nil {
+		return nil, err
+	}
+	return storage.HighestVersion(candidates, c.Check), nil
+}
+
+// semverVersions loads the published (released or deprecated) versions of a
+// service that have normalized semver columns, optionally without
+// prereleases.
+func (ps *postgresStore) semverVersions(ctx context.Context, serviceID int64, releasesOnly bool) ([]*model.Version, error) {
+	var exists bool
+	if err := sqlx.GetContext(ctx, ps.q, &exists, `SELECT EXISTS(SELECT 1 FROM services WHERE id = $1)`, serviceID); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE service_id = $1 AND major IS NOT NULL AND state IN ('released', 'deprecated')`
+	if releasesOnly {
+		query += " AND prerelease = ''"
+	}
+	var versions []*model.Version
+	err := sqlx.SelectContext(ctx, ps.q, &versions, query, serviceID)
+	return versions, err
+}
+
+// existingServiceID returns the ID of a service other than exceptID that
+// already uses name (compared case-insensitively), or 0. Checking up front
+// lets conflicts carry the existing ID, which a failed INSERT cannot report
+// portably; the unique index still catches concurrent writers.
+func (ps *postgresStore) existingServiceID(ctx context.Context, name string, exceptID int) (int64, error) {
+	var id int64
+	err := sqlx.GetContext(ctx, ps.q, &id, `SELECT id FROM services WHERE LOWER(name) = LOWER($1) AND id <> $2`, name, exceptID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// inTx runs fn with a store bound to a transaction, joining the current one
+// if there is one.
+func (ps *postgresStore) inTx(ctx context.Context, fn func(tx *postgresStore) error) error {
+	return ps.WithTx(ctx, func(tx storage.Storage) error {
+		return fn(tx.(*postgresStore))
+	})
+}
+
+// touchService bumps the revision of a service whose versions changed, so
+// its ETag changes along with its versions array.
+func (ps *postgresStore) touchService(ctx context.Context, serviceID int64) error {
+	_, err := ps.q.ExecContext(ctx, `UPDATE services SET revision = revision + 1 WHERE id = $1`, serviceID)
+	return err
+}
@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"os"
+	"testing"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/storage/storagetest"
+)
+
+// TestStorage runs the shared storage tests against the PostgreSQL database named
+// by POSTGRES_TEST_DSN. Every test migrates it down and up again, so it must be
+// a scratch database.
+func TestStorage(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storagetest.Open(t, "postgres", dsn)
+	})
+}
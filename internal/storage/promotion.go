@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+// CheckPromotion applies rule to promoting version. soakedSince is when the
+// version last succeeded in the rule's source environment, or the zero time
+// if it never did or another version has succeeded there since, and
+// approvals are the decisions for the rule's target.
+// The first gate that fails is returned as a *GateError: the path, then the
+// soak time, then the approvals.
+func CheckPromotion(rule model.PromotionRule, version string, soakedSince time.Time, approvals []model.Approval, now time.Time) error {
+	if soakedSince.IsZero() {
+		return NewGateError("path", fmt.Sprintf("version %s must be running in %s before it goes to %s", version, rule.From, rule.Environment))
+	}
+
+	soak := time.Duration(rule.MinSoakSeconds) * time.Second
+	if soaked := now.Sub(soakedSince); soaked < soak {
+		return NewGateError("soak", fmt.Sprintf("version %s has been in %s for %s; %s requires %s",
+			version, rule.From, soaked.Truncate(time.Second), rule.Environment, soak))
+	}
+
+	approved := 0
+	for _, a := range approvals {
+		switch a.Decision {
+		case model.ApprovalRejected:
+			return NewGateError("approvals", fmt.Sprintf("version %s was rejected for %s by %s", version, rule.Environment, a.Approver))
+		case model.ApprovalApproved:
+			approved++
+		}
+	}
+	if approved < rule.RequiredApprovals {
+		return NewGateError("approvals", fmt.Sprintf("version %s has %d of %d approvals required for %s",
+			version, approved, rule.RequiredApprovals, rule.Environment))
+	}
+	return nil
+}
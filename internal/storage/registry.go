@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Options configures how a backend opens its database.
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	SQLite SQLiteOptions
+}
+
+// SQLiteOptions are applied as connection pragmas by the sqlite3 backend and
+// ignored by the others.
+type SQLiteOptions struct {
+	JournalMode string
+	BusyTimeout time.Duration
+	ForeignKeys bool
+}
+
+// DefaultOptions returns the options used when no flags override them.
+func DefaultOptions() Options {
+	return Options{
+		MaxIdleConns: 2,
+		SQLite: SQLiteOptions{
+			JournalMode: "WAL",
+			BusyTimeout: 5 * time.Second,
+			ForeignKeys: true,
+		},
+	}
+}
+
+// Opener creates a Storage for a DSN. Backends register one per driver name.
+type Opener func(dsn string, opts Options) (Storage, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = make(map[string]Opener)
+)
+
+// Register makes a backend available under driver. It is meant to be called
+// from the backend package's init and panics on duplicates, like sql.Register.
+func Register(driver string, opener Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	if opener == nil {
+		panic("storage: Register opener is nil")
+	}
+	if _, dup := openers[driver]; dup {
+		panic("storage: Register called twice for driver " + driver)
+	}
+	openers[driver] = opener
+}
+
+// Drivers returns the sorted names of the registered backends.
+func Drivers() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+	drivers := make([]string, 0, len(openers))
+	for name := range openers {
+		drivers = append(drivers, name)
+	}
+	sort.Strings(drivers)
+	return drivers
+}
+
+// Open returns the Storage registered for driver, with the connection pool
+// configured from opts and the connection verified. Every write through it
+// records an audit event.
+func Open(driver, dsn string, opts Options) (Storage, error) {
+	openersMu.RLock()
+	opener, ok := openers[driver]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported DB driver: %s (registered: %v)", driver, Drivers())
+	}
+
+	store, err := opener(dsn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	db := store.DB()
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", driver, err)
+	}
+	return &auditStore{store}, nil
+}
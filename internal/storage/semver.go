@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+// SemverColumns returns the values for the major, minor, patch and
+// prerelease columns of a version row. They are all nil (NULL) when the
+// string is not a semantic version, which only happens when the semver
+// policy is disabled or for rows written before it existed.
+func SemverColumns(version string) (major, minor, patch, prerelease interface{}) {
+	v, err := semver.Parse(version)
+	if err != nil || v.Major > math.MaxInt64 || v.Minor > math.MaxInt64 || v.Patch > math.MaxInt64 {
+		return nil, nil, nil, nil
+	}
+	return int64(v.Major), int64(v.Minor), int64(v.Patch), strings.Join(v.Prerelease, ".")
+}
+
+// SameVersion reports whether a and b name the same version: they are equal,
+// or both are semantic versions with the same number and prerelease, so
+// v1.0.0 and 1.0.0+build.7 are the same but 1.0.0-RC1 and 1.0.0-rc1 are
+// not. This is what the unique index on the semver columns enforces.
+func SameVersion(a, b string) bool {
+	if a == b {
+		return true
+	}
+	va, errA := semver.Parse(a)
+	vb, errB := semver.Parse(b)
+	return errA == nil && errB == nil && va.Compare(vb) == 0 &&
+		strings.Join(va.Prerelease, ".") == strings.Join(vb.Prerelease, ".")
+}
+
+// SortVersions orders versions by semantic precedence, newest first. Strings
+// that are not semantic versions go last.
+func SortVersions(versions []*model.Version) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return semver.CompareStrings(versions[i].Version, versions[j].Version) > 0
+	})
+}
+
+// SortVersionStrings orders the versions array of a model.Service by
+// semantic precedence, oldest first. Strings that are not semantic versions
+// come first.
+func SortVersionStrings(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return semver.CompareStrings(versions[i], versions[j]) < 0
+	})
+}
+
+// HighestVersion returns the version with the highest precedence among those
+// that parse and satisfy keep, or nil.
+func HighestVersion(versions []*model.Version, keep func(semver.Version) bool) *model.Version {
+	var (
+		best   *model.Version
+		bestSV semver.Version
+	)
+	for _, v := range versions {
+		sv, err := semver.Parse(v.Version)
+		if err != nil || !keep(sv) {
+			continue
+		}
+		if best == nil || sv.Compare(bestSV) > 0 {
+			best, bestSV = v, sv
+		}
+	}
+	return best
+}
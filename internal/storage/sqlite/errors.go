@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// mapError translates go-sqlite3 errors into the storage error kinds, with a
+// client-safe message about entity ("service", "version", ...).
+func mapError(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.NewError(storage.ErrNotFound, entity+" not found", err)
+	}
+
+	var se sqlite3.Error
+	if !errors.As(err, &se) {
+		return err
+	}
+	switch se.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return storage.NewConflictError(entity, 0, err)
+	case sqlite3.ErrConstraintForeignKey:
+		// Our foreign keys cascade on delete, so a violation means the
+		// referenced parent row does not exist.
+		return storage.NewError(storage.ErrNotFound, entity+" references a record that does not exist", err)
+	case sqlite3.ErrConstraintNotNull, sqlite3.ErrConstraintCheck:
+		return storage.NewError(storage.ErrInvalid, "invalid "+entity, err)
+	}
+	return err
+}
@@ -3,50 +3,126 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/codecrafted007/service-catalog-api/internal/logger"
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
 	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/storage/sqlstore"
 	"github.com/codecrafted007/service-catalog-api/model"
 	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
 )
 
+func init() {
+	storage.Register("sqlite3", New)
+}
+
 type sqliteStore struct {
+	*sqlstore.Store
+
 	db *sqlx.DB
+	// q is db itself, or the open transaction for stores handed out by WithTx.
+	q sqlx.ExtContext
 }
 
-func New(path string) (storage.Storage, error) {
-	db, err := sqlx.Open("sqlite3", path)
+func New(path string, opts storage.Options) (storage.Storage, error) {
+	db, err := sqlx.Open("sqlite3", withPragmas(path, opts.SQLite))
 	if err != nil {
 		return nil, err
 	}
-	return &sqliteStore{db: db}, nil
+	return newStore(db, db), nil
+}
+
+// newStore returns a store running queries on q, which is db or one of its
+// transactions.
+func newStore(db *sqlx.DB, q sqlx.ExtContext) *sqliteStore {
+	s := &sqliteStore{db: db, q: q}
+	s.Store = sqlstore.New(db, q, sqlstore.Dialect{MapError: mapError}, s)
+	return s
+}
+
+// withPragmas appends the go-sqlite3 DSN parameters for opts. The driver
+// reads the first value of each parameter, so anything already present in
+// the DSN wins over the flags.
+func withPragmas(dsn string, opts storage.SQLiteOptions) string {
+	params := url.Values{}
+	if opts.JournalMode != "" {
+		params.Set("_journal_mode", opts.JournalMode)
+	}
+	if opts.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	}
+	if opts.ForeignKeys {
+		params.Set("_foreign_keys", "on")
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + params.Encode()
 }
 
 func (ss *sqliteStore) DB() *sqlx.DB {
 	return ss.db
 }
 
-func (ss *sqliteStore) ListServices(ctx context.Context, filter, sort string, page, limit int) ([]model.Service, error) {
+func (ss *sqliteStore) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	// Already inside a transaction: join it instead of nesting.
+	if _, ok := ss.q.(*sqlx.Tx); ok {
+		return fn(ss)
+	}
+
+	tx, err := ss.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(newStore(ss.db, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ss *sqliteStore) ListServices(ctx context.Context, filter storage.ServiceFilter, sort string, page, limit int) ([]model.Service, error) {
 	offset := (page - 1) * limit
 
 	var queryBuilder strings.Builder
 	queryBuilder.WriteString(`
-		SELECT s.id, s.name, s.description, s.created_at,
+		SELECT s.id, s.name, s.description, s.created_at, s.revision,
 		GROUP_CONCAT(v.version, ',') AS versions
 		FROM services s
-		LEFT JOIN versions v ON s.id = v.service_id`)
+		LEFT JOIN versions v ON s.id = v.service_id AND v.state <> 'yanked'`)
 
 	args := make([]interface{}, 0)
 	conditions := make([]string, 0)
 
-	if filter != "" {
-		conditions = append(conditions, "s.name LIKE ? OR s.description LIKE ?")
-		filterValue := fmt.Sprintf("%%%s%%", filter)
+	if filter.Text != "" {
+		conditions = append(conditions, "(s.name LIKE ? OR s.description LIKE ?)")
+		filterValue := fmt.Sprintf("%%%s%%", filter.Text)
 		args = append(args, filterValue, filterValue)
 	}
 
+	if filter.Owner != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM service_owners o LEFT JOIN teams t ON t.id = o.team_id
+			WHERE o.service_id = s.id AND (lower(t.name) = lower(?) OR lower(o.email) = lower(?) OR lower(o.chat) = lower(?)))`)
+		args = append(args, filter.Owner, filter.Owner, filter.Owner)
+	}
+
+	selConditions, selArgs := storage.SelectorConditions(filter.Selector)
+	conditions = append(conditions, selConditions...)
+	args = append(args, selArgs...)
+
 	if len(conditions) > 0 {
 		queryBuilder.WriteString(" WHERE ")
 		queryBuilder.WriteString(strings.Join(conditions, " AND "))
@@ -65,7 +141,7 @@ func (ss *sqliteStore) ListServices(ctx context.Context, filter, sort string, pa
 	queryBuilder.WriteString(" LIMIT ? OFFSET ?")
 	args = append(args, limit, offset)
 	logger.L().Infow("Executing query", "query", queryBuilder.String(), "args", args)
-	rows, err := ss.db.QueryContext(ctx, queryBuilder.String(), args...)
+	rows, err := ss.q.QueryContext(ctx, queryBuilder.String(), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +153,7 @@ func (ss *sqliteStore) ListServices(ctx context.Context, filter, sort string, pa
 		var svc model.Service
 		var versionsCSV sql.NullString
 
-		err := rows.Scan(&svc.ID, &svc.Name, &svc.Description, &svc.CreatedAt, &versionsCSV)
+		err := rows.Scan(&svc.ID, &svc.Name, &svc.Description, &svc.CreatedAt, &svc.Revision, &versionsCSV)
 		if err != nil {
 			return nil, err
 		}
@@ -86,19 +162,31 @@ func (ss *sqliteStore) ListServices(ctx context.Context, filter, sort string, pa
 		} else {
 			svc.Versions = []string{}
 		}
+		storage.SortVersionStrings(svc.Versions)
 		services = append(services, svc)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
+	loaded := make([]*model.Service, len(services))
+	for i := range services {
+		loaded[i] = &services[i]
+	}
+	if err := ss.AttachLabels(ctx, loaded...); err != nil {
+		return nil, err
+	}
 	return services, nil
 }
 
 func (ss *sqliteStore) GetServiceById(ctx context.Context, serviceId int) (*model.Service, error) {
-	rows, err := ss.db.Queryx(`
+	rows, err := ss.q.QueryxContext(ctx, `
 		SELECT 
-			s.id AS service_id, s.name, s.description, s.created_at AS service_created_at,
+			s.id AS service_id, s.name, s.description, s.created_at AS service_created_at, s.revision AS service_revision,
 			v.id AS version_id, v.version, v.created_at AS version_created_at
 		FROM services s
-		LEFT JOIN versions v ON s.id = v.service_id
+		LEFT JOIN versions v ON s.id = v.service_id AND v.state <> 'yanked'
 		WHERE s.id = ?
 	`, serviceId)
 	if err != nil {
@@ -111,11 +199,12 @@ func (ss *sqliteStore) GetServiceById(ctx context.Context, serviceId int) (*mode
 		var (
 			sid                        int
 			name, desc                 string
+			revision                   int64
 			svcCreatedAt, verCreatedAt sql.NullTime
 			verID                      sql.NullInt64
 			verStr                     sql.NullString
 		)
-		err := rows.Scan(&sid, &name, &desc, &svcCreatedAt, &verID, &verStr, &verCreatedAt)
+		err := rows.Scan(&sid, &name, &desc, &svcCreatedAt, &revision, &verID, &verStr, &verCreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -126,6 +215,7 @@ func (ss *sqliteStore) GetServiceById(ctx context.Context, serviceId int) (*mode
 				Name:        name,
 				Description: desc,
 				CreatedAt:   svcCreatedAt.Time,
+				Revision:    revision,
 			}
 		}
 
@@ -134,39 +224,72 @@ func (ss *sqliteStore) GetServiceById(ctx context.Context, serviceId int) (*mode
 		}
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
 	if svc == nil {
-		return nil, sql.ErrNoRows
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+	storage.SortVersionStrings(svc.Versions)
+
+	if svc.Ownership, err = ss.Ownership(ctx, int64(svc.ID)); err != nil {
+		return nil, err
+	}
+	if err := ss.AttachLabels(ctx, svc); err != nil {
+		return nil, err
 	}
 
 	return svc, nil
 }
 
-func (s *sqliteStore) IsValidAPIKey(key string) bool {
-	var exists bool
-	err := s.db.Get(&exists, `SELECT EXISTS(SELECT 1 FROM api_keys WHERE key = ?)`, key)
-	return err == nil && exists
+func (s *sqliteStore) GetAPIKey(key string) (*model.APIKey, error) {
+	var k model.APIKey
+	err := sqlx.GetContext(context.Background(), s.q, &k, `SELECT id, name, elevated, created_at FROM api_keys WHERE key = ?`, key)
+	if err != nil {
+		return nil, mapError(err, "API key")
+	}
+	return &k, nil
+}
+
+func (s *sqliteStore) CreateAPIKey(ctx context.Context, key, name string, elevated bool) error {
+	_, err := s.q.ExecContext(ctx, `INSERT INTO api_keys (key, name, elevated) VALUES (?, ?, ?)`, key, name, elevated)
+	return mapError(err, "API key")
 }
 
 func (s *sqliteStore) CreateService(ctx context.Context, service *model.Service) (int64, error) {
-	result, err := s.db.Exec(`
+	if id, err := s.existingServiceID(ctx, service.Name, 0); err != nil {
+		return 0, err
+	} else if id != 0 {
+		return 0, storage.NewConflictError("service", id, nil)
+	}
+
+	result, err := s.q.ExecContext(ctx, `
 		INSERT INTO services (name, description, created_at)
 		VALUES (?, ?, CURRENT_TIMESTAMP)
 	`, service.Name, service.Description)
 	if err != nil {
-		return 0, err
+		return 0, mapError(err, "service")
 	}
 	return result.LastInsertId()
 }
 
 func (s *sqliteStore) UpdateService(ctx context.Context, id int, service *model.Service) error {
-	result, err := s.db.Exec(`
+	if existing, err := s.existingServiceID(ctx, service.Name, id); err != nil {
+		return err
+	} else if existing != 0 {
+		return storage.NewConflictError("service", existing, nil)
+	}
+
+	result, err := s.q.ExecContext(ctx, `
 		UPDATE services
-		SET name = ?, description = ?
-		WHERE id = ?
-	`, service.Name, service.Description, id)
+		SET name = ?, description = ?, revision = revision + 1
+		WHERE id = ? AND (? = 0 OR revision = ?)
+	`, service.Name, service.Description, id, service.Revision, service.Revision)
 
 	if err != nil {
-		return err
+		return mapError(err, "service")
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -175,27 +298,108 @@ func (s *sqliteStore) UpdateService(ctx context.Context, id int, service *model.
 	}
 
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return s.NotUpdated(ctx, "services", "service", id)
+	}
+
+	return nil
+}
+
+func (s *sqliteStore) PatchService(ctx context.Context, id int, p storage.ServicePatch) error {
+	var (
+		sets []string
+		args []interface{}
+	)
+	if p.Name != nil {
+		if existing, err := s.existingServiceID(ctx, *p.Name, id); err != nil {
+			return err
+		} else if existing != 0 {
+			return storage.NewConflictError("service", existing, nil)
+		}
+		sets = append(sets, "name = ?")
+		args = append(args, *p.Name)
+	}
+	if p.Description != nil {
+		sets = append(sets, "description = ?")
+		args = append(args, *p.Description)
+	}
+	if len(sets) == 0 {
+		// Nothing to change, but the service must still exist and match.
+		var revision int64
+		if err := sqlx.GetContext(ctx, s.q, &revision, `SELECT revision FROM services WHERE id = ?`, id); err != nil {
+			return mapError(err, "service")
+		}
+		if p.Revision != 0 && p.Revision != revision {
+			return storage.NewError(storage.ErrPreconditionFailed, "service has been modified", nil)
+		}
+		return nil
 	}
 
+	sets = append(sets, "revision = revision + 1")
+	query := "UPDATE services SET " + strings.Join(sets, ", ") + " WHERE id = ? AND (? = 0 OR revision = ?)"
+	args = append(args, id, p.Revision, p.Revision)
+	result, err := s.q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return mapError(err, "service")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return s.NotUpdated(ctx, "services", "service", id)
+	}
 	return nil
 }
 
-func (s *sqliteStore) DeleteService(ctx context.Context, id int) error {
-	_, err := s.db.Exec(`
+func (s *sqliteStore) DeleteService(ctx context.Context, id int, revision int64) error {
+	result, err := s.q.ExecContext(ctx, `
 		DELETE FROM services
-		WHERE id = ?
-	`, id)
-	return err
+		WHERE id = ? AND (? = 0 OR revision = ?)
+	`, id, revision, revision)
+	if err != nil {
+		return mapError(err, "service")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return s.NotUpdated(ctx, "services", "service", id)
+	}
+	return nil
 }
 
 func (s *sqliteStore) CreateVersion(ctx context.Context, v *model.Version) (int64, error) {
-	result, err := s.db.ExecContext(ctx, `
-		INSERT INTO versions (service_id, version, changelog, created_at)
-		VALUES (?, ?, ?, ?)
-	`, v.ServiceID, v.Version, v.Changelog, v.CreatedAt)
-	if err != nil {
+	var id int64
+	err := s.inTx(ctx, func(tx *sqliteStore) error {
+		var err error
+		if id, err = tx.insertVersion(ctx, v); err != nil {
+			return err
+		}
+		return tx.touchService(ctx, v.ServiceID)
+	})
+	return id, err
+}
+
+func (s *sqliteStore) insertVersion(ctx context.Context, v *model.Version) (int64, error) {
+	if id, err := s.ExistingVersionID(ctx, v.ServiceID, v.Version); err != nil {
 		return 0, err
+	} else if id != 0 {
+		return 0, storage.NewConflictError("version", id, nil)
+	}
+
+	if v.State == "" {
+		v.State = model.VersionReleased
+	}
+	major, minor, patch, prerelease := storage.SemverColumns(v.Version)
+	result, err := s.q.ExecContext(ctx, `
+		INSERT INTO versions (service_id, version, changelog, created_at, major, minor, patch, prerelease, state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, v.ServiceID, v.Version, v.Changelog, v.CreatedAt, major, minor, patch, prerelease, v.State)
+	if err != nil {
+		return 0, mapError(err, "version")
 	}
 	lastInsertID, err := result.LastInsertId()
 	if err != nil {
@@ -204,38 +408,245 @@ func (s *sqliteStore) CreateVersion(ctx context.Context, v *model.Version) (int6
 	return lastInsertID, nil
 }
 
-func (s *sqliteStore) GetVersionsByServiceID(ctx context.Context, serviceID int64) ([]*model.Version, error) {
-	var versions []*model.Version
-	err := s.db.SelectContext(ctx, &versions, `
-		SELECT id, service_id, version, changelog, created_at
+func (s *sqliteStore) UpdateVersion(ctx context.Context, versionID int64, v *model.Version) error {
+	return s.inTx(ctx, func(tx *sqliteStore) error {
+		var current struct {
+			ServiceID int64              `db:"service_id"`
+			Version   string             `db:"version"`
+			State     model.VersionState `db:"state"`
+		}
+		if err := sqlx.GetContext(ctx, tx.q, &current, `SELECT service_id, version, state FROM versions WHERE id = ?`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+		// Renaming a released version would publish a new one past the
+		// approval and freeze gates.
+		if v.Version != current.Version && current.State != model.VersionDraft {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version %s is %s; only drafts can change their version", current.Version, current.State), nil)
+		}
+		serviceID := current.ServiceID
+		if id, err := tx.ExistingVersionID(ctx, serviceID, v.Version); err != nil {
+			return err
+		} else if id != 0 && id != versionID {
+			return storage.NewConflictError("version", id, nil)
+		}
+
+		major, minor, patch, prerelease := storage.SemverColumns(v.Version)
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE versions
+			SET version = ?, changelog = ?, major = ?, minor = ?, patch = ?, prerelease = ?, revision = revision + 1
+			WHERE id = ? AND (? = 0 OR revision = ?)
+		`, v.Version, v.Changelog, major, minor, patch, prerelease, versionID, v.Revision, v.Revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, serviceID)
+	})
+}
+
+func (s *sqliteStore) GetVersionsByServiceID(ctx context.Context, serviceID int64, states []model.VersionState) ([]*model.Version, error) {
+	var exists bool
+	if err := sqlx.GetContext(ctx, s.q, &exists, `SELECT EXISTS(SELECT 1 FROM services WHERE id = ?)`, serviceID); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
 		FROM versions
-		WHERE service_id = ?
-		ORDER BY created_at DESC`, serviceID)
-	return versions, err
+		WHERE service_id = ?`
+	args := []interface{}{serviceID}
+	if len(states) > 0 {
+		query += " AND state IN (?" + strings.Repeat(", ?", len(states)-1) + ")"
+		for _, state := range states {
+			args = append(args, state)
+		}
+	}
+	query += " ORDER BY major IS NULL, major DESC, minor DESC, patch DESC, created_at DESC"
+
+	var versions []*model.Version
+	err := sqlx.SelectContext(ctx, s.q, &versions, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	// SQL cannot order prerelease identifiers, so finish the job in Go.
+	storage.SortVersions(versions)
+	return versions, nil
 }
 
 func (s *sqliteStore) GetVersionByID(ctx context.Context, versionID int64) (*model.Version, error) {
 	var version model.Version
-	err := s.db.GetContext(ctx, &version, `
-		SELECT id, service_id, version, changelog, created_at
+	err := sqlx.GetContext(ctx, s.q, &version, `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
 		FROM versions
 		WHERE id = ?
 	`, versionID)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err, "version")
 	}
 	return &version, nil
 }
 
-func (s *sqliteStore) DeleteVersionByID(ctx context.Context, versionID int64) (bool, error) {
-	result, err := s.db.ExecContext(ctx, "DELETE FROM versions WHERE id = ?", versionID)
+func (s *sqliteStore) DeleteVersionByID(ctx context.Context, versionID int64, revision int64) error {
+	return s.inTx(ctx, func(tx *sqliteStore) error {
+		var serviceID int64
+		if err := sqlx.GetContext(ctx, tx.q, &serviceID, `SELECT service_id FROM versions WHERE id = ?`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+
+		result, err := tx.q.ExecContext(ctx, `DELETE FROM versions WHERE id = ? AND (? = 0 OR revision = ?)`, versionID, revision, revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, serviceID)
+	})
+}
+
+func (s *sqliteStore) TransitionVersion(ctx context.Context, versionID int64, to model.VersionState, reason string, revision int64) error {
+	return s.inTx(ctx, func(tx *sqliteStore) error {
+		var current struct {
+			ServiceID int64              `db:"service_id"`
+			State     model.VersionState `db:"state"`
+		}
+		if err := sqlx.GetContext(ctx, tx.q, &current, `SELECT service_id, state FROM versions WHERE id = ?`, versionID); err != nil {
+			return mapError(err, "version")
+		}
+		if !current.State.CanTransitionTo(to) {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version cannot move from %s to %s", current.State, to), nil)
+		}
+
+		result, err := tx.q.ExecContext(ctx, `
+			UPDATE versions
+			SET state = ?, state_reason = ?, revision = revision + 1
+			WHERE id = ? AND (? = 0 OR revision = ?)
+		`, to, reason, versionID, revision, revision)
+		if err != nil {
+			return mapError(err, "version")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return tx.NotUpdated(ctx, "versions", "version", versionID)
+		}
+		return tx.touchService(ctx, current.ServiceID)
+	})
+}
+
+func (s *sqliteStore) GetLatestVersion(ctx context.Context, serviceID int64, includePrerelease bool) (*model.Version, error) {
+	candidates, err := s.semverVersions(ctx, serviceID, !includePrerelease)
+	if err != nil {
+		return nil, err
+	}
+	return storage.HighestVersion(candidates, func(semver.Version) bool { return true }), nil
+}
+
+func (s *sqliteStore) LatestVersions(ctx context.Context, serviceIDs []int64, includePrerelease bool) (map[int64]*model.Version, error) {
+	latest := make(map[int64]*model.Version, len(serviceIDs))
+	if len(serviceIDs) == 0 {
+		return latest, nil
+	}
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE service_id IN (?) AND major IS NOT NULL AND state IN ('released', 'deprecated')`
+	if !includePrerelease {
+		query += " AND prerelease = ''"
+	}
+	query, args, err := sqlx.In(query, serviceIDs)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	var versions []*model.Version
+	if err := sqlx.SelectContext(ctx, s.q, &versions, s.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	byService := make(map[int64][]*model.Version)
+	for _, v := range versions {
+		byService[v.ServiceID] = append(byService[v.ServiceID], v)
 	}
+	for id, candidates := range byService {
+		latest[id] = storage.HighestVersion(candidates, func(semver.Version) bool { return true })
+	}
+	return latest, nil
+}
 
-	rows, err := result.RowsAffected()
+func (s *sqliteStore) ResolveVersion(ctx context.Context, serviceID int64, c semver.Constraint) (*model.Version, error) {
+	candidates, err := s.semverVersions(ctx, serviceID, false)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	return storage.HighestVersion(candidates, c.Check), nil
+}
+
+// semverVersions loads the published (released or deprecated) versions of a
+// service that have normalized semver columns, optionally without
+// prereleases.
+func (s *sqliteStore) semverVersions(ctx context.Context, serviceID int64, releasesOnly bool) ([]*model.Version, error) {
+	var exists bool
+	if err := sqlx.GetContext(ctx, s.q, &exists, `SELECT EXISTS(SELECT 1 FROM services WHERE id = ?)`, serviceID); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+
+	query := `
+		SELECT id, service_id, version, changelog, created_at, revision, state, state_reason
+		FROM versions
+		WHERE service_id = ? AND major IS NOT NULL AND state IN ('released', 'deprecated')`
+	if releasesOnly {
+		query += " AND prerelease = ''"
+	}
+	var versions []*model.Version
+	err := sqlx.SelectContext(ctx, s.q, &versions, query, serviceID)
+	return versions, err
+}
+
+// existingServiceID returns the ID of a service other than exceptID that
+// already uses name (compared case-insensitively), or 0. Checking up front
+// lets conflicts carry the existing ID, which a failed INSERT cannot report
+// portably; the unique index still catches concurrent writers.
+func (s *sqliteStore) existingServiceID(ctx context.Context, name string, exceptID int) (int64, error) {
+	var id int64
+	err := sqlx.GetContext(ctx, s.q, &id, `SELECT id FROM services WHERE lower(name) = lower(?) AND id <> ?`, name, exceptID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
 	}
-	return rows > 0, nil
+	return id, err
+}
+
+// inTx runs fn with a store bound to a transaction, joining the current one
+// if there is one.
+func (s *sqliteStore) inTx(ctx context.Context, fn func(tx *sqliteStore) error) error {
+	return s.WithTx(ctx, func(tx storage.Storage) error {
+		return fn(tx.(*sqliteStore))
+	})
+}
+
+// touchService bumps the revision of a service whose versions changed, so
+// its ETag changes along with its versions array.
+func (s *sqliteStore) touchService(ctx context.Context, serviceID int64) error {
+	_, err := s.q.ExecContext(ctx, `UPDATE services SET revision = revision + 1 WHERE id = ?`, serviceID)
+	return err
 }
@@ -0,0 +1,42 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/internal/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore opens a migrated store backed by a temporary database file.
+func newTestStore(t *testing.T) storage.Storage {
+	return storagetest.Open(t, "sqlite3", filepath.Join(t.TempDir(), "test.db"))
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, newTestStore)
+}
+
+func TestOpenAppliesPragmas(t *testing.T) {
+	store, err := storage.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"), storage.DefaultOptions())
+	require.NoError(t, err)
+	defer store.DB().Close()
+
+	var journalMode string
+	require.NoError(t, store.DB().Get(&journalMode, "PRAGMA journal_mode"))
+	assert.Equal(t, "wal", journalMode)
+
+	var foreignKeys, busyTimeout int
+	require.NoError(t, store.DB().Get(&foreignKeys, "PRAGMA foreign_keys"))
+	assert.Equal(t, 1, foreignKeys)
+	require.NoError(t, store.DB().Get(&busyTimeout, "PRAGMA busy_timeout"))
+	assert.Equal(t, 5000, busyTimeout)
+}
+
+func TestWithPragmasKeepsExistingQuery(t *testing.T) {
+	dsn := withPragmas("file:test.db?cache=shared", storage.SQLiteOptions{ForeignKeys: true})
+	assert.Equal(t, "file:test.db?cache=shared&_foreign_keys=on", dsn)
+	assert.Equal(t, "test.db", withPragmas("test.db", storage.SQLiteOptions{}))
+}
@@ -0,0 +1,256 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/jmoiron/sqlx"
+)
+
+const selectApprovalPolicies = `
+	SELECT id, service_id, label_key, label_value, required_approvals, created_at
+	FROM approval_policies`
+
+func (s *Store) ListApprovalPolicies(ctx context.Context) ([]model.ApprovalPolicy, error) {
+	policies := []model.ApprovalPolicy{}
+	err := s.sel(ctx, &policies, selectApprovalPolicies+` ORDER BY id`)
+	return policies, err
+}
+
+func (s *Store) GetApprovalPolicy(ctx context.Context, id int64) (*model.ApprovalPolicy, error) {
+	var p model.ApprovalPolicy
+	if err := s.get(ctx, &p, selectApprovalPolicies+` WHERE id = ?`, id); err != nil {
+		return nil, s.mapError(err, "approval policy")
+	}
+	return &p, nil
+}
+
+func (s *Store) CreateApprovalPolicy(ctx context.Context, p *model.ApprovalPolicy) (int64, error) {
+	var id int64
+	err := s.inTx(ctx, func(tx *Store) error {
+		var existing int64
+		var err error
+		if p.ServiceID != nil {
+			if _, err := tx.serviceName(ctx, *p.ServiceID); errors.Is(err, storage.ErrNotFound) {
+				return storage.NewError(storage.ErrInvalid, fmt.Sprintf("service %d does not exist", *p.ServiceID), nil)
+			} else if err != nil {
+				return err
+			}
+			err = tx.get(ctx, &existing, `SELECT id FROM approval_policies WHERE service_id = ?`, *p.ServiceID)
+		} else {
+			err = tx.get(ctx, &existing, `
+				SELECT id FROM approval_policies
+				WHERE service_id IS NULL AND label_key = ? AND label_value = ?
+			`, p.LabelKey, p.LabelValue)
+		}
+		if err == nil {
+			return storage.NewConflictError("approval policy", existing, nil)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		id, err = tx.insert(ctx, `
+			INSERT INTO approval_policies (service_id, label_key, label_value, required_approvals, created_at)
+			VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, p.ServiceID, p.LabelKey, p.LabelValue, p.RequiredApprovals)
+		return s.mapError(err, "approval policy")
+	})
+	return id, err
+}
+
+func (s *Store) DeleteApprovalPolicy(ctx context.Context, id int64) error {
+	result, err := s.exec(ctx, `DELETE FROM approval_policies WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return deleted(result, "approval policy not found")
+}
+
+func (s *Store) RequiredApprovals(ctx context.Context, serviceID int64) (int, error) {
+	var required int
+	err := s.get(ctx, &required, `
+		SELECT COALESCE(MAX(p.required_approvals), 0)
+		FROM approval_policies p
+		WHERE p.service_id = ?
+			OR (p.service_id IS NULL AND EXISTS (
+				SELECT 1 FROM service_labels l
+				WHERE l.service_id = ? AND l.name = p.label_key
+					AND (p.label_value = '' OR l.value = p.label_value)))
+	`, serviceID, serviceID)
+	return required, err
+}
+
+func (s *Store) CreateApprovalRequest(ctx context.Context, req *model.ApprovalRequest) (int64, error) {
+	var id int64
+	err := s.inTx(ctx, func(tx *Store) error {
+		v, err := tx.backend.GetVersionByID(ctx, req.VersionID)
+		if err != nil {
+			return err
+		}
+		if v.State != model.VersionDraft {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version %s is %s; only drafts can be submitted for release", v.Version, v.State), nil)
+		}
+
+		var pending int64
+		err = tx.get(ctx, &pending, `SELECT id FROM approval_requests WHERE version_id = ? AND status = ?`,
+			req.VersionID, model.ApprovalRequestPending)
+		if err == nil {
+			return storage.NewConflictError("approval request", pending, nil)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if req.RequiredApprovals, err = tx.RequiredApprovals(ctx, v.ServiceID); err != nil {
+			return err
+		} else if req.RequiredApprovals == 0 {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version %s needs no approvals to be released", v.Version), nil)
+		}
+		req.ServiceID, req.Version, req.Status = v.ServiceID, v.Version, model.ApprovalRequestPending
+
+		id, err = tx.insert(ctx, `
+			INSERT INTO approval_requests (version_id, requested_by, comment, status, required_approvals, created_at)
+			VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, req.VersionID, req.RequestedBy, req.Comment, req.Status, req.RequiredApprovals)
+		return s.mapError(err, "approval request")
+	})
+	return id, err
+}
+
+const selectApprovalRequests = `
+	SELECT r.id, r.version_id, v.service_id, v.version, r.requested_by, r.comment, r.status,
+		r.required_approvals, r.created_at, r.resolved_at
+	FROM approval_requests r
+	JOIN versions v ON v.id = r.version_id`
+
+func (s *Store) GetApprovalRequest(ctx context.Context, id int64) (*model.ApprovalRequest, error) {
+	var req model.ApprovalRequest
+	if err := s.get(ctx, &req, selectApprovalRequests+` WHERE r.id = ?`, id); err != nil {
+		return nil, s.mapError(err, "approval request")
+	}
+	if err := s.attachReviews(ctx, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (s *Store) ListApprovalRequests(ctx context.Context, filter storage.ApprovalRequestFilter, page, limit int) ([]model.ApprovalRequest, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Status != "" {
+		conditions = append(conditions, `r.status = ?`)
+		args = append(args, filter.Status)
+	}
+	if filter.ServiceID != 0 {
+		conditions = append(conditions, `v.service_id = ?`)
+		args = append(args, filter.ServiceID)
+	}
+	if filter.VersionID != 0 {
+		conditions = append(conditions, `r.version_id = ?`)
+		args = append(args, filter.VersionID)
+	}
+
+	query := selectApprovalRequests
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY r.created_at, r.id LIMIT ? OFFSET ?`
+	args = append(args, limit, (page-1)*limit)
+
+	requests := []model.ApprovalRequest{}
+	if err := s.sel(ctx, &requests, query, args...); err != nil {
+		return nil, err
+	}
+	loaded := make([]*model.ApprovalRequest, len(requests))
+	for i := range requests {
+		loaded[i] = &requests[i]
+	}
+	if err := s.attachReviews(ctx, loaded...); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (s *Store) ReviewApprovalRequest(ctx context.Context, id int64, review *model.ApprovalReview) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		req, err := tx.GetApprovalRequest(ctx, id)
+		if err != nil {
+			return err
+		}
+		if req.Status != model.ApprovalRequestPending {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("approval request is already %s", req.Status), nil)
+		}
+		if review.Decision == model.ApprovalApproved && review.Reviewer == req.RequestedBy {
+			return storage.NewError(storage.ErrConflict, "requesters cannot approve their own request", nil)
+		}
+
+		review.RequestID = id
+		if _, err := tx.exec(ctx, `DELETE FROM approval_reviews WHERE request_id = ? AND reviewer = ?`, id, review.Reviewer); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, `
+			INSERT INTO approval_reviews (request_id, reviewer, decision, comment, created_at)
+			VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, review.RequestID, review.Reviewer, review.Decision, review.Comment); err != nil {
+			return s.mapError(err, "approval review")
+		}
+
+		status := model.ApprovalRequestRejected
+		var approvers []string
+		if review.Decision == model.ApprovalApproved {
+			for _, earlier := range req.Reviews {
+				if earlier.Reviewer != review.Reviewer && earlier.Decision == model.ApprovalApproved {
+					approvers = append(approvers, earlier.Reviewer)
+				}
+			}
+			approvers = append(approvers, review.Reviewer)
+			if len(approvers) < req.RequiredApprovals {
+				return nil
+			}
+			status = model.ApprovalRequestApproved
+		}
+
+		if _, err := tx.exec(ctx, `UPDATE approval_requests SET status = ?, resolved_at = CURRENT_TIMESTAMP WHERE id = ?`, status, id); err != nil {
+			return err
+		}
+		if status == model.ApprovalRequestRejected {
+			return nil
+		}
+		return tx.backend.TransitionVersion(ctx, req.VersionID, model.VersionReleased, "approved by "+strings.Join(approvers, ", "), 0)
+	})
+}
+
+// attachReviews fills in the Reviews of each request, oldest first.
+func (s *Store) attachReviews(ctx context.Context, requests ...*model.ApprovalRequest) error {
+	if len(requests) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*model.ApprovalRequest, len(requests))
+	ids := make([]int64, 0, len(requests))
+	for _, req := range requests {
+		req.Reviews = []model.ApprovalReview{}
+		byID[req.ID] = req
+		ids = append(ids, req.ID)
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT id, request_id, reviewer, decision, comment, created_at
+		FROM approval_reviews
+		WHERE request_id IN (?)
+		ORDER BY created_at, id`, ids)
+	if err != nil {
+		return err
+	}
+	var reviews []model.ApprovalReview
+	if err := s.sel(ctx, &reviews, query, args...); err != nil {
+		return err
+	}
+	for _, review := range reviews {
+		byID[review.RequestID].Reviews = append(byID[review.RequestID].Reviews, review)
+	}
+	return nil
+}
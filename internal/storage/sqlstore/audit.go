@@ -0,0 +1,52 @@
+package sqlstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+func (s *Store) AddAuditEvent(ctx context.Context, e *model.AuditEvent) error {
+	var err error
+	e.ID, err = s.insert(ctx, `
+		INSERT INTO audit_events (actor, action, entity_type, entity_id, before_json, after_json, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.Actor, e.Action, e.EntityType, e.EntityID, e.Before, e.After, e.RequestID, e.CreatedAt)
+	return err
+}
+
+func (s *Store) ListAuditEvents(ctx context.Context, filter storage.AuditFilter, page, limit int) ([]model.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.EntityType != "" {
+		conditions = append(conditions, `entity_type = ?`)
+		args = append(args, filter.EntityType)
+		if filter.EntityID != 0 {
+			conditions = append(conditions, `entity_id = ?`)
+			args = append(args, filter.EntityID)
+		}
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, `actor = ?`)
+		args = append(args, filter.Actor)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, `created_at >= ?`)
+		args = append(args, filter.Since.UTC())
+	}
+
+	query := `
+		SELECT id, actor, action, entity_type, entity_id, before_json, after_json, request_id, created_at
+		FROM audit_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at, id LIMIT ? OFFSET ?`
+	args = append(args, limit, (page-1)*limit)
+
+	events := []model.AuditEvent{}
+	err := s.sel(ctx, &events, query, args...)
+	return events, err
+}
@@ -0,0 +1,139 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/jmoiron/sqlx"
+)
+
+func (s *Store) AddDependency(ctx context.Context, d *model.Dependency) (int64, error) {
+	var id int64
+	err := s.inTx(ctx, func(tx *Store) error {
+		from, err := tx.serviceName(ctx, d.ServiceID)
+		if err != nil {
+			return err
+		}
+		to, err := tx.serviceName(ctx, d.DependsOnID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return storage.NewError(storage.ErrInvalid, fmt.Sprintf("service %d does not exist", d.DependsOnID), nil)
+		} else if err != nil {
+			return err
+		}
+
+		var existing int64
+		err = tx.get(ctx, &existing, `SELECT id FROM service_dependencies WHERE service_id = ? AND depends_on_id = ?`, d.ServiceID, d.DependsOnID)
+		if err == nil {
+			return storage.NewConflictError("dependency", existing, nil)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// The new edge closes a cycle if the service is already reachable
+		// from the one it would depend on.
+		if d.ServiceID == d.DependsOnID {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("dependency would create a cycle: %s -> %s", from, to), nil)
+		}
+		reachable, err := storage.WalkDependencies(d.DependsOnID, 0, func(frontier []int64) ([]storage.DependencyEdge, error) {
+			return tx.dependencyEdges(ctx, false, frontier)
+		})
+		if err != nil {
+			return err
+		}
+		if path := storage.DependencyPath(reachable, d.ServiceID); path != nil {
+			cycle := append([]string{from, to}, path...)
+			return storage.NewError(storage.ErrConflict, "dependency would create a cycle: "+strings.Join(cycle, " -> "), nil)
+		}
+
+		id, err = tx.insert(ctx, `
+			INSERT INTO service_dependencies (service_id, depends_on_id, version_constraint, created_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		`, d.ServiceID, d.DependsOnID, d.Constraint)
+		return s.mapError(err, "dependency")
+	})
+	return id, err
+}
+
+func (s *Store) GetDependency(ctx context.Context, id int64) (*model.Dependency, error) {
+	var d model.Dependency
+	err := s.get(ctx, &d, `
+		SELECT id, service_id, depends_on_id, version_constraint, created_at
+		FROM service_dependencies
+		WHERE id = ?
+	`, id)
+	if err != nil {
+		return nil, s.mapError(err, "dependency")
+	}
+	return &d, nil
+}
+
+func (s *Store) ListAllDependencies(ctx context.Context) ([]model.Dependency, error) {
+	deps := []model.Dependency{}
+	err := s.sel(ctx, &deps, `
+		SELECT id, service_id, depends_on_id, version_constraint, created_at
+		FROM service_dependencies
+		ORDER BY service_id, depends_on_id`)
+	return deps, err
+}
+
+func (s *Store) RemoveDependency(ctx context.Context, serviceID, dependsOnID int64) error {
+	result, err := s.exec(ctx, `DELETE FROM service_dependencies WHERE service_id = ? AND depends_on_id = ?`, serviceID, dependsOnID)
+	if err != nil {
+		return err
+	}
+	return deleted(result, "dependency not found")
+}
+
+func (s *Store) ListDependencies(ctx context.Context, serviceID int64, depth int) ([]model.DependencyNode, error) {
+	if _, err := s.serviceName(ctx, serviceID); err != nil {
+		return nil, err
+	}
+	return storage.WalkDependencies(serviceID, depth, func(frontier []int64) ([]storage.DependencyEdge, error) {
+		return s.dependencyEdges(ctx, false, frontier)
+	})
+}
+
+func (s *Store) ListDependents(ctx context.Context, serviceID int64, depth int) ([]model.DependencyNode, error) {
+	if _, err := s.serviceName(ctx, serviceID); err != nil {
+		return nil, err
+	}
+	return storage.WalkDependencies(serviceID, depth, func(frontier []int64) ([]storage.DependencyEdge, error) {
+		return s.dependencyEdges(ctx, true, frontier)
+	})
+}
+
+// dependencyEdges loads the edges leaving the frontier services: their
+// dependencies, or their dependents when reverse is set.
+func (s *Store) dependencyEdges(ctx context.Context, reverse bool, frontier []int64) ([]storage.DependencyEdge, error) {
+	near, far := "service_id", "depends_on_id"
+	if reverse {
+		near, far = far, near
+	}
+	query, args, err := sqlx.In(fmt.Sprintf(`
+		SELECT d.%[1]s AS near, d.%[2]s AS far, s.name, d.version_constraint
+		FROM service_dependencies d
+		JOIN services s ON s.id = d.%[2]s
+		WHERE d.%[1]s IN (?)
+		ORDER BY s.name`, near, far), frontier)
+	if err != nil {
+		return nil, err
+	}
+	var edges []storage.DependencyEdge
+	err = s.sel(ctx, &edges, query, args...)
+	return edges, err
+}
+
+// serviceName returns the name of a service, or ErrNotFound.
+func (s *Store) serviceName(ctx context.Context, id int64) (string, error) {
+	var name string
+	err := s.get(ctx, &name, `SELECT name FROM services WHERE id = ?`, id)
+	if err != nil {
+		return "", s.mapError(err, "service")
+	}
+	return name, nil
+}
@@ -0,0 +1,185 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+func (s *Store) ListEnvironments(ctx context.Context) ([]model.Environment, error) {
+	envs := []model.Environment{}
+	err := s.sel(ctx, &envs, `
+		SELECT id, name, description, created_at
+		FROM environments
+		ORDER BY id`)
+	return envs, err
+}
+
+func (s *Store) GetEnvironment(ctx context.Context, id int64) (*model.Environment, error) {
+	var env model.Environment
+	err := s.get(ctx, &env, `
+		SELECT id, name, description, created_at
+		FROM environments
+		WHERE id = ?
+	`, id)
+	if err != nil {
+		return nil, s.mapError(err, "environment")
+	}
+	return &env, nil
+}
+
+func (s *Store) CreateEnvironment(ctx context.Context, e *model.Environment) (int64, error) {
+	if id, err := s.environmentID(ctx, e.Name); err != nil {
+		return 0, err
+	} else if id != 0 {
+		return 0, storage.NewConflictError("environment", id, nil)
+	}
+
+	id, err := s.insert(ctx, `
+		INSERT INTO environments (name, description, created_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, e.Name, e.Description)
+	return id, s.mapError(err, "environment")
+}
+
+func (s *Store) DeleteEnvironment(ctx context.Context, id int64) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		var used bool
+		if err := tx.get(ctx, &used, `SELECT EXISTS(SELECT 1 FROM deployments WHERE environment_id = ?)`, id); err != nil {
+			return err
+		}
+		if used {
+			return storage.NewError(storage.ErrConflict, "environment has deployments", nil)
+		}
+		var promotedFrom bool
+		if err := tx.get(ctx, &promotedFrom, `SELECT EXISTS(SELECT 1 FROM promotion_rules WHERE from_environment_id = ?)`, id); err != nil {
+			return err
+		}
+		if promotedFrom {
+			return storage.NewError(storage.ErrConflict, "environment is on a promotion path", nil)
+		}
+
+		result, err := tx.exec(ctx, `DELETE FROM environments WHERE id = ?`, id)
+		if err != nil {
+			return s.mapError(err, "environment")
+		}
+		return deleted(result, "environment not found")
+	})
+}
+
+func (s *Store) CreateDeployment(ctx context.Context, d *model.Deployment) (int64, error) {
+	var id int64
+	err := s.inTx(ctx, func(tx *Store) error {
+		if _, err := tx.serviceName(ctx, d.ServiceID); err != nil {
+			return err
+		}
+
+		// v1.0.0 and 1.0.0 are the same version, as they are for CreateVersion.
+		versionID, err := tx.ExistingVersionID(ctx, d.ServiceID, d.Version)
+		if err != nil {
+			return err
+		} else if versionID == 0 {
+			return storage.NewError(storage.ErrInvalid, fmt.Sprintf("version %s does not exist", d.Version), nil)
+		}
+		version, err := tx.backend.GetVersionByID(ctx, versionID)
+		if err != nil {
+			return err
+		}
+		// Drafts may still be awaiting release approval.
+		if version.State != model.VersionReleased && version.State != model.VersionDeprecated {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("version %s is %s; only released or deprecated versions can be deployed", version.Version, version.State), nil)
+		}
+		d.VersionID, d.Version = version.ID, version.Version
+
+		if d.EnvironmentID, err = tx.environmentID(ctx, d.Environment); err != nil {
+			return err
+		} else if d.EnvironmentID == 0 {
+			return storage.NewError(storage.ErrInvalid, fmt.Sprintf("environment %s does not exist", d.Environment), nil)
+		}
+		if err := tx.checkPromotion(ctx, d); err != nil {
+			return err
+		}
+
+		id, err = tx.insert(ctx, `
+			INSERT INTO deployments (service_id, version_id, environment_id, status, actor, triggered_by, deployed_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, d.ServiceID, d.VersionID, d.EnvironmentID, d.Status, d.Actor, d.TriggeredBy, d.DeployedAt)
+		return s.mapError(err, "deployment")
+	})
+	return id, err
+}
+
+const selectDeployments = `
+	SELECT d.id, d.service_id, d.version_id, v.version, d.environment_id, e.name AS environment, d.status, d.actor, d.triggered_by, d.deployed_at
+	FROM deployments d
+	JOIN versions v ON v.id = d.version_id
+	JOIN environments e ON e.id = d.environment_id`
+
+func (s *Store) GetDeployment(ctx context.Context, id int64) (*model.Deployment, error) {
+	var d model.Deployment
+	if err := s.get(ctx, &d, selectDeployments+` WHERE d.id = ?`, id); err != nil {
+		return nil, s.mapError(err, "deployment")
+	}
+	return &d, nil
+}
+
+func (s *Store) ListDeployments(ctx context.Context, serviceID int64, environment string, page, limit int) ([]model.Deployment, error) {
+	if _, err := s.serviceName(ctx, serviceID); err != nil {
+		return nil, err
+	}
+
+	query := selectDeployments + ` WHERE d.service_id = ?`
+	args := []interface{}{serviceID}
+	if environment != "" {
+		query += ` AND lower(e.name) = lower(?)`
+		args = append(args, environment)
+	}
+	query += ` ORDER BY d.deployed_at DESC, d.id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, (page-1)*limit)
+
+	deployments := []model.Deployment{}
+	err := s.sel(ctx, &deployments, query, args...)
+	return deployments, err
+}
+
+func (s *Store) ServiceEnvironments(ctx context.Context, serviceID int64) ([]model.EnvironmentStatus, error) {
+	if _, err := s.serviceName(ctx, serviceID); err != nil {
+		return nil, err
+	}
+	envs, err := s.ListEnvironments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var succeeded []model.Deployment
+	query := selectDeployments + ` WHERE d.service_id = ? AND d.status = ? ORDER BY d.deployed_at DESC, d.id DESC`
+	if err := s.sel(ctx, &succeeded, query, serviceID, model.DeploymentSucceeded); err != nil {
+		return nil, err
+	}
+	current := make(map[int64]*model.Deployment)
+	for i := range succeeded {
+		if _, ok := current[succeeded[i].EnvironmentID]; !ok {
+			current[succeeded[i].EnvironmentID] = &succeeded[i]
+		}
+	}
+
+	statuses := make([]model.EnvironmentStatus, len(envs))
+	for i, env := range envs {
+		statuses[i] = model.EnvironmentStatus{Environment: env, Current: current[env.ID]}
+	}
+	return statuses, nil
+}
+
+// environmentID finds an environment by name, ignoring case, or returns 0.
+func (s *Store) environmentID(ctx context.Context, name string) (int64, error) {
+	var id int64
+	err := s.get(ctx, &id, `SELECT id FROM environments WHERE lower(name) = lower(?)`, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
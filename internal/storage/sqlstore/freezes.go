@@ -0,0 +1,89 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+const selectFreezes = `
+	SELECT id, starts_at, ends_at, selector, team_id, reason, created_by, created_at
+	FROM freezes`
+
+func (s *Store) ListFreezes(ctx context.Context) ([]model.Freeze, error) {
+	freezes := []model.Freeze{}
+	err := s.sel(ctx, &freezes, selectFreezes+` ORDER BY starts_at, id`)
+	return freezes, err
+}
+
+func (s *Store) GetFreeze(ctx context.Context, id int64) (*model.Freeze, error) {
+	var f model.Freeze
+	if err := s.get(ctx, &f, selectFreezes+` WHERE id = ?`, id); err != nil {
+		return nil, s.mapError(err, "freeze")
+	}
+	return &f, nil
+}
+
+func (s *Store) CreateFreeze(ctx context.Context, f *model.Freeze) (int64, error) {
+	if !f.EndsAt.After(f.StartsAt) {
+		return 0, storage.NewError(storage.ErrInvalid, "a freeze must end after it starts", nil)
+	}
+	var id int64
+	err := s.inTx(ctx, func(tx *Store) error {
+		if f.TeamID != nil {
+			if _, err := tx.GetTeam(ctx, *f.TeamID); errors.Is(err, storage.ErrNotFound) {
+				return storage.NewError(storage.ErrInvalid, fmt.Sprintf("team %d does not exist", *f.TeamID), nil)
+			} else if err != nil {
+				return err
+			}
+		}
+		f.StartsAt, f.EndsAt = f.StartsAt.UTC(), f.EndsAt.UTC()
+
+		var err error
+		id, err = tx.insert(ctx, `
+			INSERT INTO freezes (starts_at, ends_at, selector, team_id, reason, created_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, f.StartsAt, f.EndsAt, f.Selector, f.TeamID, f.Reason, f.CreatedBy)
+		return err
+	})
+	return id, err
+}
+
+func (s *Store) DeleteFreeze(ctx context.Context, id int64) error {
+	result, err := s.exec(ctx, `DELETE FROM freezes WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return deleted(result, "freeze not found")
+}
+
+func (s *Store) ActiveFreezes(ctx context.Context, serviceID int64, at time.Time) ([]model.Freeze, error) {
+	var svc *model.Service
+	if serviceID != 0 {
+		var err error
+		if svc, err = s.backend.GetServiceById(ctx, int(serviceID)); err != nil {
+			return nil, err
+		}
+	}
+
+	var active []model.Freeze
+	at = at.UTC()
+	err := s.sel(ctx, &active, selectFreezes+`
+		WHERE starts_at <= ? AND ends_at > ?
+		ORDER BY ends_at DESC, id`, at, at)
+	if err != nil {
+		return nil, err
+	}
+
+	freezes := []model.Freeze{}
+	for _, f := range active {
+		if svc == nil || storage.FreezeCovers(f, svc) {
+			freezes = append(freezes, f)
+		}
+	}
+	return freezes, nil
+}
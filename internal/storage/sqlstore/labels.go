@@ -0,0 +1,128 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/jmoiron/sqlx"
+)
+
+func (s *Store) SetServiceLabel(ctx context.Context, serviceID int64, key, value string, revision int64) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		if err := tx.reviseService(ctx, serviceID, revision); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, `DELETE FROM service_labels WHERE service_id = ? AND name = ?`, serviceID, key); err != nil {
+			return err
+		}
+		_, err := tx.exec(ctx, `INSERT INTO service_labels (service_id, name, value) VALUES (?, ?, ?)`, serviceID, key, value)
+		return s.mapError(err, "label")
+	})
+}
+
+func (s *Store) RemoveServiceLabel(ctx context.Context, serviceID int64, key string, revision int64) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		if err := tx.reviseService(ctx, serviceID, revision); err != nil {
+			return err
+		}
+		result, err := tx.exec(ctx, `DELETE FROM service_labels WHERE service_id = ? AND name = ?`, serviceID, key)
+		if err != nil {
+			return err
+		}
+		return deleted(result, "label not found")
+	})
+}
+
+func (s *Store) AddServiceTag(ctx context.Context, serviceID int64, tag string, revision int64) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		if err := tx.reviseService(ctx, serviceID, revision); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, `DELETE FROM service_tags WHERE service_id = ? AND tag = ?`, serviceID, tag); err != nil {
+			return err
+		}
+		_, err := tx.exec(ctx, `INSERT INTO service_tags (service_id, tag) VALUES (?, ?)`, serviceID, tag)
+		return s.mapError(err, "tag")
+	})
+}
+
+func (s *Store) RemoveServiceTag(ctx context.Context, serviceID int64, tag string, revision int64) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		if err := tx.reviseService(ctx, serviceID, revision); err != nil {
+			return err
+		}
+		result, err := tx.exec(ctx, `DELETE FROM service_tags WHERE service_id = ? AND tag = ?`, serviceID, tag)
+		if err != nil {
+			return err
+		}
+		return deleted(result, "tag not found")
+	})
+}
+
+// AttachLabels loads the labels and tags of services that are known to
+// exist, with two queries however many services there are.
+func (s *Store) AttachLabels(ctx context.Context, services ...*model.Service) error {
+	if len(services) == 0 {
+		return nil
+	}
+	byID := make(map[int]*model.Service, len(services))
+	ids := make([]int, 0, len(services))
+	for _, svc := range services {
+		svc.Labels = map[string]string{}
+		svc.Tags = []string{}
+		byID[svc.ID] = svc
+		ids = append(ids, svc.ID)
+	}
+
+	query, args, err := sqlx.In(`SELECT service_id, name, value FROM service_labels WHERE service_id IN (?)`, ids)
+	if err != nil {
+		return err
+	}
+	var labelRows []struct {
+		ServiceID int    `db:"service_id"`
+		Name      string `db:"name"`
+		Value     string `db:"value"`
+	}
+	if err := s.sel(ctx, &labelRows, query, args...); err != nil {
+		return err
+	}
+	for _, row := range labelRows {
+		byID[row.ServiceID].Labels[row.Name] = row.Value
+	}
+
+	query, args, err = sqlx.In(`SELECT service_id, tag FROM service_tags WHERE service_id IN (?) ORDER BY tag`, ids)
+	if err != nil {
+		return err
+	}
+	var tagRows []struct {
+		ServiceID int    `db:"service_id"`
+		Tag       string `db:"tag"`
+	}
+	if err := s.sel(ctx, &tagRows, query, args...); err != nil {
+		return err
+	}
+	for _, row := range tagRows {
+		byID[row.ServiceID].Tags = append(byID[row.ServiceID].Tags, row.Tag)
+	}
+	return nil
+}
+
+// reviseService bumps the revision of a service whose representation is
+// about to change, failing if it does not match the expected revision.
+func (s *Store) reviseService(ctx context.Context, serviceID int64, revision int64) error {
+	result, err := s.exec(ctx, `
+		UPDATE services SET revision = revision + 1
+		WHERE id = ? AND (? = 0 OR revision = ?)
+	`, serviceID, revision, revision)
+	if err != nil {
+		return s.mapError(err, "service")
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return s.NotUpdated(ctx, "services", "service", serviceID)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+const selectPromotionRules = `
+	SELECT r.environment_id, e.name AS environment, r.from_environment_id, f.name AS from_environment,
+		r.min_soak_seconds, r.required_approvals
+	FROM promotion_rules r
+	JOIN environments e ON e.id = r.environment_id
+	JOIN environments f ON f.id = r.from_environment_id`
+
+func (s *Store) ListPromotionRules(ctx context.Context) ([]model.PromotionRule, error) {
+	rules := []model.PromotionRule{}
+	err := s.sel(ctx, &rules, selectPromotionRules+` ORDER BY r.environment_id`)
+	return rules, err
+}
+
+func (s *Store) GetPromotionRule(ctx context.Context, environmentID int64) (*model.PromotionRule, error) {
+	var rule model.PromotionRule
+	err := s.get(ctx, &rule, selectPromotionRules+` WHERE r.environment_id = ?`, environmentID)
+	if err != nil {
+		return nil, s.mapError(err, "promotion rule")
+	}
+	return &rule, nil
+}
+
+func (s *Store) SetPromotionRule(ctx context.Context, r *model.PromotionRule) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		if _, err := tx.GetEnvironment(ctx, r.EnvironmentID); err != nil {
+			return err
+		}
+		from, err := tx.environmentID(ctx, r.From)
+		if err != nil {
+			return err
+		} else if from == 0 {
+			return storage.NewError(storage.ErrInvalid, fmt.Sprintf("environment %s does not exist", r.From), nil)
+		} else if from == r.EnvironmentID {
+			return storage.NewError(storage.ErrInvalid, "an environment cannot be promoted from itself", nil)
+		}
+
+		// Existing paths never loop, so following the source's path back
+		// either ends or reaches the target, which the new rule would turn
+		// into a loop.
+		for id := from; id != 0; {
+			if id == r.EnvironmentID {
+				return storage.NewError(storage.ErrConflict, "promotion path would loop back to the environment", nil)
+			}
+			err := tx.get(ctx, &id, `SELECT from_environment_id FROM promotion_rules WHERE environment_id = ?`, id)
+			if errors.Is(err, sql.ErrNoRows) {
+				id = 0
+			} else if err != nil {
+				return err
+			}
+		}
+		r.FromEnvironmentID = from
+
+		if _, err := tx.exec(ctx, `DELETE FROM promotion_rules WHERE environment_id = ?`, r.EnvironmentID); err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, `
+			INSERT INTO promotion_rules (environment_id, from_environment_id, min_soak_seconds, required_approvals)
+			VALUES (?, ?, ?, ?)
+		`, r.EnvironmentID, r.FromEnvironmentID, r.MinSoakSeconds, r.RequiredApprovals)
+		return s.mapError(err, "promotion rule")
+	})
+}
+
+func (s *Store) DeletePromotionRule(ctx context.Context, environmentID int64) error {
+	result, err := s.exec(ctx, `DELETE FROM promotion_rules WHERE environment_id = ?`, environmentID)
+	if err != nil {
+		return err
+	}
+	return deleted(result, "promotion rule not found")
+}
+
+func (s *Store) AddApproval(ctx context.Context, a *model.Approval) (int64, error) {
+	var id int64
+	err := s.inTx(ctx, func(tx *Store) error {
+		if _, err := tx.backend.GetVersionByID(ctx, a.VersionID); err != nil {
+			return err
+		}
+		var err error
+		if a.EnvironmentID, err = tx.environmentID(ctx, a.Environment); err != nil {
+			return err
+		} else if a.EnvironmentID == 0 {
+			return storage.NewError(storage.ErrInvalid, fmt.Sprintf("environment %s does not exist", a.Environment), nil)
+		}
+
+		// An approver's new decision replaces their earlier one.
+		if _, err := tx.exec(ctx, `DELETE FROM approvals WHERE version_id = ? AND environment_id = ? AND approver = ?`,
+			a.VersionID, a.EnvironmentID, a.Approver); err != nil {
+			return err
+		}
+		id, err = tx.insert(ctx, `
+			INSERT INTO approvals (version_id, environment_id, approver, decision, comment, created_at)
+			VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, a.VersionID, a.EnvironmentID, a.Approver, a.Decision, a.Comment)
+		return s.mapError(err, "approval")
+	})
+	return id, err
+}
+
+const selectApprovals = `
+	SELECT a.id, a.version_id, a.environment_id, e.name AS environment, a.approver, a.decision, a.comment, a.created_at
+	FROM approvals a
+	JOIN environments e ON e.id = a.environment_id`
+
+func (s *Store) GetApproval(ctx context.Context, id int64) (*model.Approval, error) {
+	var a model.Approval
+	if err := s.get(ctx, &a, selectApprovals+` WHERE a.id = ?`, id); err != nil {
+		return nil, s.mapError(err, "approval")
+	}
+	return &a, nil
+}
+
+func (s *Store) ListApprovals(ctx context.Context, versionID int64) ([]model.Approval, error) {
+	if _, err := s.backend.GetVersionByID(ctx, versionID); err != nil {
+		return nil, err
+	}
+	approvals := []model.Approval{}
+	err := s.sel(ctx, &approvals, selectApprovals+` WHERE a.version_id = ? ORDER BY a.created_at, a.id`, versionID)
+	return approvals, err
+}
+
+func (s *Store) PromoteVersion(ctx context.Context, versionID int64, d *model.Deployment) (int64, error) {
+	var id int64
+	err := s.inTx(ctx, func(tx *Store) error {
+		v, err := tx.backend.GetVersionByID(ctx, versionID)
+		if err != nil {
+			return err
+		}
+		d.ServiceID, d.Version = v.ServiceID, v.Version
+		d.Status, d.DeployedAt = model.DeploymentSucceeded, time.Now().UTC()
+
+		// CreateDeployment applies the environment's promotion rule.
+		id, err = tx.CreateDeployment(ctx, d)
+		return err
+	})
+	return id, err
+}
+
+// checkPromotion applies the promotion rule of d.EnvironmentID, if it has
+// one, to deploying d.VersionID there at d.DeployedAt.
+func (s *Store) checkPromotion(ctx context.Context, d *model.Deployment) error {
+	rule, err := s.GetPromotionRule(ctx, d.EnvironmentID)
+	if errors.Is(err, storage.ErrNotFound) {
+		// No path into the environment: nothing to check.
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// The version soaks only while it is the one running in the source
+	// environment: a newer deployment there ends its soak time.
+	var current struct {
+		VersionID  int64     `db:"version_id"`
+		DeployedAt time.Time `db:"deployed_at"`
+	}
+	err = s.get(ctx, &current, `
+		SELECT version_id, deployed_at FROM deployments
+		WHERE service_id = ? AND environment_id = ? AND status = ?
+		ORDER BY deployed_at DESC, id DESC
+		LIMIT 1
+	`, d.ServiceID, rule.FromEnvironmentID, model.DeploymentSucceeded)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	var soakedSince time.Time
+	if current.VersionID == d.VersionID {
+		soakedSince = current.DeployedAt
+	}
+	var approvals []model.Approval
+	err = s.sel(ctx, &approvals, selectApprovals+` WHERE a.version_id = ? AND a.environment_id = ?`, d.VersionID, d.EnvironmentID)
+	if err != nil {
+		return err
+	}
+	return storage.CheckPromotion(*rule, d.Version, soakedSince, approvals, d.DeployedAt)
+}
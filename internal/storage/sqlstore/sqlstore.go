@@ -0,0 +1,117 @@
+// Package sqlstore implements the parts of storage.Storage whose SQL is the
+// same on every backend: teams and ownership, labels and tags, dependencies,
+// environments and deployments, promotions, release approvals, freezes and
+// audit events. Each backend embeds a Store and implements services,
+// versions and API keys itself.
+//
+// Queries are written with ? placeholders and rebound for the driver.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// Dialect describes what the shared queries need to know about a database.
+type Dialect struct {
+	// MapError translates driver errors into the storage error kinds, with
+	// a client-safe message about entity ("service", "version", ...).
+	MapError func(err error, entity string) error
+	// Returning is set for databases that report the ID of an inserted row
+	// through INSERT ... RETURNING id rather than LastInsertId.
+	Returning bool
+}
+
+// Store runs the shared queries against a database or one of its
+// transactions.
+type Store struct {
+	db *sqlx.DB
+	// q is db itself, or the open transaction for stores handed out by WithTx.
+	q       sqlx.ExtContext
+	dialect Dialect
+	// backend is the store embedding this one, bound to the same q. The
+	// shared methods use it for services and versions and to start
+	// transactions.
+	backend storage.Storage
+}
+
+// New returns a Store for backend, which must embed it and be bound to q.
+func New(db *sqlx.DB, q sqlx.ExtContext, dialect Dialect, backend storage.Storage) *Store {
+	return &Store{db: db, q: q, dialect: dialect, backend: backend}
+}
+
+// embedder is implemented by every backend through its embedded Store.
+type embedder interface {
+	sqlStore() *Store
+}
+
+func (s *Store) sqlStore() *Store {
+	return s
+}
+
+// inTx runs fn with a store bound to a transaction of the backend, joining
+// the current one if there is one.
+func (s *Store) inTx(ctx context.Context, fn func(tx *Store) error) error {
+	return s.backend.WithTx(ctx, func(tx storage.Storage) error {
+		return fn(tx.(embedder).sqlStore())
+	})
+}
+
+func (s *Store) get(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return sqlx.GetContext(ctx, s.q, dest, s.db.Rebind(query), args...)
+}
+
+func (s *Store) sel(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return sqlx.SelectContext(ctx, s.q, dest, s.db.Rebind(query), args...)
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.q.ExecContext(ctx, s.db.Rebind(query), args...)
+}
+
+// insert runs an INSERT into a table with an id column and returns the ID
+// of the new row. Driver errors are left for the caller to map.
+func (s *Store) insert(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if s.dialect.Returning {
+		var id int64
+		err := s.q.QueryRowxContext(ctx, s.db.Rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+	result, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *Store) mapError(err error, entity string) error {
+	return s.dialect.MapError(err, entity)
+}
+
+// NotUpdated explains a conditional write that matched no rows: either the
+// record is gone or its revision has moved on.
+func (s *Store) NotUpdated(ctx context.Context, table, entity string, id interface{}) error {
+	var exists bool
+	if err := s.get(ctx, &exists, `SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = ?)`, id); err != nil {
+		return err
+	}
+	if !exists {
+		return storage.NewError(storage.ErrNotFound, entity+" not found", nil)
+	}
+	return storage.NewError(storage.ErrPreconditionFailed, entity+" has been modified", nil)
+}
+
+// deleted turns a DELETE that matched no rows into ErrNotFound.
+func deleted(result sql.Result, msg string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.NewError(storage.ErrNotFound, msg, nil)
+	}
+	return nil
+}
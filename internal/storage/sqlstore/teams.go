@@ -0,0 +1,196 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/model"
+)
+
+func (s *Store) ListTeams(ctx context.Context) ([]model.Team, error) {
+	teams := []model.Team{}
+	err := s.sel(ctx, &teams, `
+		SELECT id, name, description, email, chat, created_at
+		FROM teams
+		ORDER BY name`)
+	return teams, err
+}
+
+func (s *Store) GetTeam(ctx context.Context, id int64) (*model.Team, error) {
+	var team model.Team
+	err := s.get(ctx, &team, `
+		SELECT id, name, description, email, chat, created_at
+		FROM teams
+		WHERE id = ?
+	`, id)
+	if err != nil {
+		return nil, s.mapError(err, "team")
+	}
+	return &team, nil
+}
+
+func (s *Store) CreateTeam(ctx context.Context, t *model.Team) (int64, error) {
+	if id, err := s.existingTeamID(ctx, t.Name, 0); err != nil {
+		return 0, err
+	} else if id != 0 {
+		return 0, storage.NewConflictError("team", id, nil)
+	}
+
+	id, err := s.insert(ctx, `
+		INSERT INTO teams (name, description, email, chat, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, t.Name, t.Description, t.Email, t.Chat)
+	return id, s.mapError(err, "team")
+}
+
+func (s *Store) UpdateTeam(ctx context.Context, id int64, t *model.Team) error {
+	if existing, err := s.existingTeamID(ctx, t.Name, id); err != nil {
+		return err
+	} else if existing != 0 {
+		return storage.NewConflictError("team", existing, nil)
+	}
+
+	result, err := s.exec(ctx, `
+		UPDATE teams
+		SET name = ?, description = ?, email = ?, chat = ?
+		WHERE id = ?
+	`, t.Name, t.Description, t.Email, t.Chat, id)
+	if err != nil {
+		return s.mapError(err, "team")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.NewError(storage.ErrNotFound, "team not found", nil)
+	}
+	return nil
+}
+
+func (s *Store) DeleteTeam(ctx context.Context, id int64) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		var owned int
+		if err := tx.get(ctx, &owned, `SELECT COUNT(DISTINCT service_id) FROM service_owners WHERE team_id = ?`, id); err != nil {
+			return err
+		}
+		if owned > 0 {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("team still owns %d service(s)", owned), nil)
+		}
+		// Deleting the team's freezes with it would lift them without the
+		// elevated key deleting a freeze takes.
+		var frozen int
+		if err := tx.get(ctx, &frozen, `SELECT COUNT(*) FROM freezes WHERE team_id = ?`, id); err != nil {
+			return err
+		}
+		if frozen > 0 {
+			return storage.NewError(storage.ErrConflict, fmt.Sprintf("team still has %d freeze(s)", frozen), nil)
+		}
+
+		result, err := tx.exec(ctx, `DELETE FROM teams WHERE id = ?`, id)
+		if err != nil {
+			return s.mapError(err, "team")
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return storage.NewError(storage.ErrNotFound, "team not found", nil)
+		}
+		return nil
+	})
+}
+
+func (s *Store) GetServiceOwnership(ctx context.Context, serviceID int64) (*model.Ownership, error) {
+	var exists bool
+	if err := s.get(ctx, &exists, `SELECT EXISTS(SELECT 1 FROM services WHERE id = ?)`, serviceID); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.NewError(storage.ErrNotFound, "service not found", nil)
+	}
+	return s.Ownership(ctx, serviceID)
+}
+
+func (s *Store) SetServiceOwnership(ctx context.Context, serviceID int64, o storage.OwnershipUpdate) error {
+	return s.inTx(ctx, func(tx *Store) error {
+		// Owners are part of the service representation, so they share its
+		// revision.
+		if err := tx.reviseService(ctx, serviceID, o.Revision); err != nil {
+			return err
+		}
+
+		if o.TeamID != 0 {
+			var exists bool
+			if err := tx.get(ctx, &exists, `SELECT EXISTS(SELECT 1 FROM teams WHERE id = ?)`, o.TeamID); err != nil {
+				return err
+			}
+			if !exists {
+				return storage.NewError(storage.ErrInvalid, fmt.Sprintf("team %d does not exist", o.TeamID), nil)
+			}
+		}
+
+		if _, err := tx.exec(ctx, `DELETE FROM service_owners WHERE service_id = ?`, serviceID); err != nil {
+			return err
+		}
+		if o.TeamID != 0 {
+			if _, err := tx.exec(ctx, `INSERT INTO service_owners (service_id, team_id) VALUES (?, ?)`, serviceID, o.TeamID); err != nil {
+				return s.mapError(err, "owner")
+			}
+		}
+		for _, c := range o.Contacts {
+			if _, err := tx.exec(ctx, `
+				INSERT INTO service_owners (service_id, name, email, chat)
+				VALUES (?, ?, ?, ?)
+			`, serviceID, c.Name, c.Email, c.Chat); err != nil {
+				return s.mapError(err, "owner")
+			}
+		}
+		return nil
+	})
+}
+
+// Ownership loads the owners of a service that is known to exist. The team
+// row, if any, becomes Team and every other row a contact.
+func (s *Store) Ownership(ctx context.Context, serviceID int64) (*model.Ownership, error) {
+	var rows []struct {
+		TeamID sql.NullInt64 `db:"team_id"`
+		model.Contact
+	}
+	err := s.sel(ctx, &rows, `
+		SELECT team_id, name, email, chat
+		FROM service_owners
+		WHERE service_id = ?
+		ORDER BY id`, serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &model.Ownership{Contacts: []model.Contact{}}
+	for _, row := range rows {
+		if !row.TeamID.Valid {
+			o.Contacts = append(o.Contacts, row.Contact)
+			continue
+		}
+		if o.Team, err = s.GetTeam(ctx, row.TeamID.Int64); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// existingTeamID returns the ID of a team other than exceptID that already
+// uses name (compared case-insensitively), or 0.
+func (s *Store) existingTeamID(ctx context.Context, name string, exceptID int64) (int64, error) {
+	var id int64
+	err := s.get(ctx, &id, `SELECT id FROM teams WHERE lower(name) = lower(?) AND id <> ?`, name, exceptID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
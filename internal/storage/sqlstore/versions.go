@@ -0,0 +1,29 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+)
+
+// ExistingVersionID returns the ID of the version of serviceID that is the
+// same version as version, per storage.SameVersion, or 0.
+func (s *Store) ExistingVersionID(ctx context.Context, serviceID int64, version string) (int64, error) {
+	// Only versions with the same number can be the same semantic version.
+	major, minor, patch, _ := storage.SemverColumns(version)
+	var candidates []struct {
+		ID      int64  `db:"id"`
+		Version string `db:"version"`
+	}
+	err := s.sel(ctx, &candidates, `SELECT id, version FROM versions WHERE service_id = ? AND (version = ? OR (major = ? AND minor = ? AND patch = ?))`,
+		serviceID, version, major, minor, patch)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range candidates {
+		if storage.SameVersion(c.Version, version) {
+			return c.ID, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,416 @@
+// Package storagetest holds the storage tests every backend must pass, so
+// that the SQLite suite also runs against PostgreSQL and MySQL.
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codecrafted007/service-catalog-api/internal/logger"
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
+	"github.com/codecrafted007/service-catalog-api/internal/storage"
+	"github.com/codecrafted007/service-catalog-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Open opens a store and migrates its database from scratch, rolling back
+// whatever an earlier test left behind.
+func Open(t *testing.T, driver, dsn string) storage.Storage {
+	t.Helper()
+	store, err := storage.Open(driver, dsn, storage.DefaultOptions())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.DB().Close() })
+
+	m, err := storage.NewMigrator(store.DB(), driver)
+	require.NoError(t, err)
+	_, err = m.To(context.Background(), 0)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	return store
+}
+
+// Run runs every test against a store from open, a fresh one per test.
+func Run(t *testing.T, open func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store storage.Storage)
+	}{
+		{"WithTx", testWithTx},
+		{"VersionsOrderedBySemver", testVersionsOrderedBySemver},
+		{"VersionsUniqueBySemver", testVersionsUniqueBySemver},
+		{"LatestVersions", testLatestVersions},
+		{"UpgradeBackfillsSemver", testUpgradeBackfillsSemver},
+		{"ListServicesByOwner", testListServicesByOwner},
+		{"APIKeys", testAPIKeys},
+		{"RequiredApprovals", testRequiredApprovals},
+		{"ActiveFreezes", testActiveFreezes},
+		{"AuditEvents", testAuditEvents},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, open(t))
+		})
+	}
+}
+
+func testWithTx(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	errBoom := errors.New("boom")
+	err := store.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.CreateService(ctx, &model.Service{Name: "rolled-back"}); err != nil {
+			return err
+		}
+		return errBoom
+	})
+	assert.ErrorIs(t, err, errBoom)
+
+	err = store.WithTx(ctx, func(tx storage.Storage) error {
+		_, err := tx.CreateService(ctx, &model.Service{Name: "committed"})
+		return err
+	})
+	require.NoError(t, err)
+
+	var names []string
+	require.NoError(t, store.DB().Select(&names, "SELECT name FROM services"))
+	assert.Equal(t, []string{"committed"}, names)
+}
+
+func testVersionsOrderedBySemver(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	serviceID, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	for _, v := range []string{"v1.9.0", "v1.10.0", "legacy-build", "v1.10.0-rc.1", "v1.10.0-rc.2"} {
+		_, err := store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: v})
+		require.NoError(t, err)
+	}
+
+	versions, err := store.GetVersionsByServiceID(ctx, serviceID, nil)
+	require.NoError(t, err)
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.Version)
+	}
+	assert.Equal(t, []string{"v1.10.0", "v1.10.0-rc.2", "v1.10.0-rc.1", "v1.9.0", "legacy-build"}, got)
+
+	svc, err := store.GetServiceById(ctx, int(serviceID))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"legacy-build", "v1.9.0", "v1.10.0-rc.1", "v1.10.0-rc.2", "v1.10.0"}, svc.Versions)
+
+	var parts struct {
+		Major, Minor, Patch int
+		Prerelease          string
+	}
+	require.NoError(t, store.DB().Get(&parts, `SELECT major, minor, patch, prerelease FROM versions WHERE version = 'v1.10.0-rc.2'`))
+	assert.Equal(t, 10, parts.Minor)
+	assert.Equal(t, "rc.2", parts.Prerelease)
+}
+
+func testLatestVersions(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	versions := map[string][]string{
+		"payments": {"v1.9.0", "v1.10.0", "v1.11.0-rc.1"},
+		"search":   {"v2.0.0-beta.1"},
+		"web":      {"legacy-build"},
+	}
+	for _, name := range []string{"payments", "search", "web"} {
+		serviceID, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+		for _, v := range versions[name] {
+			_, err := store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: v})
+			require.NoError(t, err)
+		}
+	}
+
+	latest := func(includePrerelease bool) map[int64]string {
+		got, err := store.LatestVersions(ctx, []int64{1, 2, 3, 9}, includePrerelease)
+		require.NoError(t, err)
+		names := map[int64]string{}
+		for id, v := range got {
+			assert.Equal(t, id, v.ServiceID)
+			names[id] = v.Version
+		}
+		return names
+	}
+	assert.Equal(t, map[int64]string{1: "v1.10.0"}, latest(false))
+	assert.Equal(t, map[int64]string{1: "v1.11.0-rc.1", 2: "v2.0.0-beta.1"}, latest(true))
+
+	got, err := store.LatestVersions(ctx, nil, false)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func testVersionsUniqueBySemver(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	serviceID, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	first, err := store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: "v1.0.0-rc1"})
+	require.NoError(t, err)
+
+	_, err = store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: "1.0.0-rc1"})
+	var conflict *storage.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, first, conflict.ExistingID)
+
+	// Build metadata does not make a new version.
+	_, err = store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: "1.0.0-rc1+build.7"})
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, first, conflict.ExistingID)
+
+	// Prerelease identifiers are case-sensitive.
+	_, err = store.CreateVersion(ctx, &model.Version{ServiceID: serviceID, Version: "1.0.0-RC1"})
+	require.NoError(t, err)
+
+	// The schema holds the same line for writers that skip the check.
+	_, err = store.DB().Exec(store.DB().Rebind(`
+		INSERT INTO versions (service_id, version, changelog, created_at, major, minor, patch, prerelease, state)
+		VALUES (?, 'V1.0.0-rc1', '', CURRENT_TIMESTAMP, 1, 0, 0, 'rc1', 'released')`), serviceID)
+	assert.Error(t, err)
+}
+
+// A database created before the semver columns gets them filled in for
+// its existing versions when it is upgraded.
+func testUpgradeBackfillsSemver(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	m, err := storage.NewMigrator(store.DB(), store.DB().DriverName())
+	require.NoError(t, err)
+	_, err = m.To(ctx, 1)
+	require.NoError(t, err)
+	_, err = store.DB().Exec(`INSERT INTO services (name) VALUES ('payments')`)
+	require.NoError(t, err)
+	for _, v := range []string{"v1.0.0", "v1.2.0-rc.1", "nightly"} {
+		_, err = store.DB().Exec(store.DB().Rebind(`INSERT INTO versions (service_id, version, changelog) VALUES (1, ?, '')`), v)
+		require.NoError(t, err)
+	}
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	latest, err := store.GetLatestVersion(ctx, 1, false)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, "v1.0.0", latest.Version)
+	constraint, err := semver.ParseConstraint("^1")
+	require.NoError(t, err)
+	resolved, err := store.ResolveVersion(ctx, 1, constraint)
+	require.NoError(t, err)
+	require.NotNil(t, resolved)
+	assert.Equal(t, "v1.0.0", resolved.Version)
+
+	_, err = store.CreateVersion(ctx, &model.Version{ServiceID: 1, Version: "1.0.0"})
+	var conflict *storage.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, latest.ID, conflict.ExistingID)
+}
+
+func testListServicesByOwner(t *testing.T, store storage.Storage) {
+	logger.InitLogger() // ListServices logs its query
+	ctx := context.Background()
+
+	teamID, err := store.CreateTeam(ctx, &model.Team{Name: "Checkout"})
+	require.NoError(t, err)
+	for _, name := range []string{"payments", "ledger", "search"} {
+		_, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.SetServiceOwnership(ctx, 1, storage.OwnershipUpdate{TeamID: teamID}))
+	require.NoError(t, store.SetServiceOwnership(ctx, 2, storage.OwnershipUpdate{
+		Contacts: []model.Contact{{Name: "Ana", Email: "ana@example.com", Chat: "@ana"}},
+	}))
+
+	names := func(f storage.ServiceFilter) []string {
+		services, err := store.ListServices(ctx, f, "name", 1, 10)
+		require.NoError(t, err)
+		var got []string
+		for _, s := range services {
+			got = append(got, s.Name)
+		}
+		return got
+	}
+	assert.Equal(t, []string{"payments"}, names(storage.ServiceFilter{Owner: "checkout"}))
+	assert.Equal(t, []string{"ledger"}, names(storage.ServiceFilter{Owner: "ANA@example.com"}))
+	assert.Equal(t, []string{"ledger"}, names(storage.ServiceFilter{Owner: "@ana"}))
+	assert.Empty(t, names(storage.ServiceFilter{Owner: "@ana", Text: "pay"}))
+	assert.Len(t, names(storage.ServiceFilter{}), 3)
+}
+
+func testAPIKeys(t *testing.T, store storage.Storage) {
+	ctx := storage.WithActor(context.Background(), "cli")
+
+	require.NoError(t, store.CreateAPIKey(ctx, "secret", "ana", false))
+	key, err := store.GetAPIKey("secret")
+	require.NoError(t, err)
+	assert.Equal(t, "ana", key.Name)
+	assert.False(t, key.Elevated)
+
+	require.NoError(t, store.CreateAPIKey(ctx, "root", "ops", true))
+	key, err = store.GetAPIKey("root")
+	require.NoError(t, err)
+	assert.True(t, key.Elevated)
+
+	_, err = store.GetAPIKey("guess")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, store.CreateAPIKey(ctx, "other", "ana", false), storage.ErrConflict)
+
+	// Creating a key is audited, without the key itself.
+	events, err := store.ListAuditEvents(ctx, storage.AuditFilter{EntityType: "api_key", EntityID: key.ID}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, model.AuditCreate, events[0].Action)
+	assert.Equal(t, "cli", events[0].Actor)
+	assert.JSONEq(t, `{"name":"ops","elevated":true}`, string(events[0].After))
+}
+
+func testRequiredApprovals(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	for _, name := range []string{"payments", "search", "ledger"} {
+		_, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.SetServiceLabel(ctx, 1, "tier", "critical", 0))
+	require.NoError(t, store.SetServiceLabel(ctx, 2, "tier", "low", 0))
+	require.NoError(t, store.SetServiceLabel(ctx, 3, "pci", "", 0))
+	for _, p := range []model.ApprovalPolicy{
+		{LabelKey: "tier", LabelValue: "critical", RequiredApprovals: 2},
+		// Without a value, the policy covers every tier.
+		{LabelKey: "tier", RequiredApprovals: 1},
+		{LabelKey: "pci", RequiredApprovals: 3},
+	} {
+		_, err := store.CreateApprovalPolicy(ctx, &p)
+		require.NoError(t, err)
+	}
+
+	for serviceID, want := range map[int64]int{1: 2, 2: 1, 3: 3} {
+		required, err := store.RequiredApprovals(ctx, serviceID)
+		require.NoError(t, err)
+		assert.Equal(t, want, required, "service %d", serviceID)
+	}
+	require.NoError(t, store.RemoveServiceLabel(ctx, 2, "tier", 0))
+	required, err := store.RequiredApprovals(ctx, 2)
+	require.NoError(t, err)
+	assert.Zero(t, required)
+}
+
+func testActiveFreezes(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	teamID, err := store.CreateTeam(ctx, &model.Team{Name: "Checkout"})
+	require.NoError(t, err)
+	for _, name := range []string{"payments", "search"} {
+		_, err := store.CreateService(ctx, &model.Service{Name: name})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.SetServiceOwnership(ctx, 1, storage.OwnershipUpdate{TeamID: teamID}))
+	require.NoError(t, store.AddServiceTag(ctx, 2, "frozen", 0))
+
+	start := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := start.Add(14 * 24 * time.Hour)
+	for _, f := range []model.Freeze{
+		{StartsAt: start, EndsAt: end, TeamID: &teamID, Reason: "holidays"},
+		{StartsAt: start.Add(time.Hour), EndsAt: end.Add(500 * time.Millisecond), Selector: "frozen", Reason: "migration"},
+	} {
+		_, err := store.CreateFreeze(ctx, &f)
+		require.NoError(t, err)
+	}
+	_, err = store.CreateFreeze(ctx, &model.Freeze{StartsAt: end, EndsAt: start, Reason: "backwards"})
+	assert.ErrorIs(t, err, storage.ErrInvalid)
+
+	reasons := func(serviceID int64, at time.Time) []string {
+		freezes, err := store.ActiveFreezes(ctx, serviceID, at)
+		require.NoError(t, err)
+		got := []string{}
+		for _, f := range freezes {
+			got = append(got, f.Reason)
+		}
+		return got
+	}
+	assert.Empty(t, reasons(0, start.Add(-time.Nanosecond)))
+	assert.Equal(t, []string{"holidays"}, reasons(0, start))
+	assert.Equal(t, []string{"migration", "holidays"}, reasons(0, start.Add(time.Hour)))
+	assert.Equal(t, []string{"holidays"}, reasons(1, start.Add(time.Hour)))
+	assert.Equal(t, []string{"migration"}, reasons(2, start.Add(time.Hour)))
+	// Freezes end exclusively, to the sub-second.
+	assert.Equal(t, []string{"migration"}, reasons(0, end))
+	assert.Empty(t, reasons(0, end.Add(500*time.Millisecond)))
+
+	_, err = store.ActiveFreezes(ctx, 9, start)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Deleting the team would lift its freeze, so it is refused even once
+	// the team owns nothing.
+	require.NoError(t, store.SetServiceOwnership(ctx, 1, storage.OwnershipUpdate{}))
+	assert.ErrorIs(t, store.DeleteTeam(ctx, teamID), storage.ErrConflict)
+	assert.Equal(t, []string{"holidays"}, reasons(0, start))
+}
+
+func testAuditEvents(t *testing.T, store storage.Storage) {
+	ctx := storage.WithRequestID(storage.WithActor(context.Background(), "ana"), "req-1")
+
+	id, err := store.CreateService(ctx, &model.Service{Name: "payments"})
+	require.NoError(t, err)
+	desc := "card payments"
+	require.NoError(t, store.PatchService(ctx, int(id), storage.ServicePatch{Description: &desc}))
+	require.NoError(t, store.SetServiceLabel(ctx, id, "tier", "critical", 0))
+
+	// Failed and rolled back writes leave no trace.
+	_, err = store.CreateService(ctx, &model.Service{Name: "payments"})
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	err = store.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.CreateService(ctx, &model.Service{Name: "rolled-back"}); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+	require.Error(t, err)
+
+	since := time.Now()
+	require.NoError(t, store.DeleteService(storage.WithActor(ctx, "bo"), int(id), 0))
+
+	events, err := store.ListAuditEvents(ctx, storage.AuditFilter{EntityType: "service", EntityID: id}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	var actions []model.AuditAction
+	for _, e := range events {
+		actions = append(actions, e.Action)
+		assert.Equal(t, "req-1", e.RequestID)
+	}
+	assert.Equal(t, []model.AuditAction{model.AuditCreate, model.AuditUpdate, model.AuditUpdate, model.AuditDelete}, actions)
+
+	created := events[0]
+	assert.Nil(t, created.Before)
+	var after model.Service
+	require.NoError(t, json.Unmarshal(created.After, &after))
+	assert.Equal(t, "payments", after.Name)
+
+	var before model.Service
+	require.NoError(t, json.Unmarshal(events[2].After, &after))
+	require.NoError(t, json.Unmarshal(events[2].Before, &before))
+	assert.Empty(t, before.Labels)
+	assert.Equal(t, map[string]string{"tier": "critical"}, after.Labels)
+
+	deleted := events[3]
+	assert.Equal(t, "bo", deleted.Actor)
+	assert.Nil(t, deleted.After)
+	require.NoError(t, json.Unmarshal(deleted.Before, &before))
+	assert.Equal(t, "card payments", before.Description)
+
+	events, err = store.ListAuditEvents(ctx, storage.AuditFilter{Actor: "bo"}, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+	events, err = store.ListAuditEvents(ctx, storage.AuditFilter{Since: since}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, model.AuditDelete, events[0].Action)
+	events, err = store.ListAuditEvents(ctx, storage.AuditFilter{}, 2, 3)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
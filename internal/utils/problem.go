@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// ErrorFormat selects how error responses are rendered.
+type ErrorFormat string
+
+const (
+	// ErrorFormatEnvelope is the original {code,data,error,success} body.
+	ErrorFormatEnvelope ErrorFormat = "envelope"
+	// ErrorFormatProblem is RFC 7807 application/problem+json.
+	ErrorFormatProblem ErrorFormat = "problem"
+)
+
+var defaultErrorFormat = ErrorFormatEnvelope
+
+// SetErrorFormat sets the format used when the client does not ask for
+// problem+json explicitly.
+func SetErrorFormat(f ErrorFormat) {
+	defaultErrorFormat = f
+}
+
+func ParseErrorFormat(s string) (ErrorFormat, error) {
+	switch f := ErrorFormat(strings.ToLower(s)); f {
+	case ErrorFormatEnvelope, ErrorFormatProblem:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown error format %q (want envelope or problem)", s)
+}
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	// Extensions are extra members serialized alongside the standard ones.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	body, err := json.Marshal(plain(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return body, err
+	}
+	members := make(map[string]interface{}, len(p.Extensions))
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	// Standard members win over extensions with the same name.
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
+
+// FieldError points at a single invalid input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ProblemType returns the type URI for a status, e.g. /problems/not-found.
+// The URIs are relative references that resolve against the API's own host.
+func ProblemType(status int) string {
+	title := http.StatusText(status)
+	if title == "" {
+		return "about:blank"
+	}
+	return "/problems/" + strings.ReplaceAll(strings.ToLower(title), " ", "-")
+}
+
+// WriteError writes an error response in the format the client accepts,
+// falling back to the server default.
+func WriteError(w http.ResponseWriter, r *http.Request, statusCode int, errStr string) {
+	WriteFieldErrors(w, r, statusCode, errStr, nil)
+}
+
+// WriteFieldErrors is WriteError with per-field details. The envelope format
+// carries them in data, problem+json in the errors member.
+func WriteFieldErrors(w http.ResponseWriter, r *http.Request, statusCode int, errStr string, fields []FieldError) {
+	if !wantsProblem(r) {
+		var data interface{}
+		if len(fields) > 0 {
+			data = fields
+		}
+		WriteJSON(w, statusCode, data, errStr)
+		return
+	}
+	writeProblem(w, r, Problem{Detail: errStr, Errors: fields}, statusCode)
+}
+
+// WriteErrorDetails is WriteError with extra machine-readable members, such
+// as the ID of a conflicting record. The envelope format carries them in
+// data, problem+json as extension members.
+func WriteErrorDetails(w http.ResponseWriter, r *http.Request, statusCode int, errStr string, details map[string]interface{}) {
+	if !wantsProblem(r) {
+		WriteJSON(w, statusCode, details, errStr)
+		return
+	}
+	writeProblem(w, r, Problem{Detail: errStr, Extensions: details}, statusCode)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, problem Problem, statusCode int) {
+	problem.Type = ProblemType(statusCode)
+	problem.Title = http.StatusText(statusCode)
+	problem.Status = statusCode
+	problem.Instance = r.URL.Path
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func wantsProblem(r *http.Request) bool {
+	if strings.Contains(r.Header.Get("Accept"), ProblemContentType) {
+		return true
+	}
+	return defaultErrorFormat == ErrorFormatProblem
+}
@@ -0,0 +1,226 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/codecrafted007/service-catalog-api/internal/semver"
+)
+
+// Config holds the limits that can be tuned per deployment. A zero limit
+// disables that check, an empty NamePattern accepts any name and
+// RequireSemver false accepts any version string.
+type Config struct {
+	MaxBodyBytes         int64  `json:"maxBodyBytes"`
+	NameMaxLength        int    `json:"nameMaxLength"`
+	NamePattern          string `json:"namePattern"`
+	DescriptionMaxLength int    `json:"descriptionMaxLength"`
+	VersionMaxLength     int    `json:"versionMaxLength"`
+	ChangelogMaxLength   int    `json:"changelogMaxLength"`
+	RequireSemver        bool   `json:"requireSemver"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxBodyBytes:         1 << 20,
+		NameMaxLength:        100,
+		NamePattern:          `^[A-Za-z0-9][A-Za-z0-9 ._-]*$`,
+		DescriptionMaxLength: 2000,
+		VersionMaxLength:     64,
+		ChangelogMaxLength:   10000,
+		RequireSemver:        true,
+	}
+}
+
+// LoadConfig reads a JSON file on top of the defaults, so a deployment only
+// needs to list the limits it wants to change.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read validation config: %w", err)
+	}
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid validation config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validator is a Config with its patterns compiled.
+type Validator struct {
+	Config
+	namePattern *regexp.Regexp
+}
+
+func New(cfg Config) (*Validator, error) {
+	v := &Validator{Config: cfg}
+	if cfg.NamePattern != "" {
+		re, err := regexp.Compile(cfg.NamePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namePattern: %w", err)
+		}
+		v.namePattern = re
+	}
+	return v, nil
+}
+
+// Default returns a Validator for DefaultConfig.
+func Default() *Validator {
+	v, err := New(DefaultConfig())
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Rule checks a value and returns a message describing the failure, or ""
+// when the value is acceptable.
+type Rule func(value string) string
+
+// Field declares the rules that apply to one input field.
+type Field struct {
+	Name  string
+	Value string
+	Rules []Rule
+}
+
+func F(name, value string, rules ...Rule) Field {
+	return Field{Name: name, Value: value, Rules: rules}
+}
+
+// FieldError reports a rule failure for a single field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Errors collects every field that failed validation.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Check runs every rule of every field and returns all failures, stopping
+// at the first failing rule of each field. It returns nil when all pass.
+func Check(fields ...Field) Errors {
+	var errs Errors
+	for _, f := range fields {
+		for _, rule := range f.Rules {
+			if msg := rule(f.Value); msg != "" {
+				errs = append(errs, FieldError{Field: f.Name, Message: msg})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+func Required() Rule {
+	return func(value string) string {
+		if strings.TrimSpace(value) == "" {
+			return "is required"
+		}
+		return ""
+	}
+}
+
+// MaxLength limits the number of characters (not bytes); n <= 0 disables it.
+func MaxLength(n int) Rule {
+	return func(value string) string {
+		if n > 0 && utf8.RuneCountInString(value) > n {
+			return fmt.Sprintf("must be at most %d characters", n)
+		}
+		return ""
+	}
+}
+
+// Matches requires the value to match re; empty values are left to Required.
+func Matches(re *regexp.Regexp) Rule {
+	return func(value string) string {
+		if re != nil && value != "" && !re.MatchString(value) {
+			return fmt.Sprintf("must match %s", re.String())
+		}
+		return ""
+	}
+}
+
+// OneOf restricts the value to a fixed set; empty values are left to
+// Required.
+func OneOf(allowed ...string) Rule {
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+		for _, a := range allowed {
+			if value == a {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(allowed, ", ")
+	}
+}
+
+// Email requires a bare address such as ana@example.com; empty values are
+// left to Required.
+func Email() Rule {
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+		if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+			return "must be an email address"
+		}
+		return ""
+	}
+}
+
+// NamePattern is the configured naming rule for services.
+func (v *Validator) NamePattern() Rule {
+	return Matches(v.namePattern)
+}
+
+// SemVer requires a semantic version such as 1.2.3 or v1.2.3-rc.1+build.5;
+// empty values are left to Required.
+func SemVer() Rule {
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+		if _, err := semver.Parse(value); err != nil {
+			return "must be a semantic version (MAJOR.MINOR.PATCH with optional v prefix, -prerelease and +build)"
+		}
+		return ""
+	}
+}
+
+// SemVerConstraint requires a version range such as ^1.2, >=1.0.0 <2.0.0 or
+// 1.x || 2.x; empty values are left to Required.
+func SemVerConstraint() Rule {
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+		if _, err := semver.ParseConstraint(value); err != nil {
+			return "must be a version range such as ^1.2 or >=1.0.0 <2.0.0"
+		}
+		return ""
+	}
+}
+
+// VersionFormat is the configured rule for version strings.
+func (v *Validator) VersionFormat() Rule {
+	if !v.RequireSemver {
+		return func(string) string { return "" }
+	}
+	return SemVer()
+}
@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCollectsEveryField(t *testing.T) {
+	v := Default()
+	errs := Check(
+		F("name", "", Required(), MaxLength(v.NameMaxLength), v.NamePattern()),
+		F("description", strings.Repeat("x", v.DescriptionMaxLength+1), MaxLength(v.DescriptionMaxLength)),
+		F("version", "v1.0.0", Required()),
+	)
+	require.Len(t, errs, 2)
+	assert.Equal(t, FieldError{Field: "name", Message: "is required"}, errs[0])
+	assert.Equal(t, "description", errs[1].Field)
+}
+
+func TestNamePattern(t *testing.T) {
+	v := Default()
+	assert.Empty(t, v.NamePattern()("Payments API v2"))
+	assert.NotEmpty(t, v.NamePattern()("-leading-dash"))
+
+	unrestricted, err := New(Config{})
+	require.NoError(t, err)
+	assert.Empty(t, unrestricted.NamePattern()("-anything goes-"))
+}
+
+func TestLoadConfigOverlaysDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "validation.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"nameMaxLength": 20}`), 0o600))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 20, cfg.NameMaxLength)
+	assert.Equal(t, DefaultConfig().VersionMaxLength, cfg.VersionMaxLength)
+}
+
+func TestVersionFormat(t *testing.T) {
+	v := Default()
+	assert.Empty(t, v.VersionFormat()("v1.10.0-rc.1+build.5"))
+	assert.NotEmpty(t, v.VersionFormat()("release-7"))
+
+	lenient, err := New(Config{})
+	require.NoError(t, err)
+	assert.Empty(t, lenient.VersionFormat()("release-7"))
+}
+
+func TestSemVerConstraint(t *testing.T) {
+	assert.Empty(t, SemVerConstraint()("^1.2 || >=3.0.0 <4.0.0"))
+	assert.Empty(t, SemVerConstraint()(""))
+	assert.NotEmpty(t, SemVerConstraint()("about one"))
+}
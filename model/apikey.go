@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// APIKey is who a request is made by. The key itself is never exposed.
+type APIKey struct {
+	ID   int64  `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+	// Elevated keys may override a change freeze.
+	Elevated  bool      `db:"elevated" json:"elevated"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// ApprovalPolicy is how many approvals it takes to release a version of a
+// service. It applies to the service ServiceID or, when that is nil, to
+// every service labelled LabelKey=LabelValue, or with any value of LabelKey
+// when LabelValue is empty.
+type ApprovalPolicy struct {
+	ID                int64     `db:"id" json:"id"`
+	ServiceID         *int64    `db:"service_id" json:"serviceId,omitempty"`
+	LabelKey          string    `db:"label_key" json:"labelKey,omitempty"`
+	LabelValue        string    `db:"label_value" json:"labelValue,omitempty"`
+	RequiredApprovals int       `db:"required_approvals" json:"requiredApprovals"`
+	CreatedAt         time.Time `db:"created_at" json:"createdAt"`
+}
+
+// ApprovalRequestStatus is where an approval request stands.
+type ApprovalRequestStatus string
+
+const (
+	ApprovalRequestPending  ApprovalRequestStatus = "pending"
+	ApprovalRequestApproved ApprovalRequestStatus = "approved"
+	ApprovalRequestRejected ApprovalRequestStatus = "rejected"
+)
+
+// ApprovalRequestStatuses lists every status.
+var ApprovalRequestStatuses = []ApprovalRequestStatus{ApprovalRequestPending, ApprovalRequestApproved, ApprovalRequestRejected}
+
+// ApprovalRequest asks for a draft version to be released. It is approved,
+// and the version released, once RequiredApprovals reviewers other than the
+// requester approve it; a single rejection rejects it.
+type ApprovalRequest struct {
+	ID                int64                 `db:"id" json:"id"`
+	VersionID         int64                 `db:"version_id" json:"versionId"`
+	ServiceID         int64                 `db:"service_id" json:"serviceId"`
+	Version           string                `db:"version" json:"version"`
+	RequestedBy       string                `db:"requested_by" json:"requestedBy"`
+	Comment           string                `db:"comment" json:"comment,omitempty"`
+	Status            ApprovalRequestStatus `db:"status" json:"status"`
+	RequiredApprovals int                   `db:"required_approvals" json:"requiredApprovals"`
+	Reviews           []ApprovalReview      `db:"-" json:"reviews"`
+	CreatedAt         time.Time             `db:"created_at" json:"createdAt"`
+	ResolvedAt        *time.Time            `db:"resolved_at" json:"resolvedAt,omitempty"`
+}
+
+// ApprovalReview is one reviewer's decision on an approval request.
+type ApprovalReview struct {
+	ID        int64            `db:"id" json:"id"`
+	RequestID int64            `db:"request_id" json:"requestId"`
+	Reviewer  string           `db:"reviewer" json:"reviewer"`
+	Decision  ApprovalDecision `db:"decision" json:"decision"`
+	Comment   string           `db:"comment" json:"comment,omitempty"`
+	CreatedAt time.Time        `db:"created_at" json:"createdAt"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// AuditAction is what a write did to a record.
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+	// AuditOverride records a change let through a freeze that covered the
+	// service. It is an event on the freeze.
+	AuditOverride AuditAction = "override"
+)
+
+// AuditEvent records one write: who made it, in which request, and the
+// record before and after it.
+type AuditEvent struct {
+	ID     int64       `db:"id" json:"id"`
+	Actor  string      `db:"actor" json:"actor"`
+	Action AuditAction `db:"action" json:"action"`
+	// EntityType and EntityID name the record, e.g. service 42.
+	EntityType string    `db:"entity_type" json:"entityType"`
+	EntityID   int64     `db:"entity_id" json:"entityId"`
+	Before     Snapshot  `db:"before_json" json:"before"`
+	After      Snapshot  `db:"after_json" json:"after"`
+	RequestID  string    `db:"request_id" json:"requestId,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+}
+
+// Snapshot is a record as JSON. An empty snapshot means the record did not
+// exist, and is null both in the database and in API responses.
+type Snapshot []byte
+
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	if len(s) == 0 {
+		return []byte("null"), nil
+	}
+	return s, nil
+}
+
+func (s *Snapshot) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*s = nil
+		return nil
+	}
+	*s = append(Snapshot(nil), b...)
+	return nil
+}
+
+// Scan copies the column, since drivers may reuse the bytes they return.
+func (s *Snapshot) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+	case []byte:
+		*s = append(Snapshot(nil), v...)
+	case string:
+		*s = Snapshot(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a snapshot", src)
+	}
+	return nil
+}
+
+func (s Snapshot) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	return string(s), nil
+}
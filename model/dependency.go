@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Dependency records that ServiceID calls DependsOnID, optionally pinned to
+// a semver range of it.
+type Dependency struct {
+	ID          int64     `db:"id" json:"id"`
+	ServiceID   int64     `db:"service_id" json:"serviceId"`
+	DependsOnID int64     `db:"depends_on_id" json:"dependsOnId"`
+	Constraint  string    `db:"version_constraint" json:"constraint,omitempty"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// DependencyNode is a service reached while walking dependencies or
+// dependents, through the edge from Via.
+type DependencyNode struct {
+	ServiceID  int64  `json:"serviceId"`
+	Name       string `json:"name"`
+	Depth      int    `json:"depth"`
+	Via        int64  `json:"via"`
+	Constraint string `json:"constraint,omitempty"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Environment is a place versions are deployed to, such as dev, staging or
+// prod.
+type Environment struct {
+	ID          int64     `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// DeploymentStatus is the outcome of a deployment.
+type DeploymentStatus string
+
+const (
+	DeploymentInProgress DeploymentStatus = "in_progress"
+	DeploymentSucceeded  DeploymentStatus = "succeeded"
+	DeploymentFailed     DeploymentStatus = "failed"
+	DeploymentRolledBack DeploymentStatus = "rolled_back"
+)
+
+// DeploymentStatuses lists every status.
+var DeploymentStatuses = []DeploymentStatus{DeploymentInProgress, DeploymentSucceeded, DeploymentFailed, DeploymentRolledBack}
+
+// Deployment records a version being deployed to an environment. Version
+// and Environment are the names of the records behind VersionID and
+// EnvironmentID. Actor is the API key that recorded it; TriggeredBy is
+// whoever the caller, such as a CI pipeline, says started it.
+type Deployment struct {
+	ID            int64            `db:"id" json:"id"`
+	ServiceID     int64            `db:"service_id" json:"serviceId"`
+	VersionID     int64            `db:"version_id" json:"versionId"`
+	Version       string           `db:"version" json:"version"`
+	EnvironmentID int64            `db:"environment_id" json:"environmentId"`
+	Environment   string           `db:"environment" json:"environment"`
+	Status        DeploymentStatus `db:"status" json:"status"`
+	Actor         string           `db:"actor" json:"actor"`
+	TriggeredBy   string           `db:"triggered_by" json:"triggeredBy,omitempty"`
+	DeployedAt    time.Time        `db:"deployed_at" json:"deployedAt"`
+}
+
+// EnvironmentStatus is what a service is running in one environment: its
+// latest successful deployment there, or nil.
+type EnvironmentStatus struct {
+	Environment Environment `json:"environment"`
+	Current     *Deployment `json:"current"`
+}
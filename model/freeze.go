@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Freeze is a change freeze: from StartsAt until EndsAt no version of a
+// service it covers may be released, promoted or deployed. It covers the
+// services matching Selector and owned by TeamID, whichever are set, or
+// every service when neither is.
+type Freeze struct {
+	ID        int64     `db:"id" json:"id"`
+	StartsAt  time.Time `db:"starts_at" json:"startsAt"`
+	EndsAt    time.Time `db:"ends_at" json:"endsAt"`
+	Selector  string    `db:"selector" json:"selector,omitempty"`
+	TeamID    *int64    `db:"team_id" json:"teamId,omitempty"`
+	Reason    string    `db:"reason" json:"reason"`
+	CreatedBy string    `db:"created_by" json:"createdBy"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+// ActiveAt reports whether the freeze is in force at t.
+func (f Freeze) ActiveAt(t time.Time) bool {
+	return !t.Before(f.StartsAt) && t.Before(f.EndsAt)
+}
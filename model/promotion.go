@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// PromotionRule is the path into an environment: a version is promoted to
+// EnvironmentID only after a successful deployment to FromEnvironmentID
+// that is at least MinSoakSeconds old, and once RequiredApprovals people
+// have approved it for the target. Environment and From are the names of
+// the two environments.
+type PromotionRule struct {
+	EnvironmentID     int64  `db:"environment_id" json:"environmentId"`
+	Environment       string `db:"environment" json:"environment"`
+	FromEnvironmentID int64  `db:"from_environment_id" json:"fromEnvironmentId"`
+	From              string `db:"from_environment" json:"from"`
+	MinSoakSeconds    int64  `db:"min_soak_seconds" json:"minSoakSeconds"`
+	RequiredApprovals int    `db:"required_approvals" json:"requiredApprovals"`
+}
+
+// ApprovalDecision is an approver's verdict.
+type ApprovalDecision string
+
+const (
+	ApprovalApproved ApprovalDecision = "approved"
+	ApprovalRejected ApprovalDecision = "rejected"
+)
+
+// ApprovalDecisions lists every decision.
+var ApprovalDecisions = []ApprovalDecision{ApprovalApproved, ApprovalRejected}
+
+// Approval is one approver's decision on promoting a version to an
+// environment.
+type Approval struct {
+	ID            int64            `db:"id" json:"id"`
+	VersionID     int64            `db:"version_id" json:"versionId"`
+	EnvironmentID int64            `db:"environment_id" json:"environmentId"`
+	Environment   string           `db:"environment" json:"environment"`
+	Approver      string           `db:"approver" json:"approver"`
+	Decision      ApprovalDecision `db:"decision" json:"decision"`
+	Comment       string           `db:"comment" json:"comment,omitempty"`
+	CreatedAt     time.Time        `db:"created_at" json:"createdAt"`
+}
@@ -3,9 +3,15 @@ package model
 import "time"
 
 type Service struct {
-	ID          int       `db:"id" json:"id"`
-	Name        string    `db:"name" json:"name"`
-	Description string    `db:"description" json:"description"`
-	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
-	Versions    []string  `json:"versions"`
+	ID          int        `db:"id" json:"id"`
+	Name        string     `db:"name" json:"name"`
+	Description string     `db:"description" json:"description"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+	Versions    []string   `json:"versions"`
+	Revision    int64      `db:"revision" json:"revision"`
+	Ownership   *Ownership `json:"ownership,omitempty"`
+	// Labels are key=value pairs and Tags free-form markers, both
+	// matched by label selectors.
+	Labels map[string]string `json:"labels"`
+	Tags   []string          `json:"tags"`
 }
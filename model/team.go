@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type Team struct {
+	ID          int64     `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	Email       string    `db:"email" json:"email,omitempty"`
+	Chat        string    `db:"chat" json:"chat,omitempty"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// Contact is a person to reach about a service.
+type Contact struct {
+	Name  string `db:"name" json:"name"`
+	Email string `db:"email" json:"email,omitempty"`
+	Chat  string `db:"chat" json:"chat,omitempty"`
+}
+
+// Ownership says who answers for a service: the owning team, if one is
+// assigned, and any individual contacts.
+type Ownership struct {
+	Team     *Team     `json:"team"`
+	Contacts []Contact `json:"contacts"`
+}
@@ -2,10 +2,50 @@ package model
 
 import "time"
 
+// VersionState is where a version is in its release lifecycle.
+type VersionState string
+
+const (
+	VersionDraft      VersionState = "draft"
+	VersionReleased   VersionState = "released"
+	VersionDeprecated VersionState = "deprecated"
+	VersionYanked     VersionState = "yanked"
+)
+
+// VersionStates lists every state in lifecycle order.
+var VersionStates = []VersionState{VersionDraft, VersionReleased, VersionDeprecated, VersionYanked}
+
+// Valid reports whether s is a known state.
+func (s VersionState) Valid() bool {
+	for _, known := range VersionStates {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransitionTo reports whether a version may move from s to next:
+// draft to released to deprecated, or from any state but yanked to yanked.
+func (s VersionState) CanTransitionTo(next VersionState) bool {
+	switch next {
+	case VersionReleased:
+		return s == VersionDraft
+	case VersionDeprecated:
+		return s == VersionReleased
+	case VersionYanked:
+		return s.Valid() && s != VersionYanked
+	}
+	return false
+}
+
 type Version struct {
-	ID        int64     `db:"id" json:"id"`
-	ServiceID int64     `db:"service_id" json:"serviceId"`
-	Version   string    `db:"version" json:"version"`
-	Changelog string    `db:"changelog" json:"changelog,omitempty"`
-	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	ID          int64        `db:"id" json:"id"`
+	ServiceID   int64        `db:"service_id" json:"serviceId"`
+	Version     string       `db:"version" json:"version"`
+	Changelog   string       `db:"changelog" json:"changelog,omitempty"`
+	CreatedAt   time.Time    `db:"created_at" json:"createdAt"`
+	Revision    int64        `db:"revision" json:"revision"`
+	State       VersionState `db:"state" json:"state"`
+	StateReason string       `db:"state_reason" json:"stateReason,omitempty"`
 }